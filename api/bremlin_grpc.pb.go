@@ -0,0 +1,250 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: bremlin.proto
+
+// The Bremlin query service: parses, validates, explains and evaluates
+// commands against the graph the server loaded at startup.
+
+package api
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Bremlin_Parse_FullMethodName    = "/bremlin.Bremlin/Parse"
+	Bremlin_Validate_FullMethodName = "/bremlin.Bremlin/Validate"
+	Bremlin_Explain_FullMethodName  = "/bremlin.Bremlin/Explain"
+	Bremlin_Eval_FullMethodName     = "/bremlin.Bremlin/Eval"
+)
+
+// BremlinClient is the client API for Bremlin service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type BremlinClient interface {
+	// Returns the steps of the command.
+	Parse(ctx context.Context, in *Query, opts ...grpc.CallOption) (*Plan, error)
+	// Checks the command against the schema of the server.
+	Validate(ctx context.Context, in *Query, opts ...grpc.CallOption) (*Validation, error)
+	// Returns the steps of the command and the plan they are evaluated with.
+	Explain(ctx context.Context, in *Query, opts ...grpc.CallOption) (*Plan, error)
+	// Evaluates the command and streams the nodes it selects, in batches.
+	Eval(ctx context.Context, in *Query, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Result], error)
+}
+
+type bremlinClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBremlinClient(cc grpc.ClientConnInterface) BremlinClient {
+	return &bremlinClient{cc}
+}
+
+func (c *bremlinClient) Parse(ctx context.Context, in *Query, opts ...grpc.CallOption) (*Plan, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Plan)
+	err := c.cc.Invoke(ctx, Bremlin_Parse_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bremlinClient) Validate(ctx context.Context, in *Query, opts ...grpc.CallOption) (*Validation, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Validation)
+	err := c.cc.Invoke(ctx, Bremlin_Validate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bremlinClient) Explain(ctx context.Context, in *Query, opts ...grpc.CallOption) (*Plan, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Plan)
+	err := c.cc.Invoke(ctx, Bremlin_Explain_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bremlinClient) Eval(ctx context.Context, in *Query, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Result], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Bremlin_ServiceDesc.Streams[0], Bremlin_Eval_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[Query, Result]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Bremlin_EvalClient = grpc.ServerStreamingClient[Result]
+
+// BremlinServer is the server API for Bremlin service.
+// All implementations must embed UnimplementedBremlinServer
+// for forward compatibility.
+type BremlinServer interface {
+	// Returns the steps of the command.
+	Parse(context.Context, *Query) (*Plan, error)
+	// Checks the command against the schema of the server.
+	Validate(context.Context, *Query) (*Validation, error)
+	// Returns the steps of the command and the plan they are evaluated with.
+	Explain(context.Context, *Query) (*Plan, error)
+	// Evaluates the command and streams the nodes it selects, in batches.
+	Eval(*Query, grpc.ServerStreamingServer[Result]) error
+	mustEmbedUnimplementedBremlinServer()
+}
+
+// UnimplementedBremlinServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedBremlinServer struct{}
+
+func (UnimplementedBremlinServer) Parse(context.Context, *Query) (*Plan, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Parse not implemented")
+}
+func (UnimplementedBremlinServer) Validate(context.Context, *Query) (*Validation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Validate not implemented")
+}
+func (UnimplementedBremlinServer) Explain(context.Context, *Query) (*Plan, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Explain not implemented")
+}
+func (UnimplementedBremlinServer) Eval(*Query, grpc.ServerStreamingServer[Result]) error {
+	return status.Errorf(codes.Unimplemented, "method Eval not implemented")
+}
+func (UnimplementedBremlinServer) mustEmbedUnimplementedBremlinServer() {}
+func (UnimplementedBremlinServer) testEmbeddedByValue()                 {}
+
+// UnsafeBremlinServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BremlinServer will
+// result in compilation errors.
+type UnsafeBremlinServer interface {
+	mustEmbedUnimplementedBremlinServer()
+}
+
+func RegisterBremlinServer(s grpc.ServiceRegistrar, srv BremlinServer) {
+	// If the following call pancis, it indicates UnimplementedBremlinServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Bremlin_ServiceDesc, srv)
+}
+
+func _Bremlin_Parse_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Query)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BremlinServer).Parse(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bremlin_Parse_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BremlinServer).Parse(ctx, req.(*Query))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Bremlin_Validate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Query)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BremlinServer).Validate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bremlin_Validate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BremlinServer).Validate(ctx, req.(*Query))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Bremlin_Explain_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Query)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BremlinServer).Explain(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bremlin_Explain_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BremlinServer).Explain(ctx, req.(*Query))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Bremlin_Eval_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(Query)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BremlinServer).Eval(m, &grpc.GenericServerStream[Query, Result]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Bremlin_EvalServer = grpc.ServerStreamingServer[Result]
+
+// Bremlin_ServiceDesc is the grpc.ServiceDesc for Bremlin service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Bremlin_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "bremlin.Bremlin",
+	HandlerType: (*BremlinServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Parse",
+			Handler:    _Bremlin_Parse_Handler,
+		},
+		{
+			MethodName: "Validate",
+			Handler:    _Bremlin_Validate_Handler,
+		},
+		{
+			MethodName: "Explain",
+			Handler:    _Bremlin_Explain_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Eval",
+			Handler:       _Bremlin_Eval_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "bremlin.proto",
+}
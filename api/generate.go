@@ -0,0 +1,5 @@
+// Package api holds the gRPC service of bremlin serve, generated from
+// bremlin.proto.
+package api
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative bremlin.proto
@@ -0,0 +1,506 @@
+package main
+
+import (
+	"bremlin/parser"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/k0kubun/pp"
+	"github.com/mattn/go-isatty"
+)
+
+// input is the source of one command, read from a file or stdin.
+type input struct {
+	name string
+	src  string
+}
+
+const stdinName = "<stdin>"
+
+// Reads the files, or stdin when there are none or a file is "-".
+func (e *env) inputs(paths []string) ([]input, error) {
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+	inputs := make([]input, 0, len(paths))
+	for _, p := range paths {
+		var b []byte
+		var err error
+		if p == "-" {
+			p = stdinName
+			b, err = io.ReadAll(e.stdin)
+		} else {
+			b, err = os.ReadFile(p)
+		}
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, input{name: p, src: string(b)})
+	}
+	return inputs, nil
+}
+
+// Reads the single command a subcommand works on.
+func (e *env) input(fs *flag.FlagSet) (input, int) {
+	if fs.NArg() > 1 {
+		fmt.Fprintf(e.stderr, "bremlin %s: expected at most one file got %d\n", fs.Name(), fs.NArg())
+		return input{}, exitUsage
+	}
+	inputs, err := e.inputs(fs.Args())
+	if err != nil {
+		fmt.Fprintf(e.stderr, "bremlin %s: %s\n", fs.Name(), err)
+		return input{}, exitFail
+	}
+	return inputs[0], exitOK
+}
+
+// Parses the command of an input, reporting errors to stderr.
+func (e *env) parse(in input) ([]parser.Step, bool) {
+	chain, err := parser.ParseCommand(in.src)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "%s: %s\n", in.name, err)
+		return nil, false
+	}
+	return chain, true
+}
+
+// unit is a command to work on: the command of a command file or a rule of
+// a rule file.
+type unit struct {
+	name  string
+	chain []parser.Step
+}
+
+// Parses an input into the commands it holds, reporting errors to stderr.
+func (e *env) units(in input) ([]unit, bool) {
+	if !parser.IsRuleSet(in.src) {
+		chain, ok := e.parse(in)
+		return []unit{{in.name, chain}}, ok
+	}
+	rs, err := parser.ParseRuleSet(in.src)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "%s: %s\n", in.name, err)
+		return nil, false
+	}
+	units := make([]unit, len(rs.Rules))
+	for i, r := range rs.Rules {
+		units[i] = unit{in.name + ":" + r.Name, r.Chain}
+	}
+	return units, true
+}
+
+// Parses the single command a subcommand works on: the command of a command
+// file, the rule of a rule file with just one rule or the named rule.
+func (e *env) unit(fs *flag.FlagSet, rule string) (unit, int) {
+	in, code := e.input(fs)
+	if code != exitOK {
+		return unit{}, code
+	}
+	units, ok := e.units(in)
+	if !ok {
+		return unit{}, exitFail
+	}
+	return e.pick(fs, in, units, rule)
+}
+
+// Picks the named rule from the units of an input, or the only one.
+func (e *env) pick(fs *flag.FlagSet, in input, units []unit, rule string) (unit, int) {
+	if rule == "" {
+		if len(units) != 1 {
+			fmt.Fprintf(e.stderr, "bremlin %s: %s has %d rules, choose one with -rule\n", fs.Name(), in.name, len(units))
+			return unit{}, exitUsage
+		}
+		return units[0], exitOK
+	}
+	for _, u := range units {
+		if u.name == in.name+":"+rule {
+			return u, exitOK
+		}
+	}
+	fmt.Fprintf(e.stderr, "bremlin %s: %s has no rule %s\n", fs.Name(), in.name, rule)
+	return unit{}, exitFail
+}
+
+func (e *env) flags(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: bremlin %s %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func runParse(e *env, args []string) int {
+	fs := e.flags("parse", "[-json] [FILE ...]")
+	asJSON := fs.Bool("json", false, "print the steps as JSON")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	inputs, err := e.inputs(fs.Args())
+	if err != nil {
+		fmt.Fprintf(e.stderr, "bremlin parse: %s\n", err)
+		return exitFail
+	}
+
+	if f, ok := e.stdout.(*os.File); !ok || !isatty.IsTerminal(f.Fd()) {
+		pp.ColoringEnabled = false
+	}
+	code := exitOK
+	for _, in := range inputs {
+		units, ok := e.units(in)
+		if !ok {
+			code = exitFail
+			continue
+		}
+		for _, u := range units {
+			if len(units) > 1 || u.name != in.name {
+				fmt.Fprintf(e.stdout, "// %s\n", u.name)
+			}
+			if !*asJSON {
+				pp.Fprintln(e.stdout, u.chain)
+				continue
+			}
+			b, err := parser.MarshalChain(u.chain)
+			if err == nil {
+				err = printJSON(e.stdout, b)
+			}
+			if err != nil {
+				fmt.Fprintf(e.stderr, "%s: %s\n", u.name, err)
+				code = exitFail
+			}
+		}
+	}
+	return code
+}
+
+func printJSON(w io.Writer, b []byte) error {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func runFmt(e *env, args []string) int {
+	fs := e.flags("fmt", "[-w] [-l] [FILE ...]")
+	write := fs.Bool("w", false, "write the result to the file instead of stdout")
+	list := fs.Bool("l", false, "list the files whose formatting differs")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	inputs, err := e.inputs(fs.Args())
+	if err != nil {
+		fmt.Fprintf(e.stderr, "bremlin fmt: %s\n", err)
+		return exitFail
+	}
+
+	code := exitOK
+	for _, in := range inputs {
+		out, ok := e.format(in)
+		if !ok {
+			code = exitFail
+			continue
+		}
+		changed := out != in.src
+		if *list && changed {
+			fmt.Fprintln(e.stdout, in.name)
+		}
+		if *write && in.name != stdinName {
+			if changed {
+				if err := writeFile(in.name, out); err != nil {
+					fmt.Fprintf(e.stderr, "bremlin fmt: %s\n", err)
+					code = exitFail
+				}
+			}
+			continue
+		}
+		if !*list {
+			fmt.Fprint(e.stdout, out)
+		}
+	}
+	return code
+}
+
+// Replaces the contents of a file, keeping its permissions.
+func writeFile(path, src string) error {
+	st, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(src), st.Mode().Perm())
+}
+
+func runLint(e *env, args []string) int {
+	fs := e.flags("lint", "-schema FILE [FILE ...]")
+	schemaPath := fs.String("schema", "", "Turtle file with the ontology to validate against")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *schemaPath == "" {
+		fmt.Fprintln(e.stderr, "bremlin lint: -schema is required")
+		fs.Usage()
+		return exitUsage
+	}
+	schema, err := parser.LoadSchemaFile(*schemaPath)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "bremlin lint: %s\n", err)
+		return exitFail
+	}
+	inputs, err := e.inputs(fs.Args())
+	if err != nil {
+		fmt.Fprintf(e.stderr, "bremlin lint: %s\n", err)
+		return exitFail
+	}
+
+	code := exitOK
+	v := parser.NewValidator(schema)
+	for _, in := range inputs {
+		units, ok := e.units(in)
+		if !ok {
+			code = exitFail
+			continue
+		}
+		for _, u := range units {
+			diags := v.Validate(u.chain)
+			diags = append(diags, parser.InferTypes(u.chain, schema).Diagnostics...)
+			for _, d := range diags {
+				fmt.Fprintf(e.stdout, "%s: %s\n", u.name, d)
+				if d.Severity == parser.SeverityError {
+					code = exitFail
+				}
+			}
+		}
+	}
+	return code
+}
+
+func runCompile(e *env, args []string) int {
+	fs := e.flags("compile", "[-format json|binary] [-dict FILE] [-rule NAME] [FILE]")
+	format := fs.String("format", "json", "json for a self-contained plan, binary for the compact encoding")
+	dict := fs.String("dict", "", "internalizer log to take the iids from, created if it does not exist")
+	rule := fs.String("rule", "", "rule of a rule file to compile, all of them by default when compiling to JSON")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *format != "json" && *format != "binary" {
+		fmt.Fprintf(e.stderr, "bremlin compile: unknown format %s\n", *format)
+		return exitUsage
+	}
+	in, code := e.input(fs)
+	if code != exitOK {
+		return code
+	}
+	units, ok := e.units(in)
+	if !ok {
+		return exitFail
+	}
+	// A rule file compiles to an object with a plan for every rule.
+	all := *format == "json" && *rule == "" && parser.IsRuleSet(in.src)
+	if !all {
+		u, code := e.pick(fs, in, units, *rule)
+		if code != exitOK {
+			return code
+		}
+		units = []unit{u}
+	}
+
+	var is parser.Internalizer = parser.NewSyncInternalizer()
+	if *dict != "" {
+		fi, err := parser.OpenFileInternalizer(*dict)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "bremlin compile: %s\n", err)
+			return exitFail
+		}
+		defer fi.Close()
+		is = fi
+	}
+
+	plans := make([]*parser.Plan, len(units))
+	for i, u := range units {
+		steps, err := parser.InternalizeSteps(u.chain, is)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "%s: %s\n", u.name, err)
+			return exitFail
+		}
+
+		if *format == "binary" {
+			if _, err := e.stdout.Write(parser.EncodeSteps(steps)); err != nil {
+				fmt.Fprintf(e.stderr, "bremlin compile: %s\n", err)
+				return exitFail
+			}
+			return exitOK
+		}
+		plan, err := parser.NewPlan(steps, is)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "%s: %s\n", u.name, err)
+			return exitFail
+		}
+		plans[i] = plan
+	}
+
+	var b []byte
+	var err error
+	if all {
+		byRule := make(map[string]*parser.Plan, len(plans))
+		for i, u := range units {
+			byRule[strings.TrimPrefix(u.name, in.name+":")] = plans[i]
+		}
+		b, err = json.Marshal(byRule)
+	} else {
+		b, err = plans[0].MarshalJSON()
+	}
+	if err == nil {
+		err = printJSON(e.stdout, b)
+	}
+	if err != nil {
+		fmt.Fprintf(e.stderr, "bremlin compile: %s\n", err)
+		return exitFail
+	}
+	return exitOK
+}
+
+// Formats a command file or a rule file.
+func (e *env) format(in input) (string, bool) {
+	if !parser.IsRuleSet(in.src) {
+		chain, ok := e.parse(in)
+		if !ok {
+			return "", false
+		}
+		return parser.FormatChain(chain), true
+	}
+	rs, err := parser.ParseRuleSet(in.src)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "%s: %s\n", in.name, err)
+		return "", false
+	}
+	return parser.FormatRuleSet(rs), true
+}
+
+// fileList is a flag that can be given several times.
+type fileList []string
+
+func (l *fileList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *fileList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
+func runEval(e *env, args []string) int {
+	fs := e.flags("eval", "-data FILE [-data FILE ...] [-rule NAME] [FILE]")
+	var data fileList
+	fs.Var(&data, "data", "Turtle or N-Triples file to evaluate against, can be repeated")
+	rule := fs.String("rule", "", "rule of a rule file to evaluate")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if len(data) == 0 {
+		fmt.Fprintln(e.stderr, "bremlin eval: -data is required")
+		fs.Usage()
+		return exitUsage
+	}
+	u, code := e.unit(fs, *rule)
+	if code != exitOK {
+		return code
+	}
+
+	is, g, err := loadGraph(data)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "bremlin eval: %s\n", err)
+		return exitFail
+	}
+
+	steps, _, err := parser.InternalizeStepsReadOnly(u.chain, is)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "%s: %s\n", u.name, err)
+		return exitFail
+	}
+	nodes, err := parser.Evaluate(steps, g, is)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "%s: %s\n", u.name, err)
+		return exitFail
+	}
+	for _, n := range nodes {
+		s, _ := is.GetString(n)
+		fmt.Fprintln(e.stdout, s)
+	}
+	return exitOK
+}
+
+// Loads Turtle or N-Triples files into a graph.
+func loadGraph(paths []string) (*parser.SyncInternalizer, *parser.MemGraph, error) {
+	is := parser.NewSyncInternalizer()
+	g := parser.NewMemGraph(nil, is)
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		triples, err := parser.ReadTriples(f)
+		f.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, t := range triples {
+			g.Add(t)
+		}
+	}
+	return is, g, nil
+}
+
+func runTranslate(e *env, args []string) int {
+	fs := e.flags("translate", "[-to sparql|cypher|gremlin|gremlin-bytecode] [-rule NAME] [FILE]")
+	to := fs.String("to", "sparql", "query language to translate to: sparql, cypher, gremlin or gremlin-bytecode")
+	rule := fs.String("rule", "", "rule of a rule file to translate")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	var translate func([]parser.Step) (string, error)
+	switch *to {
+	case "sparql":
+		translate = func(chain []parser.Step) (string, error) {
+			return parser.ToSPARQL(chain, parser.DefaultPrefixes())
+		}
+	case "cypher":
+		translate = func(chain []parser.Step) (string, error) {
+			return parser.ToCypher(chain, parser.DefaultGraphMapping())
+		}
+	case "gremlin":
+		translate = func(chain []parser.Step) (string, error) {
+			return parser.ToGremlin(chain, parser.DefaultGraphMapping())
+		}
+	case "gremlin-bytecode":
+		translate = func(chain []parser.Step) (string, error) {
+			return parser.ToGremlinBytecode(chain, parser.DefaultGraphMapping())
+		}
+	default:
+		fmt.Fprintf(e.stderr, "bremlin translate: unknown language %s\n", *to)
+		return exitUsage
+	}
+
+	u, code := e.unit(fs, *rule)
+	if code != exitOK {
+		return code
+	}
+	q, err := translate(u.chain)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "%s: %s\n", u.name, err)
+		return exitFail
+	}
+	fmt.Fprint(e.stdout, q)
+	if !strings.HasSuffix(q, "\n") {
+		fmt.Fprintln(e.stdout)
+	}
+	return exitOK
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Runs the tool with the arguments and stdin and returns the exit code,
+// stdout and stderr.
+func runCmd(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(&env{strings.NewReader(stdin), &stdout, &stderr}, args)
+	return code, stdout.String(), stderr.String()
+}
+
+func writeTemp(t *testing.T, name, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunUsage(t *testing.T) {
+	if code, _, _ := runCmd(t, ""); code != exitUsage {
+		t.Errorf("Expected exit code %d got %d", exitUsage, code)
+	}
+	if code, _, stderr := runCmd(t, "", "frobnicate"); code != exitUsage || !strings.Contains(stderr, "unknown command") {
+		t.Errorf("Expected unknown command got %d %q", code, stderr)
+	}
+	if code, stdout, _ := runCmd(t, "", "help"); code != exitOK || !strings.Contains(stdout, "translate") {
+		t.Errorf("Expected usage got %d %q", code, stdout)
+	}
+	if code, _, _ := runCmd(t, "", "parse", "-bogus"); code != exitUsage {
+		t.Errorf("Expected exit code %d got %d", exitUsage, code)
+	}
+}
+
+func TestRunParse(t *testing.T) {
+	code, stdout, _ := runCmd(t, `Start[iri].HasType[ex:Gremlin].Eval`, "parse", "-json")
+	if code != exitOK || !strings.Contains(stdout, `"arg": "ex:Gremlin"`) {
+		t.Errorf("Unexpected output %d %q", code, stdout)
+	}
+
+	code, stdout, _ = runCmd(t, `Start[iri].HasType[ex:Gremlin].Eval`, "parse")
+	if code != exitOK || !strings.Contains(stdout, `"HasType"`) {
+		t.Errorf("Unexpected output %d %q", code, stdout)
+	}
+
+	bad := writeTemp(t, "bad.brm", `Start[iri].HasType[].Eval`)
+	code, _, stderr := runCmd(t, "", "parse", bad)
+	if code != exitFail || !strings.HasPrefix(stderr, bad+": ") {
+		t.Errorf("Expected parse error got %d %q", code, stderr)
+	}
+}
+
+func TestRunFmt(t *testing.T) {
+	path := writeTemp(t, "cmd.brm", `Start[iri].Or(HasType[ex:Gremlin].HasType[ex:GooGrok]).Eval`)
+	expected := "Start[iri]\n.Or(\n\tHasType[ex:Gremlin]\n\t.HasType[ex:GooGrok]\n)\n.Eval\n"
+
+	code, stdout, _ := runCmd(t, "", "fmt", "-l", path)
+	if code != exitOK || stdout != path+"\n" {
+		t.Errorf("Expected %s to be listed got %q", path, stdout)
+	}
+
+	code, stdout, _ = runCmd(t, "", "fmt", "-w", path)
+	if code != exitOK || stdout != "" {
+		t.Errorf("Unexpected output %d %q", code, stdout)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, b)
+	}
+
+	code, stdout, _ = runCmd(t, "", "fmt", "-l", path)
+	if code != exitOK || stdout != "" {
+		t.Errorf("Expected no files to be listed got %q", stdout)
+	}
+}
+
+func TestRunLint(t *testing.T) {
+	schema := "parser/testdata/animals.ttl"
+	code, stdout, _ := runCmd(t, `Start[iri].HasType[ex:Gremlin].Follow[ex:Eats].Eval`, "lint", "-schema", schema)
+	if code != exitOK || stdout != "" {
+		t.Errorf("Expected no diagnostics got %d %q", code, stdout)
+	}
+
+	code, stdout, _ = runCmd(t, `Start[iri].HasType[ex:Hobbit].Eval`, "lint", "-schema", schema)
+	if code != exitFail || !strings.Contains(stdout, "<stdin>: error: step 1 (HasType): unknown class ex:Hobbit") {
+		t.Errorf("Expected an error got %d %q", code, stdout)
+	}
+
+	if code, _, _ := runCmd(t, "", "lint"); code != exitUsage {
+		t.Errorf("Expected exit code %d without a schema got %d", exitUsage, code)
+	}
+}
+
+func TestRunCompile(t *testing.T) {
+	cmd := `Start[iri].HasType[ex:Gremlin].Eval`
+	code, stdout, _ := runCmd(t, cmd, "compile")
+	if code != exitOK || !strings.Contains(stdout, `"ex:Gremlin"`) || !strings.Contains(stdout, `"dictionary"`) {
+		t.Errorf("Unexpected output %d %q", code, stdout)
+	}
+
+	dict := filepath.Join(t.TempDir(), "iids.log")
+	code, stdout, _ = runCmd(t, cmd, "compile", "-format", "binary", "-dict", dict)
+	if code != exitOK || !strings.HasPrefix(stdout, "BRMP") {
+		t.Errorf("Unexpected output %d %q", code, stdout)
+	}
+	if _, err := os.Stat(dict); err != nil {
+		t.Errorf("Expected the dictionary to be created: %v", err)
+	}
+
+	if code, _, _ := runCmd(t, cmd, "compile", "-format", "xml"); code != exitUsage {
+		t.Errorf("Expected exit code %d for an unknown format got %d", exitUsage, code)
+	}
+	if code, _, _ := runCmd(t, cmd, "compile", "a.brm", "b.brm"); code != exitUsage {
+		t.Errorf("Expected exit code %d for two files got %d", exitUsage, code)
+	}
+}
+
+func TestRunEval(t *testing.T) {
+	code, stdout, _ := runCmd(t, `Start[iri].HasType[ex:Gremlin].Eval`, "eval", "-data", "parser/testdata/zoo.ttl")
+	if code != exitOK || stdout != "ex:gizmo\nex:stripe\n" {
+		t.Errorf("Unexpected output %d %q", code, stdout)
+	}
+
+	if code, _, _ := runCmd(t, "", "eval"); code != exitUsage {
+		t.Errorf("Expected exit code %d without data got %d", exitUsage, code)
+	}
+	if code, _, _ := runCmd(t, `Start[iri].Eval`, "eval", "-data", "missing.ttl"); code != exitFail {
+		t.Errorf("Expected exit code %d for missing data got %d", exitFail, code)
+	}
+}
+
+func TestRunTranslate(t *testing.T) {
+	cmd := `Start[iri].HasType[ex:Gremlin].Eval`
+	for _, to := range []string{"sparql", "cypher", "gremlin", "gremlin-bytecode"} {
+		code, stdout, stderr := runCmd(t, cmd, "translate", "-to", to)
+		if code != exitOK || !strings.Contains(stdout, "Gremlin") {
+			t.Errorf("%s: unexpected output %d %q %q", to, code, stdout, stderr)
+		}
+	}
+	if code, _, _ := runCmd(t, cmd, "translate", "-to", "sql"); code != exitUsage {
+		t.Errorf("Expected exit code %d for an unknown language got %d", exitUsage, code)
+	}
+}
+
+func TestRunRuleFile(t *testing.T) {
+	rules := "parser/testdata/rules/monsters.brm"
+	code, stdout, stderr := runCmd(t, "", "eval", "-data", "parser/testdata/zoo.ttl", "-rule", "ActivePredators", rules)
+	if code != exitOK || stdout != "ex:gizmo\nex:blob\n" {
+		t.Errorf("Unexpected output %d %q %q", code, stdout, stderr)
+	}
+
+	if code, _, _ := runCmd(t, "", "eval", "-data", "parser/testdata/zoo.ttl", rules); code != exitUsage {
+		t.Errorf("Expected exit code %d without -rule got %d", exitUsage, code)
+	}
+	if code, _, _ := runCmd(t, "", "translate", "-rule", "Hobbits", rules); code != exitFail {
+		t.Errorf("Expected exit code %d for an unknown rule got %d", exitFail, code)
+	}
+
+	code, stdout, _ = runCmd(t, "", "compile", rules)
+	for _, name := range []string{`"HungryMonsters"`, `"ActivePredators"`, `"Everything"`} {
+		if code != exitOK || !strings.Contains(stdout, name) {
+			t.Errorf("Expected a plan for %s got %d %q", name, code, stdout)
+		}
+	}
+
+	code, stdout, _ = runCmd(t, "", "parse", rules)
+	if code != exitOK || !strings.Contains(stdout, "ActivePredators") {
+		t.Errorf("Unexpected output %d %q", code, stdout)
+	}
+
+	code, stdout, _ = runCmd(t, "", "fmt", rules)
+	if code != exitOK || !strings.Contains(stdout, "rule HungryMonsters {\n\tStart[iri]\n") || !strings.Contains(stdout, "// either kind") {
+		t.Errorf("Unexpected output %d %q", code, stdout)
+	}
+}
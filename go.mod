@@ -1,12 +1,21 @@
 module bremlin
 
-go 1.22.4
+go 1.23.0
 
-require github.com/k0kubun/pp v3.0.1+incompatible
+require (
+	github.com/k0kubun/pp v3.0.1+incompatible
+	github.com/mattn/go-isatty v0.0.16
+	github.com/peterh/liner v1.2.2
+	google.golang.org/grpc v1.70.0
+	google.golang.org/protobuf v1.35.2
+)
 
 require (
 	github.com/k0kubun/colorstring v0.0.0-20150214042306-9440f1994b88 // indirect
 	github.com/mattn/go-colorable v0.1.13 // indirect
-	github.com/mattn/go-isatty v0.0.16 // indirect
-	golang.org/x/sys v0.4.0 // indirect
+	github.com/mattn/go-runewidth v0.0.3 // indirect
+	golang.org/x/net v0.32.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20241202173237-19429a94021a // indirect
 )
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bremlin/api"
+	"bremlin/parser"
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Nodes sent in a single Result of Eval.
+const resultBatch = 1000
+
+// grpcServer answers the service of api/bremlin.proto with the graph, schema
+// and timeout of the HTTP server.
+type grpcServer struct {
+	api.UnimplementedBremlinServer
+	s *server
+}
+
+func (s *server) grpcServer() *grpc.Server {
+	gs := grpc.NewServer()
+	api.RegisterBremlinServer(gs, &grpcServer{s: s})
+	return gs
+}
+
+// Serves gRPC on the listener until the context is done, then stops
+// accepting connections and waits for the calls in flight.
+func serveGRPCUntil(ctx context.Context, ln net.Listener, gs *grpc.Server) error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- gs.Serve(ln)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	stopped := make(chan struct{})
+	go func() {
+		gs.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(shutdownTimeout):
+		gs.Stop()
+	}
+	return <-errc
+}
+
+// Parses the query and runs f on its chain.
+func call[T any](s *server, q *api.Query, f func(chain []parser.Step) (T, error)) (T, error) {
+	var result T
+	chain, err := parseRequest(q.Command, q.Rule)
+	if err == nil {
+		result, err = f(chain)
+	}
+	if err != nil {
+		return result, s.grpcError(err)
+	}
+	return result, nil
+}
+
+// Returns the status an error is answered with.
+func (s *server) grpcError(err error) error {
+	var bad *badInput
+	var limit *parser.LimitExceeded
+	switch {
+	case errors.As(err, &bad):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &limit):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	}
+	s.log.Printf("grpc: %s", err)
+	return status.Error(codes.Internal, err.Error())
+}
+
+func (g *grpcServer) Parse(ctx context.Context, q *api.Query) (*api.Plan, error) {
+	return call(g.s, q, func(chain []parser.Step) (*api.Plan, error) {
+		return &api.Plan{Steps: apiSteps(chain)}, nil
+	})
+}
+
+func (g *grpcServer) Validate(ctx context.Context, q *api.Query) (*api.Validation, error) {
+	return call(g.s, q, func(chain []parser.Step) (*api.Validation, error) {
+		found, valid := g.s.check(chain)
+		diags := make([]*api.Diagnostic, len(found))
+		for i, d := range found {
+			diags[i] = apiDiagnostic(d)
+		}
+		return &api.Validation{Valid: valid, Diagnostics: diags}, nil
+	})
+}
+
+func (g *grpcServer) Explain(ctx context.Context, q *api.Query) (*api.Plan, error) {
+	return call(g.s, q, func(chain []parser.Step) (*api.Plan, error) {
+		plan, unknown, err := g.s.plan(chain)
+		if err != nil {
+			return nil, err
+		}
+		return &api.Plan{Steps: apiSteps(chain), Explain: plan, Unknown: unknown}, nil
+	})
+}
+
+// Evaluates the command and sends the nodes it selects in batches of
+// resultBatch as they are found, so that large results are neither held
+// in memory nor sent in one message.
+func (g *grpcServer) Eval(q *api.Query, stream api.Bremlin_EvalServer) error {
+	ctx, cancel := g.s.withTimeout(stream.Context())
+	defer cancel()
+	it, err := call(g.s, q, func(chain []parser.Step) (parser.NodeIterator, error) {
+		return g.s.iterate(ctx, chain)
+	})
+	if err != nil {
+		return err
+	}
+
+	batch := make([]string, 0, resultBatch)
+	send := func() error {
+		err := stream.Send(&api.Result{Nodes: batch})
+		batch = make([]string, 0, resultBatch)
+		return err
+	}
+	for n := range parser.All(it) {
+		iri, _ := g.s.is.GetString(n)
+		batch = append(batch, iri)
+		if len(batch) == resultBatch {
+			if err := send(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := it.Err(); err != nil {
+		return g.s.grpcError(evalError(err))
+	}
+	if len(batch) > 0 {
+		return send()
+	}
+	return nil
+}
+
+func apiSteps(chain []parser.Step) []*api.Step {
+	steps := make([]*api.Step, len(chain))
+	for i, s := range chain {
+		steps[i] = &api.Step{
+			Token:  s.Token(),
+			Arg:    s.Arg(),
+			Vals:   s.Vals(),
+			Subcmd: apiSteps(s.Subcmd()),
+		}
+	}
+	return steps
+}
+
+func apiDiagnostic(d parser.Diagnostic) *api.Diagnostic {
+	severity := api.Diagnostic_SEVERITY_UNSPECIFIED
+	switch d.Severity {
+	case parser.SeverityError:
+		severity = api.Diagnostic_ERROR
+	case parser.SeverityWarning:
+		severity = api.Diagnostic_WARNING
+	}
+	path := make([]int32, len(d.Path))
+	for i, p := range d.Path {
+		path[i] = int32(p)
+	}
+	return &api.Diagnostic{Severity: severity, Path: path, Step: d.Token, Message: d.Message}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"bremlin/api"
+	"bremlin/parser"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Returns a client of the server connected in process through a bufconn
+// listener served until ctx is done.
+func dialBufconn(t *testing.T, ctx context.Context, s *server) (api.BremlinClient, chan error) {
+	t.Helper()
+	ln := bufconn.Listen(1 << 20)
+	done := make(chan error, 1)
+	go func() {
+		done <- serveGRPCUntil(ctx, ln, s.grpcServer())
+	}()
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return api.NewBremlinClient(conn), done
+}
+
+func newGRPCClient(t *testing.T, s *server) api.BremlinClient {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	c, _ := dialBufconn(t, ctx, s)
+	return c
+}
+
+// Calls Eval and collects the streamed batches.
+func evalAll(c api.BremlinClient, q *api.Query) ([][]string, error) {
+	stream, err := c.Eval(context.Background(), q)
+	if err != nil {
+		return nil, err
+	}
+	var batches [][]string
+	for {
+		r, err := stream.Recv()
+		if err == io.EOF {
+			return batches, nil
+		}
+		if err != nil {
+			return batches, err
+		}
+		batches = append(batches, r.Nodes)
+	}
+}
+
+func TestGRPCService(t *testing.T) {
+	c := newGRPCClient(t, newTestServer(t))
+	ctx := context.Background()
+	cmd := &api.Query{Command: `Start[iri].Or(HasType[ex:Gremlin].HasType[ex:GooGrok]).Eval`}
+
+	plan, err := c.Parse(ctx, cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Steps) != 3 || plan.Steps[1].Token != "Or" || len(plan.Steps[1].Subcmd) != 2 || plan.Steps[1].Subcmd[0].Arg != "ex:Gremlin" {
+		t.Errorf("Unexpected steps %v", plan.Steps)
+	}
+
+	v, err := c.Validate(ctx, &api.Query{Command: `Start[iri].HasType[ex:Hobbit].Eval`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Valid || len(v.Diagnostics) == 0 || v.Diagnostics[0].Severity != api.Diagnostic_ERROR || v.Diagnostics[0].Step != "HasType" {
+		t.Errorf("Unexpected validation %v", v)
+	}
+	if v, err := c.Validate(ctx, cmd); err != nil || !v.Valid {
+		t.Errorf("Expected a valid command got %v %v", v, err)
+	}
+
+	plan, err = c.Explain(ctx, &api.Query{Command: `Start[iri].HasType[ex:Gremlin].HasType[ex:Hobbit].Eval`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(plan.Explain, "HasType ex:Gremlin") || len(plan.Unknown) != 1 || len(plan.Steps) != 4 {
+		t.Errorf("Unexpected plan %v", plan)
+	}
+
+	batches, err := evalAll(c, cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 1 || strings.Join(batches[0], " ") != "ex:gizmo ex:stripe ex:blob" {
+		t.Errorf("Unexpected nodes %v", batches)
+	}
+}
+
+func TestGRPCEvalBatches(t *testing.T) {
+	var sb strings.Builder
+	sb.WriteString("@prefix ex: <http://example.org/> .\n")
+	n := 2*resultBatch + 1
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "ex:g%d a ex:Gremlin .\n", i)
+	}
+	data := filepath.Join(t.TempDir(), "many.ttl")
+	if err := os.WriteFile(data, []byte(sb.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestServer(t)
+	var err error
+	if s.is, s.graph, err = loadGraph([]string{data}); err != nil {
+		t.Fatal(err)
+	}
+
+	batches, err := evalAll(newGRPCClient(t, s), &api.Query{Command: `Start[iri].HasType[ex:Gremlin].Eval`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 3 || len(batches[0]) != resultBatch || len(batches[2]) != 1 {
+		t.Errorf("Expected 3 batches of up to %d nodes got %d", resultBatch, len(batches))
+	}
+}
+
+func TestGRPCErrors(t *testing.T) {
+	c := newGRPCClient(t, newTestServer(t))
+	ctx := context.Background()
+
+	_, err := c.Parse(ctx, &api.Query{Command: `Start[iri].Bogus[].Eval`})
+	if status.Code(err) != codes.InvalidArgument || !strings.Contains(err.Error(), "unknown step Bogus") {
+		t.Errorf("Expected InvalidArgument got %v", err)
+	}
+
+	rules := "rule A { Start[iri].HasType[ex:Gremlin].Eval }\nrule B { Start[iri].HasType[ex:GooGrok].Eval }"
+	_, err = evalAll(c, &api.Query{Command: rules})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument got %v", err)
+	}
+	batches, err := evalAll(c, &api.Query{Command: rules, Rule: "B"})
+	if err != nil || len(batches) != 1 || len(batches[0]) != 1 {
+		t.Errorf("Unexpected nodes %v %v", batches, err)
+	}
+
+	s := newTestServer(t)
+	s.timeout = time.Nanosecond
+	_, err = evalAll(newGRPCClient(t, s), &api.Query{Command: `Start[iri].Eval`})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded got %v", err)
+	}
+
+	s = newTestServer(t)
+	s.limits = parser.Limits{Calls: 1}
+	_, err = evalAll(newGRPCClient(t, s), &api.Query{Command: `Start[iri].HasType[ex:Gremlin].Eval`})
+	if status.Code(err) != codes.ResourceExhausted || !strings.Contains(err.Error(), "backend calls") {
+		t.Errorf("Expected ResourceExhausted got %v", err)
+	}
+}
+
+func TestServeGRPCUntil(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c, done := dialBufconn(t, ctx, newTestServer(t))
+
+	if _, err := c.Parse(context.Background(), &api.Query{Command: `Start[iri].Eval`}); err != nil {
+		t.Errorf("Expected an answer got %v", err)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the server to shut down")
+	}
+}
+
+func TestGRPCErrorCodes(t *testing.T) {
+	s := newTestServer(t)
+	tests := []struct {
+		err      error
+		expected codes.Code
+	}{
+		{&badInput{fmt.Errorf("bad")}, codes.InvalidArgument},
+		{fmt.Errorf("eval: %w", &badInput{fmt.Errorf("bad")}), codes.InvalidArgument},
+		{&parser.LimitExceeded{}, codes.ResourceExhausted},
+		{context.DeadlineExceeded, codes.DeadlineExceeded},
+		{context.Canceled, codes.Canceled},
+		{fmt.Errorf("broken"), codes.Internal},
+	}
+	for _, test := range tests {
+		if got := status.Code(s.grpcError(test.err)); got != test.expected {
+			t.Errorf("%v: expected %s got %s", test.err, test.expected, got)
+		}
+	}
+}
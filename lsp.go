@@ -0,0 +1,644 @@
+package main
+
+import (
+	"bremlin/parser"
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// lspServer is a Language Server Protocol server for commands and rule
+// files, speaking JSON-RPC over a pair of streams.
+type lspServer struct {
+	in       *bufio.Reader
+	out      io.Writer
+	schema   *parser.Schema // nil when commands are not checked against a schema
+	docs     map[string]*document
+	shutdown bool
+}
+
+// document is an open text document and what could be parsed from it.
+type document struct {
+	text     string
+	ruleFile bool
+	chain    []parser.Step   // the steps of a command file
+	rules    *parser.RuleSet // the rules of a rule file
+	err      error           // the parse error, if any
+
+	// The last rules of the document that parsed, to complete fragments and
+	// prefixes while it is being edited.
+	known *parser.RuleSet
+}
+
+func runLsp(e *env, args []string) int {
+	fs := e.flags("lsp", "[-schema FILE]")
+	schemaPath := fs.String("schema", "", "Turtle file with the ontology to check commands against")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	s := newLspServer(e.stdin, e.stdout)
+	if *schemaPath != "" {
+		schema, err := parser.LoadSchemaFile(*schemaPath)
+		if err != nil {
+			fmt.Fprintf(e.stderr, "bremlin lsp: %s\n", err)
+			return exitFail
+		}
+		s.schema = schema
+	}
+	if err := s.serve(); err != nil {
+		fmt.Fprintf(e.stderr, "bremlin lsp: %s\n", err)
+		return exitFail
+	}
+	return exitOK
+}
+
+func newLspServer(in io.Reader, out io.Writer) *lspServer {
+	return &lspServer{
+		in:   bufio.NewReader(in),
+		out:  out,
+		docs: make(map[string]*document),
+	}
+}
+
+// JSON-RPC error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidParams  = -32602
+	rpcMethodNotFound = -32601
+)
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+var errNoShutdown = errors.New("exit before shutdown")
+
+// Handles messages until the client sends exit or closes the input.
+func (s *lspServer) serve() error {
+	for {
+		b, err := s.read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req rpcRequest
+		if err := json.Unmarshal(b, &req); err != nil {
+			s.reply(nil, nil, &rpcError{rpcParseError, err.Error()})
+			continue
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return errNoShutdown
+			}
+			return nil
+		}
+		result, rerr := s.handle(req.Method, req.Params)
+		// Requests have an id, notifications are not answered.
+		if req.ID != nil {
+			s.reply(req.ID, result, rerr)
+		}
+	}
+}
+
+// Reads the content of a message.
+func (s *lspServer) read() ([]byte, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	n, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(s.in, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (s *lspServer) write(msg map[string]any) {
+	msg["jsonrpc"] = "2.0"
+	b, err := json.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(b), b)
+}
+
+func (s *lspServer) reply(id json.RawMessage, result any, err *rpcError) {
+	msg := map[string]any{"id": id}
+	if err != nil {
+		msg["error"] = err
+	} else {
+		msg["result"] = result
+	}
+	s.write(msg)
+}
+
+func (s *lspServer) notify(method string, params any) {
+	s.write(map[string]any{"method": method, "params": params})
+}
+
+// Protocol types, limited to the fields the server uses.
+type (
+	lspPosition struct {
+		Line      int `json:"line"`
+		Character int `json:"character"`
+	}
+	lspRange struct {
+		Start lspPosition `json:"start"`
+		End   lspPosition `json:"end"`
+	}
+	lspLocation struct {
+		URI   string   `json:"uri"`
+		Range lspRange `json:"range"`
+	}
+	lspDiagnostic struct {
+		Range    lspRange `json:"range"`
+		Severity int      `json:"severity"`
+		Source   string   `json:"source"`
+		Message  string   `json:"message"`
+	}
+	lspTextEdit struct {
+		Range   lspRange `json:"range"`
+		NewText string   `json:"newText"`
+	}
+	lspCompletionItem struct {
+		Label  string `json:"label"`
+		Kind   int    `json:"kind"`
+		Detail string `json:"detail,omitempty"`
+	}
+	lspDocumentParams struct {
+		TextDocument struct {
+			URI  string `json:"uri"`
+			Text string `json:"text"`
+		} `json:"textDocument"`
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+		Position lspPosition `json:"position"`
+	}
+)
+
+// Severities of diagnostics and kinds of completion items.
+const (
+	lspError   = 1
+	lspWarning = 2
+
+	lspKindFunction  = 3
+	lspKindModule    = 9
+	lspKindReference = 18
+)
+
+// Handles a request or notification and returns its result.
+func (s *lspServer) handle(method string, raw json.RawMessage) (any, *rpcError) {
+	var params lspDocumentParams
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, &rpcError{rpcInvalidParams, err.Error()}
+		}
+	}
+	uri := params.TextDocument.URI
+	doc := s.docs[uri]
+
+	switch method {
+	case "initialize":
+		return map[string]any{
+			"capabilities": map[string]any{
+				"textDocumentSync":           1, // full
+				"hoverProvider":              true,
+				"completionProvider":         map[string]any{"triggerCharacters": []string{".", "[", ":"}},
+				"definitionProvider":         true,
+				"documentFormattingProvider": true,
+			},
+			"serverInfo": map[string]any{"name": "bremlin"},
+		}, nil
+	case "initialized", "$/cancelRequest", "$/setTrace", "workspace/didChangeConfiguration":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		s.open(uri, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		if n := len(params.ContentChanges); n > 0 {
+			s.open(uri, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		delete(s.docs, uri)
+		s.notify("textDocument/publishDiagnostics", map[string]any{"uri": uri, "diagnostics": []lspDiagnostic{}})
+		return nil, nil
+	case "textDocument/hover":
+		if doc == nil {
+			return nil, nil
+		}
+		return s.hover(doc, params.Position), nil
+	case "textDocument/completion":
+		if doc == nil {
+			return []lspCompletionItem{}, nil
+		}
+		return s.complete(doc, params.Position), nil
+	case "textDocument/definition":
+		if doc == nil {
+			return nil, nil
+		}
+		return s.definition(uri, doc, params.Position), nil
+	case "textDocument/formatting":
+		if doc == nil {
+			return nil, nil
+		}
+		return s.format(doc), nil
+	}
+	return nil, &rpcError{rpcMethodNotFound, "method not found: " + method}
+}
+
+// Parses an opened or changed document and publishes its diagnostics.
+func (s *lspServer) open(uri, text string) {
+	doc := &document{text: text, ruleFile: parser.IsRuleSet(text)}
+	if doc.ruleFile {
+		doc.rules, doc.err = parser.ParseRuleSet(text)
+		doc.known = doc.rules
+		if prev, ok := s.docs[uri]; ok && doc.err != nil {
+			doc.known = prev.known
+		}
+	} else {
+		doc.chain, doc.err = parser.ParseCommand(text)
+	}
+	s.docs[uri] = doc
+	s.notify("textDocument/publishDiagnostics", map[string]any{"uri": uri, "diagnostics": s.diagnostics(doc)})
+}
+
+var (
+	errLine = regexp.MustCompile(`^line (\d+): `)
+	errStep = regexp.MustCompile(`(?:unknown step|failed to parse|unknown fragment) (\w+)`)
+)
+
+// Returns the problems of a document: its parse error, or what the schema
+// finds wrong with its steps.
+func (s *lspServer) diagnostics(doc *document) []lspDiagnostic {
+	diags := make([]lspDiagnostic, 0)
+	if doc.err != nil {
+		msg := doc.err.Error()
+		r := lineRange(doc.text, 0)
+		line := 0
+		if m := errLine.FindStringSubmatch(msg); m != nil {
+			line, _ = strconv.Atoi(m[1])
+			r = lineRange(doc.text, line-1)
+		}
+		if m := errStep.FindStringSubmatch(msg); m != nil {
+			if i, j, ok := parser.FindStep(doc.text, m[1], line); ok {
+				r = textRange(doc.text, i, j)
+			}
+		}
+		return append(diags, lspDiagnostic{r, lspError, "bremlin", msg})
+	}
+	if s.schema == nil {
+		return diags
+	}
+
+	check := func(chain []parser.Step, positions []parser.StepPos, fallback lspRange) {
+		found := parser.NewValidator(s.schema).Validate(chain)
+		found = append(found, parser.InferTypes(chain, s.schema).Diagnostics...)
+		for _, d := range found {
+			r := fallback
+			if p, ok := positionAt(positions, d.Path); ok {
+				r = textRange(doc.text, p.Start, p.End)
+			}
+			severity := lspError
+			if d.Severity == parser.SeverityWarning {
+				severity = lspWarning
+			}
+			diags = append(diags, lspDiagnostic{r, severity, "bremlin", fmt.Sprintf("%s: %s", d.Token, d.Message)})
+		}
+	}
+	if doc.rules == nil {
+		check(doc.chain, parser.StepPositions(doc.text, doc.chain), lineRange(doc.text, 0))
+		return diags
+	}
+	for _, r := range doc.rules.Rules {
+		positions := r.Positions
+		if usesFragments(positions) {
+			// The steps of the rule no longer match the source once the
+			// fragments are inserted.
+			positions = nil
+		}
+		check(r.Chain, positions, lineRange(doc.text, r.Line-1))
+	}
+	return diags
+}
+
+func usesFragments(positions []parser.StepPos) bool {
+	return slices.ContainsFunc(positions, func(p parser.StepPos) bool { return p.Token == "Use" })
+}
+
+func positionAt(positions []parser.StepPos, path []int) (parser.StepPos, bool) {
+	for _, p := range positions {
+		if slices.Equal(p.Path, path) {
+			return p, true
+		}
+	}
+	return parser.StepPos{}, false
+}
+
+// Returns the innermost step written around the offset, with the chain it
+// belongs to when its path can be used to look up inferred types.
+func (doc *document) stepAt(offset int) (parser.StepPos, []parser.Step, bool) {
+	var found parser.StepPos
+	var chain []parser.Step
+	ok := false
+	search := func(positions []parser.StepPos, c []parser.Step) {
+		for _, p := range positions {
+			if p.Start <= offset && offset < max(p.End, p.Start+len(p.Token)) {
+				found, chain, ok = p, c, true
+			}
+		}
+	}
+	switch {
+	case doc.rules != nil:
+		for _, f := range doc.rules.Fragments {
+			search(f.Positions, nil)
+		}
+		for _, r := range doc.rules.Rules {
+			c := r.Chain
+			if usesFragments(r.Positions) {
+				c = nil
+			}
+			search(r.Positions, c)
+		}
+	case doc.err == nil:
+		search(parser.StepPositions(doc.text, doc.chain), doc.chain)
+	}
+	return found, chain, ok
+}
+
+func (s *lspServer) hover(doc *document, pos lspPosition) any {
+	p, chain, ok := doc.stepAt(offsetOf(doc.text, pos))
+	if !ok {
+		return nil
+	}
+
+	var sb strings.Builder
+	if p.Token == "Use" {
+		name := useName(doc.text, p)
+		f, ok := doc.rules.Fragment(name)
+		if !ok {
+			return nil
+		}
+		fmt.Fprintf(&sb, "```\n%s\n```\nFragment defined on line %d.", fragmentSignature(f), f.Line)
+	} else {
+		d, ok := parser.LookupStep(p.Token)
+		if !ok {
+			return nil
+		}
+		fmt.Fprintf(&sb, "```\n%s\n```\n%s", stepSignature(d), arityText(d))
+		if s.schema != nil && chain != nil {
+			if st, ok := parser.InferTypes(chain, s.schema).At(p.Path); ok {
+				if st.Types == nil {
+					sb.WriteString("\n\nNothing is known about the types of the node after this step.")
+				} else {
+					fmt.Fprintf(&sb, "\n\nTypes after this step: %s", strings.Join(st.Types, ", "))
+				}
+			}
+		}
+	}
+	return map[string]any{
+		"contents": map[string]any{"kind": "markdown", "value": sb.String()},
+		"range":    textRange(doc.text, p.Start, p.Start+len(p.Token)),
+	}
+}
+
+// Returns the fragment a Use step names.
+func useName(text string, p parser.StepPos) string {
+	args := strings.TrimPrefix(text[p.Start:p.End], "Use")
+	args = strings.TrimSpace(strings.Trim(strings.TrimSpace(args), "[]"))
+	name, _, _ := strings.Cut(args, ",")
+	return strings.Trim(strings.TrimSpace(name), `"`)
+}
+
+func stepSignature(d parser.StepDef) string {
+	switch {
+	case d.Token == parser.Start:
+		return "Start[iri]"
+	case d.Token == parser.Eval:
+		return "Eval"
+	case d.Subcmd:
+		return d.Name() + "(step, ...)"
+	}
+	args := make([]string, 0, len(d.Args)+1)
+	for _, k := range d.Args {
+		args = append(args, argKindName(k))
+	}
+	if d.Rest != 0 {
+		args = append(args, argKindName(d.Rest)+", ...")
+	}
+	return d.Name() + "[" + strings.Join(args, ", ") + "]"
+}
+
+func argKindName(k parser.ArgKind) string {
+	if k == parser.LiteralArg {
+		return "literal"
+	}
+	return "iri"
+}
+
+func arityText(d parser.StepDef) string {
+	if d.Subcmd {
+		return "Takes alternative steps and keeps the nodes any of them match."
+	}
+	if d.Token == parser.Start || d.Token == parser.Eval {
+		return "Delimits a command."
+	}
+	switch min, max := d.Arity(); {
+	case max == -1:
+		return fmt.Sprintf("Takes %d or more arguments.", min)
+	case max == 0:
+		return "Takes no arguments."
+	case max == 1:
+		return "Takes 1 argument."
+	default:
+		return fmt.Sprintf("Takes %d arguments.", max)
+	}
+}
+
+func fragmentSignature(f *parser.Fragment) string {
+	if len(f.Params) == 0 {
+		return "def " + f.Name
+	}
+	return "def " + f.Name + "(" + strings.Join(f.Params, ", ") + ")"
+}
+
+// Returns the step names, prefixes and fragments that complete the word
+// before the position.
+func (s *lspServer) complete(doc *document, pos lspPosition) []lspCompletionItem {
+	offset := offsetOf(doc.text, pos)
+	start := offset
+	for start > 0 && isWordChar(rune(doc.text[start-1])) {
+		start--
+	}
+	word := doc.text[start:offset]
+
+	items := make([]lspCompletionItem, 0)
+	add := func(label string, kind int, detail string) {
+		if strings.HasPrefix(label, word) {
+			items = append(items, lspCompletionItem{label, kind, detail})
+		}
+	}
+
+	known := doc.known
+	if known == nil {
+		known = &parser.RuleSet{}
+	}
+	if doc.ruleFile && strings.HasSuffix(strings.TrimRight(doc.text[:start], " \t"), "Use[") {
+		for _, f := range known.Fragments {
+			add(f.Name, lspKindReference, fragmentSignature(&f))
+		}
+		return items
+	}
+
+	for _, name := range parser.StepNames() {
+		if d, ok := parser.LookupStep(name); ok {
+			add(name, lspKindFunction, stepSignature(d))
+		}
+	}
+	if doc.ruleFile {
+		add("Use", lspKindFunction, "Use[fragment, arg, ...]")
+	}
+	prefixes := parser.DefaultPrefixes()
+	for p, iri := range known.Prefixes {
+		prefixes[p] = iri
+	}
+	names := make([]string, 0, len(prefixes))
+	for p := range prefixes {
+		if p != "" {
+			names = append(names, p)
+		}
+	}
+	sort.Strings(names)
+	for _, p := range names {
+		add(p+":", lspKindModule, prefixes[p])
+	}
+	return items
+}
+
+// Returns the definition of the fragment used at the position.
+func (s *lspServer) definition(uri string, doc *document, pos lspPosition) any {
+	if doc.rules == nil {
+		return nil
+	}
+	p, _, ok := doc.stepAt(offsetOf(doc.text, pos))
+	if !ok || p.Token != "Use" {
+		return nil
+	}
+	f, ok := doc.rules.Fragment(useName(doc.text, p))
+	if !ok {
+		return nil
+	}
+	r := lineRange(doc.text, f.Line-1)
+	line := lineText(doc.text, f.Line-1)
+	if i := strings.Index(line, f.Name); i >= 0 {
+		start := offsetOf(doc.text, r.Start) + i
+		r = textRange(doc.text, start, start+len(f.Name))
+	}
+	return lspLocation{uri, r}
+}
+
+// Returns the edit that replaces the document with its canonical form, or no
+// edits when it does not parse.
+func (s *lspServer) format(doc *document) []lspTextEdit {
+	if doc.err != nil {
+		return []lspTextEdit{}
+	}
+	var out string
+	if doc.rules != nil {
+		out = parser.FormatRuleSet(doc.rules)
+	} else {
+		out = parser.FormatChain(doc.chain)
+	}
+	if out == doc.text {
+		return []lspTextEdit{}
+	}
+	return []lspTextEdit{{textRange(doc.text, 0, len(doc.text)), out}}
+}
+
+// Positions count lines from 0 and characters in UTF-16 code units.
+
+func offsetOf(text string, pos lspPosition) int {
+	offset := 0
+	for i := 0; i < pos.Line; i++ {
+		j := strings.IndexByte(text[offset:], '\n')
+		if j < 0 {
+			return len(text)
+		}
+		offset += j + 1
+	}
+	for n := 0; n < pos.Character && offset < len(text) && text[offset] != '\n'; {
+		r, size := utf8.DecodeRuneInString(text[offset:])
+		n += utf16Len(r)
+		offset += size
+	}
+	return offset
+}
+
+func positionOf(text string, offset int) lspPosition {
+	offset = min(offset, len(text))
+	line := strings.Count(text[:offset], "\n")
+	start := strings.LastIndexByte(text[:offset], '\n') + 1
+	n := 0
+	for _, r := range text[start:offset] {
+		n += utf16Len(r)
+	}
+	return lspPosition{line, n}
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func textRange(text string, start, end int) lspRange {
+	return lspRange{positionOf(text, start), positionOf(text, end)}
+}
+
+// Returns the range of the text of a line, without its indentation.
+func lineRange(text string, line int) lspRange {
+	start := offsetOf(text, lspPosition{line, 0})
+	l := lineText(text, line)
+	indent := len(l) - len(strings.TrimLeft(l, " \t"))
+	return textRange(text, start+indent, start+len(l))
+}
+
+func lineText(text string, line int) string {
+	start := offsetOf(text, lspPosition{line, 0})
+	l, _, _ := strings.Cut(text[start:], "\n")
+	return strings.TrimRight(l, "\r")
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+
+	"bremlin/parser"
+)
+
+// lspSession runs the server over the given messages and returns the
+// responses by id and the notifications in order.
+func lspSession(t *testing.T, schema *parser.Schema, msgs ...map[string]any) (map[int]json.RawMessage, []map[string]json.RawMessage) {
+	t.Helper()
+	var in bytes.Buffer
+	for _, m := range msgs {
+		m["jsonrpc"] = "2.0"
+		b, _ := json.Marshal(m)
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(b), b)
+	}
+	var out bytes.Buffer
+	s := newLspServer(&in, &out)
+	s.schema = schema
+	if err := s.serve(); err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(map[int]json.RawMessage)
+	notes := make([]map[string]json.RawMessage, 0)
+	r := bufio.NewReader(&out)
+	for {
+		header, err := textproto.NewReader(r).ReadMIMEHeader()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, _ := strconv.Atoi(header.Get("Content-Length"))
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			t.Fatal(err)
+		}
+		var msg map[string]json.RawMessage
+		if err := json.Unmarshal(b, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if id, ok := msg["id"]; ok {
+			var i int
+			json.Unmarshal(id, &i)
+			if e, ok := msg["error"]; ok {
+				results[i] = e
+			} else {
+				results[i] = msg["result"]
+			}
+		} else {
+			notes = append(notes, msg)
+		}
+	}
+	return results, notes
+}
+
+func didOpen(uri, text string) map[string]any {
+	return map[string]any{"method": "textDocument/didOpen", "params": map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": "bremlin", "version": 1, "text": text},
+	}}
+}
+
+func request(id int, method, uri string, line, char int) map[string]any {
+	return map[string]any{"id": id, "method": method, "params": map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": line, "character": char},
+	}}
+}
+
+func shutdown(id int) []map[string]any {
+	return []map[string]any{{"id": id, "method": "shutdown"}, {"method": "exit"}}
+}
+
+func TestLspLifecycle(t *testing.T) {
+	msgs := []map[string]any{
+		{"id": 1, "method": "initialize", "params": map[string]any{"capabilities": map[string]any{}}},
+		{"method": "initialized", "params": map[string]any{}},
+		{"id": 2, "method": "workspace/symbol", "params": map[string]any{}},
+	}
+	results, _ := lspSession(t, nil, append(msgs, shutdown(3)...)...)
+	if !strings.Contains(string(results[1]), `"hoverProvider":true`) {
+		t.Errorf("Expected capabilities got %s", results[1])
+	}
+	if !strings.Contains(string(results[2]), `"code":-32601`) {
+		t.Errorf("Expected method not found got %s", results[2])
+	}
+	if string(results[3]) != "null" {
+		t.Errorf("Expected null result for shutdown got %s", results[3])
+	}
+
+	s := newLspServer(strings.NewReader("Content-Length: 33\r\n\r\n{\"jsonrpc\":\"2.0\",\"method\":\"exit\"}"), io.Discard)
+	if err := s.serve(); err != errNoShutdown {
+		t.Errorf("Expected %v got %v", errNoShutdown, err)
+	}
+}
+
+func TestLspDiagnostics(t *testing.T) {
+	schema, err := parser.LoadSchemaFile("parser/testdata/animals.ttl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, notes := lspSession(t, schema,
+		didOpen("file:///bad.brm", "Start[iri]\n.Bogus[x]\n.Eval"),
+		didOpen("file:///lint.brm", "Start[iri]\n.HasType[ex:Hobbit]\n.Eval"),
+		didOpen("file:///good.brm", "Start[iri].HasType[ex:Gremlin].Eval"),
+	)
+	if len(notes) != 3 {
+		t.Fatalf("Expected 3 notifications got %d", len(notes))
+	}
+
+	type published struct {
+		URI         string          `json:"uri"`
+		Diagnostics []lspDiagnostic `json:"diagnostics"`
+	}
+	var p published
+	json.Unmarshal(notes[0]["params"], &p)
+	if len(p.Diagnostics) != 1 || p.Diagnostics[0].Range.Start != (lspPosition{1, 1}) || !strings.Contains(p.Diagnostics[0].Message, "unknown step Bogus") {
+		t.Errorf("Unexpected diagnostics %+v", p)
+	}
+	json.Unmarshal(notes[1]["params"], &p)
+	if len(p.Diagnostics) == 0 || p.Diagnostics[0].Range != (lspRange{lspPosition{1, 1}, lspPosition{1, 19}}) || !strings.Contains(p.Diagnostics[0].Message, "unknown class ex:Hobbit") {
+		t.Errorf("Unexpected diagnostics %+v", p)
+	}
+	json.Unmarshal(notes[2]["params"], &p)
+	if len(p.Diagnostics) != 0 {
+		t.Errorf("Expected no diagnostics got %+v", p)
+	}
+}
+
+func TestLspDiagnosticsOutsideComments(t *testing.T) {
+	rules := "rule A {\n  // HasTyp is misspelled in B\n  Start[iri].HasType[ex:A].Eval\n}\n" +
+		"rule B {\n  Start[iri]\n  .HasTyp[ex:A]\n  .Eval\n}\n"
+	_, notes := lspSession(t, nil,
+		didOpen("file:///cmd.brm", "// HasTyp is misspelled below\nStart[iri].HasTyp[ex:A].Eval"),
+		didOpen("file:///rules.brm", rules),
+	)
+	expected := []lspRange{
+		{lspPosition{1, 11}, lspPosition{1, 17}},
+		{lspPosition{6, 3}, lspPosition{6, 9}},
+	}
+	for i, r := range expected {
+		var p struct {
+			Diagnostics []lspDiagnostic `json:"diagnostics"`
+		}
+		json.Unmarshal(notes[i]["params"], &p)
+		if len(p.Diagnostics) != 1 || p.Diagnostics[0].Range != r {
+			t.Errorf("Expected a diagnostic at %+v got %+v", r, p.Diagnostics)
+		}
+	}
+}
+
+const lspRules = `PREFIX zoo: <http://example.org/>
+
+def Monster = Or(HasType[zoo:Gremlin].HasType[zoo:GooGrok])
+
+rule Hungry {
+    Start[iri].Use[Monster].HasValue[zoo:name, "x"].Eval
+}
+`
+
+func TestLspHoverAndDefinition(t *testing.T) {
+	uri := "file:///rules.brm"
+	msgs := []map[string]any{
+		didOpen(uri, lspRules),
+		request(1, "textDocument/hover", uri, 5, 30),
+		request(2, "textDocument/hover", uri, 5, 21),
+		request(3, "textDocument/definition", uri, 5, 21),
+		request(4, "textDocument/hover", uri, 0, 3),
+	}
+	results, _ := lspSession(t, nil, append(msgs, shutdown(5)...)...)
+
+	if s := string(results[1]); !strings.Contains(s, "HasValue[iri, literal, ...]") || !strings.Contains(s, "Takes 2 or more arguments.") {
+		t.Errorf("Unexpected hover %s", s)
+	}
+	if s := string(results[2]); !strings.Contains(s, "def Monster") || !strings.Contains(s, "line 3") {
+		t.Errorf("Unexpected hover %s", s)
+	}
+	var loc lspLocation
+	json.Unmarshal(results[3], &loc)
+	if loc.URI != uri || loc.Range != (lspRange{lspPosition{2, 4}, lspPosition{2, 11}}) {
+		t.Errorf("Unexpected definition %s", results[3])
+	}
+	if string(results[4]) != "null" {
+		t.Errorf("Expected no hover outside of steps got %s", results[4])
+	}
+}
+
+func TestLspHoverTypes(t *testing.T) {
+	schema, err := parser.LoadSchemaFile("parser/testdata/animals.ttl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri := "file:///cmd.brm"
+	msgs := []map[string]any{
+		didOpen(uri, "Start[iri].HasType[ex:Gremlin].Eval"),
+		request(1, "textDocument/hover", uri, 0, 12),
+	}
+	results, _ := lspSession(t, schema, append(msgs, shutdown(2)...)...)
+	if s := string(results[1]); !strings.Contains(s, "Types after this step: ex:Gremlin") {
+		t.Errorf("Unexpected hover %s", s)
+	}
+}
+
+func TestLspCompletion(t *testing.T) {
+	uri := "file:///rules.brm"
+	// Completion works on the last rules that parsed while editing.
+	text := lspRules + "rule More { Start[iri].Use[Mo].Has\n"
+	msgs := []map[string]any{
+		didOpen(uri, lspRules),
+		{"method": "textDocument/didChange", "params": map[string]any{
+			"textDocument":   map[string]any{"uri": uri, "version": 2},
+			"contentChanges": []map[string]any{{"text": text}},
+		}},
+		request(1, "textDocument/completion", uri, 7, 29),
+		request(2, "textDocument/completion", uri, 7, 35),
+		request(3, "textDocument/completion", uri, 5, 39),
+	}
+	results, _ := lspSession(t, nil, append(msgs, shutdown(4)...)...)
+
+	labels := func(id int) []string {
+		var items []lspCompletionItem
+		json.Unmarshal(results[id], &items)
+		l := make([]string, len(items))
+		for i, it := range items {
+			l[i] = it.Label
+		}
+		return l
+	}
+	if l := labels(1); strings.Join(l, " ") != "Monster" {
+		t.Errorf("Expected fragment completion got %v", l)
+	}
+	var fragments []lspCompletionItem
+	json.Unmarshal(results[1], &fragments)
+	if len(fragments) == 1 && fragments[0].Kind != lspKindReference {
+		t.Errorf("Expected fragments to be of kind %d got %d", lspKindReference, fragments[0].Kind)
+	}
+	if l := labels(2); strings.Join(l, " ") != "HasBroader HasCategory HasType HasValue" {
+		t.Errorf("Expected step completions got %v", l)
+	}
+	if l := labels(3); !strings.Contains(strings.Join(l, " "), "zoo:") {
+		t.Errorf("Expected prefix completions got %v", l)
+	}
+}
+
+func TestLspFormatting(t *testing.T) {
+	uri := "file:///cmd.brm"
+	msgs := []map[string]any{
+		didOpen(uri, "Start[iri].HasType[ex:Gremlin] // g\n.Eval"),
+		request(1, "textDocument/formatting", uri, 0, 0),
+	}
+	results, _ := lspSession(t, nil, append(msgs, shutdown(2)...)...)
+	var edits []lspTextEdit
+	json.Unmarshal(results[1], &edits)
+	if len(edits) != 1 || edits[0].NewText != "Start[iri]\n.HasType[ex:Gremlin]  // g\n.Eval\n" || edits[0].Range.End != (lspPosition{1, 5}) {
+		t.Errorf("Unexpected edits %s", results[1])
+	}
+}
+
+func TestLspPositions(t *testing.T) {
+	text := "a\né𝄞b\n"
+	for _, test := range []struct {
+		offset int
+		pos    lspPosition
+	}{
+		{0, lspPosition{0, 0}},
+		{2, lspPosition{1, 0}},
+		{4, lspPosition{1, 1}},
+		{8, lspPosition{1, 3}},
+		{9, lspPosition{1, 4}},
+	} {
+		if got := positionOf(text, test.offset); got != test.pos {
+			t.Errorf("%d: expected %v got %v", test.offset, test.pos, got)
+		}
+		if got := offsetOf(text, test.pos); got != test.offset {
+			t.Errorf("%v: expected %d got %d", test.pos, test.offset, got)
+		}
+	}
+}
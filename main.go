@@ -1,32 +1,78 @@
 package main
 
 import (
-	"bremlin/parser"
-	"bufio"
 	"fmt"
+	"io"
 	"os"
-	"strings"
+)
+
+// env holds the standard streams of a command, so that commands can be run
+// from tests.
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
 
-	"github.com/k0kubun/pp"
+type command struct {
+	name    string
+	summary string
+	run     func(e *env, args []string) int
+}
+
+// Exit codes.
+const (
+	exitOK    = 0 // success
+	exitFail  = 1 // a command could not be parsed, linted, compiled or run
+	exitUsage = 2 // bad flags or arguments
 )
 
+var commands = []command{
+	{"parse", "print the steps of commands as text or JSON", runParse},
+	{"fmt", "format commands", runFmt},
+	{"lint", "validate commands against a schema", runLint},
+	{"compile", "emit the internalized plan of a command", runCompile},
+	{"eval", "evaluate a command against Turtle or N-Triples data", runEval},
+	{"translate", "translate a command to SPARQL, Cypher or Gremlin", runTranslate},
+	{"repl", "start an interactive session", runRepl},
+	{"lsp", "start a language server on stdin and stdout", runLsp},
+	{"serve", "answer parse, validate, explain and eval requests over HTTP and gRPC", runServe},
+}
+
 func main() {
-	fmt.Println("Enter Bremlin command:")
-	scanner := bufio.NewScanner(os.Stdin)
-	var lines []string
-	for scanner.Scan() {
-		line := scanner.Text()
-		if line == "" {
-			break
+	os.Exit(run(&env{os.Stdin, os.Stdout, os.Stderr}, os.Args[1:]))
+}
+
+func run(e *env, args []string) int {
+	if len(args) == 0 {
+		printUsage(e.stderr)
+		return exitUsage
+	}
+
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(e, args[1:])
 		}
-		lines = append(lines, line)
 	}
-	cmd := strings.Join(lines, "\n")
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		printUsage(e.stdout)
+		return exitOK
+	}
+	fmt.Fprintf(e.stderr, "bremlin: unknown command %s\n", args[0])
+	printUsage(e.stderr)
+	return exitUsage
+}
 
-	chain, err := parser.ParseCommand(cmd)
-	if err != nil {
-		fmt.Printf("Error: %s\n", err)
-	} else {
-		pp.Println(chain)
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: bremlin <command> [flags] [FILE ...]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.summary)
 	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands read the files given, or stdin when there are none or the file is -.")
+	fmt.Fprintf(w, "They exit with %d on success, %d when a command fails and %d on bad usage.\n", exitOK, exitFail, exitUsage)
+	fmt.Fprintln(w, "Run bremlin <command> -h for the flags of a command.")
 }
@@ -0,0 +1,175 @@
+package parser
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// The binary plan format is
+//
+//	magic "BRMP" | version byte | steps | crc32 (big endian)
+//
+// where steps is a uvarint count followed by every step as
+//
+//	token byte | uvarint arg | uvarint n, n uvarint ivals |
+//	uvarint n, n (uvarint length, bytes) svals | subcmd steps
+//
+// and the checksum covers everything before it.
+const (
+	binaryMagic   = "BRMP"
+	BinaryVersion = 1
+
+	// Bounds nesting of Or subcommands when decoding untrusted input.
+	maxBinaryDepth = 64
+)
+
+var ErrChecksum = errors.New("plan checksum mismatch")
+
+// Encodes internalized steps to the compact binary plan format.
+func EncodeSteps(steps []istep) []byte {
+	b := []byte(binaryMagic)
+	b = append(b, BinaryVersion)
+	b = appendSteps(b, steps)
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b))
+}
+
+func appendSteps(b []byte, steps []istep) []byte {
+	b = binary.AppendUvarint(b, uint64(len(steps)))
+	for _, s := range steps {
+		b = append(b, byte(s.Token))
+		b = binary.AppendUvarint(b, uint64(s.Arg))
+		b = binary.AppendUvarint(b, uint64(len(s.Ivals)))
+		for _, v := range s.Ivals {
+			b = binary.AppendUvarint(b, uint64(v))
+		}
+		b = binary.AppendUvarint(b, uint64(len(s.Svals)))
+		for _, v := range s.Svals {
+			b = binary.AppendUvarint(b, uint64(len(v)))
+			b = append(b, v...)
+		}
+		b = appendSteps(b, s.Subcmd)
+	}
+	return b
+}
+
+// Decodes internalized steps from the compact binary plan format.
+func DecodeSteps(b []byte) ([]istep, error) {
+	if len(b) < len(binaryMagic)+1+4 {
+		return nil, fmt.Errorf("plan too short (%d bytes)", len(b))
+	}
+	if string(b[:len(binaryMagic)]) != binaryMagic {
+		return nil, fmt.Errorf("not a binary plan")
+	}
+	if v := b[len(binaryMagic)]; v != BinaryVersion {
+		return nil, fmt.Errorf("unsupported plan version %d, expected %d", v, BinaryVersion)
+	}
+
+	body, sum := b[:len(b)-4], b[len(b)-4:]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(sum) {
+		return nil, ErrChecksum
+	}
+
+	d := binaryDecoder{b: body[len(binaryMagic)+1:]}
+	steps, err := d.steps(0)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.b) != 0 {
+		return nil, fmt.Errorf("%d trailing bytes after plan", len(d.b))
+	}
+	return steps, nil
+}
+
+type binaryDecoder struct {
+	b []byte
+}
+
+func (d *binaryDecoder) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		return 0, fmt.Errorf("malformed varint")
+	}
+	d.b = d.b[n:]
+	return v, nil
+}
+
+// Reads a count of items that each take at least one byte, so that corrupt
+// counts cannot cause large allocations.
+func (d *binaryDecoder) count() (int, error) {
+	n, err := d.uvarint()
+	if err != nil {
+		return 0, err
+	}
+	if n > uint64(len(d.b)) {
+		return 0, fmt.Errorf("count %d exceeds remaining %d bytes", n, len(d.b))
+	}
+	return int(n), nil
+}
+
+func (d *binaryDecoder) steps(depth int) ([]istep, error) {
+	if depth > maxBinaryDepth {
+		return nil, fmt.Errorf("subcommands nested deeper than %d", maxBinaryDepth)
+	}
+	n, err := d.count()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, nil
+	}
+
+	steps := make([]istep, n)
+	for i := range steps {
+		s := &steps[i]
+		if len(d.b) == 0 {
+			return nil, fmt.Errorf("unexpected end of plan")
+		}
+		s.Token = Token(d.b[0])
+		d.b = d.b[1:]
+		if Ttoa(s.Token) == "**error**" {
+			return nil, fmt.Errorf("unknown token %d", s.Token)
+		}
+
+		arg, err := d.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		s.Arg = Iid(arg)
+
+		nv, err := d.count()
+		if err != nil {
+			return nil, err
+		}
+		for j := 0; j < nv; j++ {
+			v, err := d.uvarint()
+			if err != nil {
+				return nil, err
+			}
+			s.Ivals = append(s.Ivals, Iid(v))
+		}
+
+		ns, err := d.count()
+		if err != nil {
+			return nil, err
+		}
+		for j := 0; j < ns; j++ {
+			l, err := d.uvarint()
+			if err != nil {
+				return nil, err
+			}
+			if l > uint64(len(d.b)) {
+				return nil, fmt.Errorf("string length %d exceeds remaining %d bytes", l, len(d.b))
+			}
+			s.Svals = append(s.Svals, string(d.b[:l]))
+			d.b = d.b[l:]
+		}
+
+		s.Subcmd, err = d.steps(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return steps, nil
+}
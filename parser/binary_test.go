@@ -0,0 +1,94 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func internalizeCmd(t testing.TB, cmd string) []istep {
+	t.Helper()
+	chain, err := ParseCommand(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps, err := InternalizeSteps(chain, NewIidStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return steps
+}
+
+const binaryTestCmd = `Start[iri].Or(IsInstance[red].HasBroader[tax, node]).HasValue[color, "blue", "green"].Follow[rel].Eval`
+
+func TestBinaryRoundTrip(t *testing.T) {
+	steps := internalizeCmd(t, binaryTestCmd)
+
+	b := EncodeSteps(steps)
+	back, err := DecodeSteps(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, steps) {
+		t.Errorf("Expected %+v got %+v", steps, back)
+	}
+
+	j, err := json.Marshal(steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) >= len(j) {
+		t.Errorf("Expected binary plan (%d bytes) to be smaller than JSON (%d bytes)", len(b), len(j))
+	}
+}
+
+func TestBinaryChecksum(t *testing.T) {
+	b := EncodeSteps(internalizeCmd(t, binaryTestCmd))
+	b[8] ^= 0xff
+
+	_, err := DecodeSteps(b)
+	if !errors.Is(err, ErrChecksum) {
+		t.Errorf("Expected checksum error got %v", err)
+	}
+}
+
+func TestBinaryVersion(t *testing.T) {
+	b := EncodeSteps(internalizeCmd(t, binaryTestCmd))
+	b[4] = BinaryVersion + 1
+
+	_, err := DecodeSteps(b)
+	if err == nil {
+		t.Errorf("Expected error for unsupported version")
+	}
+}
+
+func TestBinaryTruncated(t *testing.T) {
+	b := EncodeSteps(internalizeCmd(t, binaryTestCmd))
+	for i := 0; i < len(b); i++ {
+		if _, err := DecodeSteps(b[:i]); err == nil {
+			t.Errorf("Expected error decoding %d of %d bytes", i, len(b))
+		}
+	}
+}
+
+func FuzzDecodeSteps(f *testing.F) {
+	f.Add(EncodeSteps(internalizeCmd(f, binaryTestCmd)))
+	f.Add(EncodeSteps(internalizeCmd(f, `Start[iri].Eval`)))
+	f.Add([]byte(binaryMagic))
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		steps, err := DecodeSteps(b)
+		if err != nil {
+			return
+		}
+		back, err := DecodeSteps(EncodeSteps(steps))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(back, steps) {
+			t.Errorf("Expected %+v got %+v", steps, back)
+		}
+	})
+}
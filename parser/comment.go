@@ -0,0 +1,180 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// A comment of Bremlin source: // or # to the end of the line, or /* to */.
+// A comment starts at the start of a line or after whitespace, outside of
+// quotes and <iri>, so that IRIs such as http://example.org/a#b are not
+// taken for comments.
+type comment struct {
+	text       string // the comment as written, markers included
+	start, end int    // byte offsets of the comment in the source
+}
+
+// Finds the comments of the source. An unterminated block comment runs to
+// the end of the source and is reported as an error.
+func findComments(src string) ([]comment, error) {
+	comments := make([]comment, 0)
+	iq, ia := false, false
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '\n':
+			iq, ia = false, false
+		case iq:
+			// A backslash escapes the quote or backslash after it.
+			if c == '\\' && i+1 < len(src) && src[i+1] != '\n' {
+				i++
+			} else if c == '"' {
+				iq = false
+			}
+		case ia:
+			if c == '>' {
+				ia = false
+			}
+		case c == '"':
+			iq = true
+		case c == '<':
+			ia = true
+		case i > 0 && !isSpace(src[i-1]):
+		case c == '#' || strings.HasPrefix(src[i:], "//"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			comments = append(comments, comment{strings.TrimSpace(src[i : i+end]), i, i + end})
+			i += end - 1
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				comments = append(comments, comment{strings.TrimSpace(src[i:]), i, len(src)})
+				return comments, fmt.Errorf("unterminated comment")
+			}
+			end += i + 4
+			comments = append(comments, comment{src[i:end], i, end})
+			i = end - 1
+		}
+	}
+	return comments, nil
+}
+
+// Replaces the comments with spaces, keeping the line breaks so that
+// offsets and line numbers do not change.
+func blankComments(src string, comments []comment) string {
+	b := []byte(src)
+	for _, c := range comments {
+		for i := c.start; i < c.end; i++ {
+			if b[i] != '\n' {
+				b[i] = ' '
+			}
+		}
+	}
+	return string(b)
+}
+
+// Replaces the comments of the source with spaces.
+func stripComments(src string) string {
+	comments, _ := findComments(src)
+	return blankComments(src, comments)
+}
+
+// The comments around a step of the source. A comment on the line a step
+// starts on trails it, other comments lead the step that follows them.
+// Comments after the last step trail it.
+type stepComments struct {
+	name     string
+	leading  []string
+	trailing []string
+}
+
+// Removes the comments of a command and returns them by the step they
+// belong to, in the order the steps are written.
+func extractComments(cmd string) (string, []stepComments, error) {
+	comments, err := findComments(cmd)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(comments) == 0 {
+		return cmd, nil, nil
+	}
+	src := blankComments(cmd, comments)
+
+	starts := stepStarts(src)
+	steps := make([]stepComments, len(starts))
+	for i, s := range starts {
+		steps[i].name = nameAt(src, s)
+	}
+	for _, c := range comments {
+		// The number of steps that start before the comment.
+		n, _ := slices.BinarySearch(starts, c.start)
+		switch {
+		case n > 0 && (n == len(starts) || !hasLineBreak(src, starts[n-1], c.start)):
+			steps[n-1].trailing = append(steps[n-1].trailing, c.text)
+		case n < len(starts):
+			steps[n].leading = append(steps[n].leading, c.text)
+		}
+	}
+	return src, steps, nil
+}
+
+// Attaches the comments to the steps of the parsed chain. Source steps that
+// do not make it into the chain, such as And, pass their comments on to the
+// next step.
+func attachComments(chain []Step, comments []stepComments) {
+	var pending []string
+	var attach func(chain []Step)
+	attach = func(chain []Step) {
+		for i := range chain {
+			for len(comments) > 0 && comments[0].name != chain[i].token {
+				pending = append(pending, comments[0].leading...)
+				pending = append(pending, comments[0].trailing...)
+				comments = comments[1:]
+			}
+			if len(comments) == 0 {
+				return
+			}
+			if leading := append(pending, comments[0].leading...); len(leading) > 0 {
+				chain[i].leading = leading
+			}
+			if len(comments[0].trailing) > 0 {
+				chain[i].trailing = comments[0].trailing
+			}
+			pending = nil
+			comments = comments[1:]
+			attach(chain[i].subcmd)
+		}
+	}
+	attach(chain)
+}
+
+// Writes the comments trailing a step. They stay on the line of the step
+// until a line comment ends it.
+func writeTrailing(sb *strings.Builder, comments []string, indent string) {
+	for i, c := range comments {
+		switch {
+		case i == 0:
+			sb.WriteString("  ")
+		case isLineComment(comments[i-1]):
+			sb.WriteString("\n" + indent)
+		default:
+			sb.WriteString(" ")
+		}
+		sb.WriteString(c)
+	}
+}
+
+func isLineComment(c string) bool {
+	return !strings.HasPrefix(c, "/*")
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStripComments(t *testing.T) {
+	src := "# comment\nHasType[<http://example.org/a#b>] // trailing\nHasValue[f, \"# not // a comment\"]#x /* a\nb */"
+	expected := "         \nHasType[<http://example.org/a#b>]            \nHasValue[f, \"# not // a comment\"]#x     \n    "
+	if got := stripComments(src); got != expected {
+		t.Errorf("Expected %q got %q", expected, got)
+	}
+}
+
+func TestFindComments(t *testing.T) {
+	comments, err := findComments("Start[iri] /* a */ // b\n# c\n.Eval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts := make([]string, len(comments))
+	for i, c := range comments {
+		texts[i] = c.text
+	}
+	if !reflect.DeepEqual(texts, []string{"/* a */", "// b", "# c"}) {
+		t.Errorf("Unexpected comments %q", texts)
+	}
+
+	if _, err := findComments("Start[iri] /* a"); err == nil {
+		t.Error("Expected an error for an unterminated comment")
+	}
+}
+
+func TestParseComments(t *testing.T) {
+	chain, err := ParseCommand(`// find them
+Start[iri]
+	.Or( // either
+		HasType[ex:Gremlin] /* one */
+		.HasType[ex:GooGrok])
+	# food
+	.And(Follow[ex:Eats])
+	.Eval // done
+// end`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Start[iri].Or[](HasType[ex:Gremlin].HasType[ex:GooGrok]).Follow[ex:Eats].Eval[]"
+	if chainString(chain) != expected {
+		t.Fatalf("Expected %s got %s", expected, chainString(chain))
+	}
+
+	tests := []struct {
+		step     Step
+		leading  []string
+		trailing []string
+	}{
+		{chain[0], []string{"// find them"}, nil},
+		{chain[1], nil, []string{"// either"}},
+		{chain[1].subcmd[0], nil, []string{"/* one */"}},
+		{chain[2], []string{"# food"}, nil},
+		{chain[3], nil, []string{"// done", "// end"}},
+	}
+	for _, test := range tests {
+		if !reflect.DeepEqual(test.step.leading, test.leading) || !reflect.DeepEqual(test.step.trailing, test.trailing) {
+			t.Errorf("%s: expected %q %q got %q %q", test.step.token, test.leading, test.trailing, test.step.leading, test.step.trailing)
+		}
+	}
+}
+
+func TestFormatComments(t *testing.T) {
+	src := `/* find
+   them */
+Start[iri]  // start
+.Or(  // either
+	HasType[ex:Gremlin]  /* one */ // two
+	.HasType[ex:GooGrok]
+)
+# food
+.Follow[ex:Eats]
+.Eval  // done
+// end
+`
+	chain, err := ParseCommand(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := FormatChain(chain); got != src {
+		t.Errorf("Expected\n%s\ngot\n%s", src, got)
+	}
+}
@@ -0,0 +1,209 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Translates a chain of steps to an openCypher query returning the nodes
+// the chain evaluates to.
+func ToCypher(chain []Step, m GraphMapping) (string, error) {
+	if len(chain) < 2 || chain[0].token != "Start" || chain[len(chain)-1].token != "Eval" {
+		return "", fmt.Errorf("expected chain to begin with Start and end with Eval")
+	}
+
+	steps, limit, err := trailingLimit(chain[1 : len(chain)-1])
+	if err != nil {
+		return "", err
+	}
+	w := cypherWriter{m: m}
+	node := w.newNode()
+	lines, node, err := w.clauses(steps, node, "MATCH ("+node+")")
+	if err != nil {
+		return "", err
+	}
+	lines = append(lines, "RETURN DISTINCT "+node)
+	if limit > 0 {
+		lines = append(lines, fmt.Sprintf("LIMIT %d", limit))
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+type cypherWriter struct {
+	m     GraphMapping
+	nodes int
+}
+
+func (w *cypherWriter) newNode() string {
+	v := fmt.Sprintf("n%d", w.nodes)
+	w.nodes++
+	return v
+}
+
+// Translates a chain starting at node to a list of clauses. The first clause
+// introduces node, conditions on the current node are collected in a WHERE
+// clause following the most recent MATCH or WITH.
+func (w *cypherWriter) clauses(chain []Step, node, first string) ([]string, string, error) {
+	lines := []string{first}
+	conds := make([]string, 0)
+	// WHERE cannot follow a CALL subquery directly.
+	afterCall := false
+	flush := func() {
+		if len(conds) > 0 {
+			if afterCall {
+				lines = append(lines, "WITH "+node)
+			}
+			lines = append(lines, "WHERE "+strings.Join(conds, " AND "))
+			conds = conds[:0]
+		}
+		afterCall = false
+	}
+
+	for _, s := range chain {
+		switch {
+		case isFollow(s):
+			flush()
+			next := w.newNode()
+			lines = append(lines, "MATCH "+w.edge(s, node, next))
+			node = next
+		case s.token == "Or" && moves(s):
+			flush()
+			next := w.newNode()
+			l, err := w.union(s, node, next)
+			if err != nil {
+				return nil, "", err
+			}
+			lines = append(lines, l...)
+			node = next
+			afterCall = true
+		default:
+			c, err := w.condition(s, node)
+			if err != nil {
+				return nil, "", err
+			}
+			if c != "" {
+				conds = append(conds, c)
+			}
+		}
+	}
+	flush()
+	return lines, node, nil
+}
+
+// Translates an Or step whose alternatives move to other nodes to a
+// subquery returning the union of the alternatives as next.
+func (w *cypherWriter) union(s Step, node, next string) ([]string, error) {
+	lines := []string{"CALL {"}
+	for i, sub := range s.subcmd {
+		if i > 0 {
+			lines = append(lines, "  UNION")
+		}
+		l, end, err := w.clauses([]Step{sub}, node, "WITH "+node)
+		if err != nil {
+			return nil, err
+		}
+		if end == next {
+			l = append(l, "RETURN "+next)
+		} else {
+			l = append(l, fmt.Sprintf("RETURN %s AS %s", end, next))
+		}
+		for _, x := range l {
+			lines = append(lines, "  "+x)
+		}
+	}
+	return append(lines, "}"), nil
+}
+
+func (w *cypherWriter) edge(s Step, from, to string) string {
+	rel := cypherName(w.m.name(s.arg))
+	if s.token == "FollowInverse" {
+		return fmt.Sprintf("(%s)<-[:%s]-(%s)", from, rel, to)
+	}
+	return fmt.Sprintf("(%s)-[:%s]->(%s)", from, rel, to)
+}
+
+// Translates a step that filters the current node to a WHERE condition.
+func (w *cypherWriter) condition(s Step, node string) (string, error) {
+	m := w.m
+	switch s.token {
+	case "NoOp":
+		return "", nil
+	case "HasType":
+		if m.TypeAsLabel {
+			return node + ":" + cypherName(m.name(s.arg)), nil
+		}
+		return w.equals(node, m.TypeProperty, m.name(s.arg)), nil
+	case "HasCategory":
+		return w.equals(node, m.CategoryProperty, m.name(s.arg)), nil
+	case "HasValue":
+		vals := make([]string, len(s.vals))
+		for i, v := range s.vals {
+			vals[i] = cypherString(v)
+		}
+		return fmt.Sprintf("%s.%s IN [%s]", node, cypherName(m.name(s.arg)), strings.Join(vals, ", ")), nil
+	case "IsInstance":
+		return w.equals(node, m.IdProperty, m.name(s.arg)), nil
+	case "InScheme":
+		return w.edgeTo(node, m.SchemeEdge, s.arg), nil
+	case "HasBroader":
+		if len(s.vals) != 1 {
+			return "", fmt.Errorf("expected HasBroader[taxonomy, target] got %d values", len(s.vals))
+		}
+		return w.edgeTo(node, m.SchemeEdge, s.arg) + " AND " + w.edgeTo(node, m.BroaderEdge, s.vals[0]), nil
+	case "IsActive":
+		return fmt.Sprintf("%s.%s = %s", node, cypherName(m.ActiveProperty), cypherLiteral(m.ActiveValue)), nil
+	case "IsInactive":
+		return fmt.Sprintf("NOT coalesce(%s.%s = %s, false)", node, cypherName(m.ActiveProperty), cypherLiteral(m.ActiveValue)), nil
+	case "Or":
+		alts := make([]string, 0, len(s.subcmd))
+		for _, sub := range s.subcmd {
+			c, err := w.condition(sub, node)
+			if err != nil {
+				return "", err
+			}
+			if c != "" {
+				alts = append(alts, c)
+			}
+		}
+		if len(alts) == 0 {
+			return "", nil
+		}
+		return "(" + strings.Join(alts, " OR ") + ")", nil
+	case "Limit":
+		return "", fmt.Errorf("cannot translate Limit to Cypher unless it ends the command")
+	default:
+		return "", fmt.Errorf("cannot translate %s to Cypher", s.token)
+	}
+}
+
+func (w *cypherWriter) equals(node, key, val string) string {
+	return fmt.Sprintf("%s.%s = %s", node, cypherName(key), cypherString(val))
+}
+
+// Matches an edge from node to the node identified by target.
+func (w *cypherWriter) edgeTo(node, edge, target string) string {
+	return fmt.Sprintf("(%s)-[:%s]->({%s: %s})", node, cypherName(edge), cypherName(w.m.IdProperty), cypherString(w.m.name(target)))
+}
+
+var cypherIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Quotes a label, relationship type or property key when needed.
+func cypherName(s string) string {
+	if cypherIdent.MatchString(s) {
+		return s
+	}
+	return "`" + strings.ReplaceAll(s, "`", "``") + "`"
+}
+
+func cypherString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\t", `\t`)
+	return "'" + r.Replace(s) + "'"
+}
+
+func cypherLiteral(s string) string {
+	if isBareLiteral(s) {
+		return s
+	}
+	return cypherString(s)
+}
@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCypherGolden(t *testing.T) {
+	testGolden(t, ".cypher", func(chain []Step) (string, error) {
+		return ToCypher(chain, DefaultGraphMapping())
+	})
+}
+
+func TestCypherMapping(t *testing.T) {
+	chain, err := ParseCommand(`Start[iri].HasType[ex:Gremlin].HasCategory[ex:Monster].IsActive[].Eval`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := DefaultGraphMapping()
+	m.TypeAsLabel = false
+	m.TypeProperty = "kind"
+	m.CategoryProperty = "cat"
+	m.ActiveProperty = "status"
+	m.ActiveValue = "live"
+	m.Names["ex:Gremlin"] = "Gremlin"
+
+	q, err := ToCypher(chain, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "WHERE n0.kind = 'Gremlin' AND n0.cat = 'ex:Monster' AND n0.status = 'live'"
+	if !strings.Contains(q, expected) {
+		t.Errorf("Expected %s in\n%s", expected, q)
+	}
+}
@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// Evaluator evaluates internalized steps against a graph.
+type Evaluator struct {
+	graph       Graph
+	is          Internalizer
+	limits      Limits
+	typ         Iid
+	category    Iid
+	inScheme    Iid
+	broader     Iid
+	active      Iid
+	activeValue string
+}
+
+// Creates an evaluator for the graph. The predicates of the vocabulary are
+// looked up in is, which must be the Internalizer the graph and the steps
+// were internalized with.
+func NewEvaluator(g Graph, v Vocabulary, is Internalizer) *Evaluator {
+	// A predicate the Internalizer does not know is not used by the graph,
+	// so the steps defined in terms of it match nothing.
+	nomatch, _ := is.GetIid("NoMatch")
+	lookup := func(s string) Iid {
+		if i, ok := is.GetIid(s); ok {
+			return i
+		}
+		return nomatch
+	}
+	return &Evaluator{
+		graph:       g,
+		is:          is,
+		typ:         lookup(v.Type),
+		category:    lookup(v.Category),
+		inScheme:    lookup(v.InScheme),
+		broader:     lookup(v.Broader),
+		active:      lookup(v.Active),
+		activeValue: v.ActiveValue,
+	}
+}
+
+// Evaluates the steps against the graph using the default vocabulary and
+// returns the nodes they lead to.
+func Evaluate(steps []istep, g Graph, is Internalizer) ([]Iid, error) {
+	return NewEvaluator(g, DefaultVocabulary(), is).Evaluate(steps)
+}
+
+// Evaluates the steps and returns the nodes they lead to, in Iid order.
+func (e *Evaluator) Evaluate(steps []istep) ([]Iid, error) {
+	return e.EvaluateContext(context.Background(), steps)
+}
+
+// Evaluates the steps like Evaluate, stopping with the error of the context
+// once it is done.
+func (e *Evaluator) EvaluateContext(ctx context.Context, steps []istep) ([]Iid, error) {
+	it, err := e.IterateContext(ctx, steps)
+	if err != nil {
+		return nil, err
+	}
+	nodes := slices.Collect(All(it))
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return nodeSet(nodes), nil
+}
+
+// Evaluates a single step on a set of nodes of g.
+func (e *Evaluator) step(s istep, g Graph, nodes []Iid) ([]Iid, error) {
+	f, ok, err := e.nodeFunc(s, g)
+	switch {
+	case err != nil:
+		return nil, err
+	case ok && f.keep != nil:
+		return filter(nodes, f.keep), nil
+	case ok:
+		next := make([]Iid, 0)
+		for _, n := range nodes {
+			next = append(next, f.expand(n)...)
+		}
+		return nodeSet(next), nil
+	}
+
+	switch s.Token {
+	case NoOp:
+		return nodes, nil
+	case Limit:
+		n, err := istepCount(s)
+		if err != nil {
+			return nil, err
+		}
+		return nodes[:min(n, len(nodes))], nil
+	case Or:
+		union := make([]Iid, 0)
+		for _, sub := range s.Subcmd {
+			r, err := e.step(sub, g, nodes)
+			if err != nil {
+				return nil, err
+			}
+			union = append(union, r...)
+		}
+		return nodeSet(union), nil
+	}
+
+	d, ok := LookupToken(s.Token)
+	if !ok || d.eval == nil {
+		return nil, fmt.Errorf("cannot evaluate %s", Ttoa(s.Token))
+	}
+	next, err := d.eval(g, nodes, istepArgs(s))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", d.Name(), err)
+	}
+	return nodeSet(next), nil
+}
+
+// Returns the nodes for which keep returns true.
+func filter(nodes []Iid, keep func(Iid) bool) []Iid {
+	out := make([]Iid, 0, len(nodes))
+	for _, n := range nodes {
+		if keep(n) {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// Returns the distinct nodes in Iid order.
+func nodeSet(nodes []Iid) []Iid {
+	nodes = slices.Clone(nodes)
+	slices.Sort(nodes)
+	return slices.Compact(nodes)
+}
@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func loadTestGraph(t *testing.T, is Internalizer) *MemGraph {
+	t.Helper()
+	f, err := os.Open("testdata/zoo.ttl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	triples, err := ReadTriples(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewMemGraph(triples, is)
+}
+
+// Parses, internalizes and evaluates the command against the test graph and
+// returns the names of the nodes it leads to.
+func evalCmd(t *testing.T, cmd string) []string {
+	t.Helper()
+	is := NewSyncInternalizer()
+	g := loadTestGraph(t, is)
+	chain, err := ParseCommand(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps, err := InternalizeSteps(chain, is)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, err := Evaluate(steps, g, is)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(nodes))
+	for i, n := range nodes {
+		names[i], _ = is.GetString(n)
+	}
+	return names
+}
+
+func TestEvaluate(t *testing.T) {
+	tests := []struct {
+		cmd      string
+		expected []string
+	}{
+		{`Start[iri].HasType[ex:Gremlin].Eval`, []string{"ex:gizmo", "ex:stripe"}},
+		{`Start[iri].Or(HasType[ex:Gremlin].HasType[ex:GooGrok]).HasValue[ex:FurColor, "green", "blue"].Eval`,
+			[]string{"ex:gizmo", "ex:blob"}},
+		{`Start[iri].HasBroader[ex:Animals, ex:Preditor].Follow[ex:SmellOfFood].Follow[ex:ComesFrom].HasType[ex:TastyMeal].Eval`,
+			[]string{"ex:cake"}},
+		{`Start[iri].HasType[ex:Food].FollowInverse[ex:Eats].IsInactive[].Eval`, []string{"ex:stripe"}},
+		{`Start[iri].InScheme[ex:Animals].IsActive[].HasCategory[ex:Slimy].Eval`, []string{"ex:blob"}},
+		{`Start[iri].IsInstance[ex:gizmo].Or(Follow[ex:Eats].Follow[ex:LivesIn]).Eval`, []string{"ex:cake", "ex:kingston"}},
+		{`Start[iri].HasType[ex:Hobbit].Eval`, []string{}},
+	}
+	for _, test := range tests {
+		names := evalCmd(t, test.cmd)
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("%s: expected %v got %v", test.cmd, test.expected, names)
+		}
+	}
+}
+
+func TestEvaluateNotAPlan(t *testing.T) {
+	is := NewSyncInternalizer()
+	g := loadTestGraph(t, is)
+	if _, err := Evaluate([]istep{{Token: HasType}}, g, is); err == nil {
+		t.Errorf("Expected error for plan without Start and Eval")
+	}
+}
@@ -0,0 +1,33 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Describes internalized steps for people: one step per line with the
+// strings its Iids stand for, and the alternatives of an Or indented.
+func Explain(steps []istep, is Internalizer) string {
+	var sb strings.Builder
+	explain(&sb, steps, is, "")
+	return sb.String()
+}
+
+func explain(sb *strings.Builder, steps []istep, is Internalizer, indent string) {
+	for _, s := range steps {
+		sb.WriteString(indent + Ttoa(s.Token))
+		args := istepArgs(s)
+		for _, i := range args.Iids {
+			str, ok := is.GetString(i)
+			if !ok {
+				str = "?"
+			}
+			fmt.Fprintf(sb, " %s (%d)", str, i)
+		}
+		for _, l := range args.Literals {
+			sb.WriteString(" " + bremlinString(l))
+		}
+		sb.WriteString("\n")
+		explain(sb, s.Subcmd, is, indent+"  ")
+	}
+}
@@ -0,0 +1,29 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	is := NewIidStore()
+	chain, err := ParseCommand(`Start[iri].Or(HasType[ex:Gremlin].IsActive[]).HasValue[ex:FurColor, green, "sky blue"].HasBroader[ex:Animals, ex:Preditor].Eval`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps, err := InternalizeSteps(chain, is)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `Start
+Or
+  HasType ex:Gremlin (2)
+  IsActive
+HasValue ex:FurColor (3) "green" "sky blue"
+HasBroader ex:Animals (4) ex:Preditor (5)
+Eval
+`
+	if s := Explain(steps, is); s != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, s)
+	}
+}
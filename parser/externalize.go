@@ -0,0 +1,167 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Convert internalized steps back to a chain of steps, looking up the string
+// of every Iid. It is an error for a step to refer to an Iid the
+// Internalizer does not know.
+func ExternalizeSteps(steps []istep, is Internalizer) ([]Step, error) {
+	getString := is.GetString
+	if bi, ok := is.(BatchInternalizer); ok {
+		iids := planIids(steps, nil)
+		strs, found := bi.GetStrings(iids)
+		known := make(map[Iid]string, len(iids))
+		for i, iid := range iids {
+			if found[i] {
+				known[iid] = strs[i]
+			}
+		}
+		getString = func(i Iid) (string, bool) {
+			s, ok := known[i]
+			return s, ok
+		}
+	}
+	return externalizeSteps(steps, getString)
+}
+
+func externalizeSteps(steps []istep, get func(Iid) (string, bool)) ([]Step, error) {
+	chain := make([]Step, 0, len(steps))
+	for _, s := range steps {
+		d, ok := LookupToken(s.Token)
+		if !ok {
+			return nil, fmt.Errorf("unknown token %d", s.Token)
+		}
+		lookup := func(i Iid) (string, error) {
+			str, ok := get(i)
+			if !ok {
+				return "", fmt.Errorf("dangling iid %d in %s step", i, d.Name())
+			}
+			return str, nil
+		}
+
+		step := Step{
+			token: d.Name(),
+		}
+		if d.Token == Start {
+			step.arg = "iri"
+		}
+
+		// Identifiers precede literals in the arguments of every step.
+		args := make([]string, 0)
+		if hasIidArg(d.Token) {
+			a, err := lookup(s.Arg)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, a)
+		}
+		for _, i := range s.Ivals {
+			a, err := lookup(i)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, a)
+		}
+		args = append(args, s.Svals...)
+		if len(args) > 0 {
+			step.arg = args[0]
+		}
+		if len(args) > 1 {
+			step.vals = args[1:]
+		}
+		if _, err := checkStep(step); err != nil {
+			return nil, err
+		}
+
+		if d.Subcmd {
+			subcmd, err := externalizeSteps(s.Subcmd, get)
+			if err != nil {
+				return nil, err
+			}
+			step.subcmd = subcmd
+		}
+		chain = append(chain, step)
+	}
+	return chain, nil
+}
+
+// Formats a chain of steps as Bremlin source that parses back to the same
+// chain, with one step per line and the alternatives of an Or indented.
+// The comments of the steps are written before them or at the end of their
+// line.
+func FormatChain(chain []Step) string {
+	var sb strings.Builder
+	formatChain(&sb, chain, "")
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+func formatChain(sb *strings.Builder, chain []Step, indent string) {
+	first := true
+	for _, s := range chain {
+		if s.token == "NoOp" {
+			continue
+		}
+		if !first {
+			sb.WriteString("\n")
+		}
+		for _, c := range s.leading {
+			sb.WriteString(indent + c + "\n")
+		}
+		sb.WriteString(indent)
+		if !first {
+			sb.WriteString(".")
+		}
+		first = false
+
+		d, ok := LookupStep(s.token)
+		switch {
+		case s.token == useStep:
+			sb.WriteString(formatUse(s))
+		case !ok:
+			// Written as is, so that the parser reports it.
+			sb.WriteString(s.token)
+		case d.Token == Eval:
+			sb.WriteString(s.token)
+		case d.Subcmd:
+			sb.WriteString(s.token + "(")
+			writeTrailing(sb, s.trailing, indent)
+			sb.WriteString("\n")
+			formatChain(sb, s.subcmd, indent+"\t")
+			sb.WriteString("\n" + indent + ")")
+			continue
+		default:
+			args := stepArgs(s)
+			for i, a := range args {
+				// The count of a Limit is written as a number.
+				if d.ArgKind(i) == LiteralArg && d.Token != Limit {
+					args[i] = bremlinString(a)
+				} else {
+					args[i] = bremlinArg(a)
+				}
+			}
+			sb.WriteString(s.token + "[" + strings.Join(args, ", ") + "]")
+		}
+		writeTrailing(sb, s.trailing, indent)
+	}
+}
+
+var bremlinName = regexp.MustCompile(`^\$?[A-Za-z0-9_:/#-]+$`)
+
+// Quotes an argument when it contains characters the parser splits on.
+func bremlinArg(s string) string {
+	if bremlinName.MatchString(s) {
+		return s
+	}
+	return bremlinString(s)
+}
+
+// Quotes a string, escaping the backslashes and quotes in it.
+func bremlinString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(s) + `"`
+}
@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExternalizeSteps(t *testing.T) {
+	cmd := `Start[iri].Or(HasType[ex:Gremlin].Follow[ex:Eats]).HasValue[ex:FurColor, "green", "blue"]` +
+		`.HasBroader[ex:Animals, ex:Preditor].IsActive[].Eval`
+	chain, err := ParseCommand(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, is := range []Internalizer{NewIidStore(), NewSyncInternalizer()} {
+		steps, err := InternalizeSteps(chain, is)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ExternalizeSteps(steps, is)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if chainString(got) != chainString(chain) {
+			t.Errorf("Expected %s got %s", chainString(chain), chainString(got))
+		}
+	}
+}
+
+func TestExternalizeStepsDangling(t *testing.T) {
+	is := NewIidStore()
+	steps := []istep{
+		{Token: Start},
+		{Token: Or, Subcmd: []istep{{Token: HasBroader, Arg: 1, Ivals: []Iid{42}}}},
+		{Token: Eval},
+	}
+	if _, err := ExternalizeSteps(steps, is); err == nil {
+		t.Errorf("Expected error for dangling iid")
+	}
+	if _, err := ExternalizeSteps(steps, NewSyncInternalizer()); err == nil {
+		t.Errorf("Expected error for dangling iid")
+	}
+}
+
+func TestFormatChain(t *testing.T) {
+	cmd := `Start[iri].Or(HasType[Gremlin].And(HasType[GooGrok].IsInactive[])).HasValue[FurColor, green, "sky blue"]` +
+		`.InScheme["http://other.org/Animals"].HasBroader[ex:Fantasy, ex:Preditor].Follow[SmellOfFood].Eval`
+	chain, err := ParseCommand(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `Start[iri]
+.Or(
+	HasType[Gremlin]
+	.HasType[GooGrok]
+	.IsInactive[]
+)
+.HasValue[FurColor, "green", "sky blue"]
+.InScheme["http://other.org/Animals"]
+.HasBroader[ex:Fantasy, ex:Preditor]
+.Follow[SmellOfFood]
+.Eval
+`
+	src := FormatChain(chain)
+	if src != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, src)
+	}
+
+	reparsed, err := ParseCommand(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reparsed, chain) {
+		t.Errorf("Expected %s got %s", chainString(chain), chainString(reparsed))
+	}
+}
+
+func TestFormatChainQuotes(t *testing.T) {
+	cmd := `Start[iri].HasValue[ex:Name, "say \"hi\"", "a, b] (c).d", "\""].Eval`
+	chain, err := ParseCommand(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{`say "hi"`, `a, b] (c).d`, `"`}
+	if !reflect.DeepEqual(chain[1].vals, expected) {
+		t.Fatalf("Expected %q got %q", expected, chain[1].vals)
+	}
+
+	src := FormatChain(chain)
+	reparsed, err := ParseCommand(src)
+	if err != nil {
+		t.Fatalf("%s: %s", src, err)
+	}
+	if !reflect.DeepEqual(reparsed, chain) {
+		t.Errorf("Expected %q got %q", chain[1].vals, reparsed[1].vals)
+	}
+}
+
+func TestFormatChainBackslashes(t *testing.T) {
+	vals := []string{`C:\`, `a\"b`, `\\`, `x\dy`, `"\`}
+	chain := []Step{
+		{token: "Start", arg: "iri"},
+		{token: "HasValue", arg: "ex:Path", vals: vals},
+		{token: "Eval"},
+	}
+	src := FormatChain(chain)
+	reparsed, err := ParseCommand(src)
+	if err != nil {
+		t.Fatalf("%s: %s", src, err)
+	}
+	if !reflect.DeepEqual(reparsed[1].vals, vals) {
+		t.Errorf("Expected %q got %q", vals, reparsed[1].vals)
+	}
+
+	chain, err = FromSPARQL(`SELECT ?n0 WHERE { ?n0 <http://example.org/Path> ?v0 . VALUES ?v0 { "C:\\" } }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src = FormatChain(chain)
+	if reparsed, err = ParseCommand(src); err != nil {
+		t.Fatalf("%s: %s", src, err)
+	}
+	if v := reparsed[1].vals; len(v) != 1 || v[0] != `C:\` {
+		t.Errorf("Expected [C:\\] got %q", v)
+	}
+}
@@ -0,0 +1,289 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"slices"
+	"sync"
+)
+
+// The log starts with a header followed by one record per string, in Iid
+// order. A record is a uvarint length, the string and a crc32 (big endian)
+// of the string.
+const fileInternalizerHeader = "BRMI\x01"
+
+// The strings every Internalizer is seeded with, stored as the first records
+// of the log.
+var fileInternalizerSeeds = []string{"Error", "NoMatch"}
+
+// FileInternalizer is an Internalizer backed by an append-only log on disk,
+// so that strings keep their Iids across process restarts. The log is read
+// into memory when it is opened and every new string is appended to it
+// before Put returns. Writes survive a crash of the process; call Sync to
+// make them survive a crash of the machine.
+//
+// A record that was only partially written when the process crashed is
+// discarded when the log is opened again. Such a string was never returned
+// from Put, so no Iid that was handed out changes. A corrupt record followed
+// by other records cannot be the result of a crash, and opening such a log
+// fails rather than give the strings after it new Iids.
+type FileInternalizer struct {
+	index *SyncInternalizer
+
+	mu   sync.Mutex // serializes appends
+	f    *os.File
+	size int64
+	err  error
+}
+
+// Opens the log at path, creating it if it does not exist.
+func OpenFileInternalizer(path string) (*FileInternalizer, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	fi := &FileInternalizer{
+		index: NewSyncInternalizer(),
+		f:     f,
+	}
+	if err := fi.load(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return fi, nil
+}
+
+// Replays the log into the in-memory index, truncating a torn record at the
+// end of the log and failing on a corrupt record before it.
+func (fi *FileInternalizer) load() error {
+	st, err := fi.f.Stat()
+	if err != nil {
+		return err
+	}
+	if st.Size() == 0 {
+		// The header and the seeds are written at once, so that a new log
+		// is either empty or holds both.
+		b := append([]byte(fileInternalizerHeader), encodeRecords(fileInternalizerSeeds)...)
+		if _, err := fi.f.Write(b); err != nil {
+			return err
+		}
+		fi.size = int64(len(b))
+		return fi.f.Sync()
+	}
+
+	r := bufio.NewReader(fi.f)
+	header := make([]byte, len(fileInternalizerHeader))
+	if _, err := io.ReadFull(r, header); err != nil || string(header) != fileInternalizerHeader {
+		return fmt.Errorf("not an internalizer log")
+	}
+	fi.size = int64(len(header))
+
+	next := Iid(0)
+	for ; ; next++ {
+		s, n, err := readRecord(r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if !errors.Is(err, io.ErrUnexpectedEOF) && !atEOF(r) {
+				return fmt.Errorf("corrupt log, record at offset %d: %w", fi.size, err)
+			}
+			// Drop the torn record so that the next append starts at a
+			// record boundary.
+			if err := fi.f.Truncate(fi.size); err != nil {
+				return err
+			}
+			break
+		}
+		if i := fi.index.Put(s); i != next {
+			return fmt.Errorf("corrupt log, %q is stored as iid %d and %d", s, i, next)
+		}
+		fi.size += n
+	}
+
+	if _, err := fi.f.Seek(fi.size, io.SeekStart); err != nil {
+		return err
+	}
+	// A log whose seeds were torn lacks some of them. They are stored
+	// before any other string so that the Iids of the log match those of
+	// the index.
+	if int(next) < len(fileInternalizerSeeds) {
+		if err := fi.append(fileInternalizerSeeds[next:]...); err != nil {
+			return err
+		}
+		return fi.f.Sync()
+	}
+	return nil
+}
+
+// The error of a record whose string does not match its checksum.
+var errRecordChecksum = errors.New("record checksum mismatch")
+
+// Reports whether the reader has no bytes left.
+func atEOF(r *bufio.Reader) bool {
+	_, err := r.Peek(1)
+	return errors.Is(err, io.EOF)
+}
+
+// Reads one record and returns the string and the size of the record.
+// Returns io.EOF at the end of the log and another error for a record that
+// is incomplete or fails its checksum.
+func readRecord(r *bufio.Reader) (string, int64, error) {
+	l, err := binary.ReadUvarint(r)
+	if errors.Is(err, io.EOF) {
+		return "", 0, io.EOF
+	}
+	if err != nil {
+		return "", 0, io.ErrUnexpectedEOF
+	}
+	if l > 1<<24 {
+		return "", 0, fmt.Errorf("record length %d too large", l)
+	}
+	buf := make([]byte, l+4)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", 0, io.ErrUnexpectedEOF
+	}
+	s, sum := buf[:l], buf[l:]
+	if crc32.ChecksumIEEE(s) != binary.BigEndian.Uint32(sum) {
+		return "", 0, errRecordChecksum
+	}
+	n := int64(len(binary.AppendUvarint(nil, l))) + int64(len(buf))
+	return string(s), n, nil
+}
+
+// Returns a record for every string.
+func encodeRecords(strs []string) []byte {
+	var rec []byte
+	for _, s := range strs {
+		rec = binary.AppendUvarint(rec, uint64(len(s)))
+		rec = append(rec, s...)
+		rec = binary.BigEndian.AppendUint32(rec, crc32.ChecksumIEEE([]byte(s)))
+	}
+	return rec
+}
+
+// Appends a record for every string to the log with a single write and adds
+// the strings to the index. Must be called with fi.mu held.
+func (fi *FileInternalizer) append(strs ...string) error {
+	rec := encodeRecords(strs)
+	if _, err := fi.f.Write(rec); err != nil {
+		// Do not leave a partial record behind for the next append.
+		fi.f.Truncate(fi.size)
+		fi.f.Seek(fi.size, io.SeekStart)
+		return err
+	}
+	fi.size += int64(len(rec))
+	for _, s := range strs {
+		fi.index.Put(s)
+	}
+	return nil
+}
+
+func (fi *FileInternalizer) GetIid(s string) (Iid, bool) {
+	return fi.index.GetIid(s)
+}
+
+func (fi *FileInternalizer) GetString(i Iid) (string, bool) {
+	return fi.index.GetString(i)
+}
+
+// Returns the Iid of the string, appending it to the log if it is new. If
+// the log cannot be written the Iid of Error is returned and Err reports
+// the failure, as do InternalizeSteps and Plan.Rebind.
+func (fi *FileInternalizer) Put(s string) Iid {
+	if i, ok := fi.index.GetIid(s); ok {
+		return i
+	}
+
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	if i, ok := fi.index.GetIid(s); ok {
+		return i
+	}
+	if fi.err == nil {
+		fi.err = fi.append(s)
+	}
+	if fi.err != nil {
+		return 0
+	}
+	i, _ := fi.index.GetIid(s)
+	return i
+}
+
+// Returns the Iids of the strings, appending the new strings to the log with
+// a single write. If the log cannot be written the Iid of Error is returned
+// for the new strings and Err reports the failure.
+func (fi *FileInternalizer) PutMany(strs []string) []Iid {
+	iids, found := fi.index.GetIids(strs)
+	missing := make([]string, 0)
+	for i, ok := range found {
+		if !ok {
+			missing = append(missing, strs[i])
+		}
+	}
+	if len(missing) == 0 {
+		return iids
+	}
+
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	add := make([]string, 0, len(missing))
+	for _, s := range missing {
+		if _, ok := fi.index.GetIid(s); !ok && !slices.Contains(add, s) {
+			add = append(add, s)
+		}
+	}
+	if fi.err == nil && len(add) > 0 {
+		fi.err = fi.append(add...)
+	}
+	for i, ok := range found {
+		if !ok {
+			iids[i], _ = fi.index.GetIid(strs[i])
+		}
+	}
+	return iids
+}
+
+func (fi *FileInternalizer) GetIids(strs []string) ([]Iid, []bool) {
+	return fi.index.GetIids(strs)
+}
+
+func (fi *FileInternalizer) GetStrings(iids []Iid) ([]string, []bool) {
+	return fi.index.GetStrings(iids)
+}
+
+// Returns the first error writing to the log, if any.
+func (fi *FileInternalizer) Err() error {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	return fi.err
+}
+
+// Returns the number of strings in the dictionary.
+func (fi *FileInternalizer) Len() int {
+	return fi.index.Len()
+}
+
+// Flushes the log to stable storage.
+func (fi *FileInternalizer) Sync() error {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	return fi.f.Sync()
+}
+
+// Syncs and closes the log.
+func (fi *FileInternalizer) Close() error {
+	fi.mu.Lock()
+	defer fi.mu.Unlock()
+	if err := fi.f.Sync(); err != nil {
+		fi.f.Close()
+		return err
+	}
+	return fi.f.Close()
+}
@@ -0,0 +1,279 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func openTestLog(t *testing.T, path string) *FileInternalizer {
+	t.Helper()
+	fi, err := OpenFileInternalizer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fi
+}
+
+func TestFileInternalizerReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iids.log")
+
+	fi := openTestLog(t, path)
+	red := fi.Put("red")
+	blue := fi.Put("blue")
+	if err := fi.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fi = openTestLog(t, path)
+	defer fi.Close()
+	if i, ok := fi.GetIid("red"); !ok || i != red {
+		t.Errorf("Expected red to be iid %d got %d", red, i)
+	}
+	if s, ok := fi.GetString(blue); !ok || s != "blue" {
+		t.Errorf("Expected blue got %s", s)
+	}
+	if i, _ := fi.GetIid("NoMatch"); i != 1 {
+		t.Errorf("Expected NoMatch to be iid 1 got %d", i)
+	}
+	if green := fi.Put("green"); green != blue+1 {
+		t.Errorf("Expected green to be iid %d got %d", blue+1, green)
+	}
+}
+
+func TestFileInternalizerTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iids.log")
+
+	fi := openTestLog(t, path)
+	red := fi.Put("red")
+	fi.Put("a string that is torn")
+	if err := fi.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash part way through writing the last record.
+	st, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, st.Size()-7); err != nil {
+		t.Fatal(err)
+	}
+
+	fi = openTestLog(t, path)
+	if i, ok := fi.GetIid("red"); !ok || i != red {
+		t.Errorf("Expected red to be iid %d got %d", red, i)
+	}
+	if _, ok := fi.GetIid("a string that is torn"); ok {
+		t.Errorf("Expected the torn record to be dropped")
+	}
+	blue := fi.Put("blue")
+	if blue != red+1 {
+		t.Errorf("Expected blue to be iid %d got %d", red+1, blue)
+	}
+	if err := fi.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fi = openTestLog(t, path)
+	defer fi.Close()
+	if i, ok := fi.GetIid("blue"); !ok || i != blue {
+		t.Errorf("Expected blue to survive a reopen as iid %d got %d", blue, i)
+	}
+}
+
+func TestFileInternalizerTornSeeds(t *testing.T) {
+	for _, keep := range []int{0, 1} {
+		path := filepath.Join(t.TempDir(), "iids.log")
+		fi := openTestLog(t, path)
+		if err := fi.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Simulate a crash that left the header and the first keep seeds.
+		size := int64(len(fileInternalizerHeader) + len(encodeRecords(fileInternalizerSeeds[:keep])))
+		if err := os.Truncate(path, size); err != nil {
+			t.Fatal(err)
+		}
+
+		fi = openTestLog(t, path)
+		foo := fi.Put("foo")
+		if foo != 2 {
+			t.Errorf("Expected foo to be iid 2 got %d", foo)
+		}
+		if err := fi.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		fi = openTestLog(t, path)
+		if i, ok := fi.GetIid("foo"); !ok || i != foo {
+			t.Errorf("Expected foo to survive a reopen as iid %d got %d", foo, i)
+		}
+		if i, _ := fi.GetIid("NoMatch"); i != 1 {
+			t.Errorf("Expected NoMatch to be iid 1 got %d", i)
+		}
+		fi.Close()
+	}
+}
+
+func TestFileInternalizerCorruptRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iids.log")
+
+	fi := openTestLog(t, path)
+	red := fi.Put("red")
+	fi.Put("blue")
+	if err := fi.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a bit in the checksum of the last record.
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[len(b)-1] ^= 1
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fi = openTestLog(t, path)
+	defer fi.Close()
+	if i, ok := fi.GetIid("red"); !ok || i != red {
+		t.Errorf("Expected red to be iid %d got %d", red, i)
+	}
+	if _, ok := fi.GetIid("blue"); ok {
+		t.Errorf("Expected the corrupt record to be dropped")
+	}
+}
+
+func TestFileInternalizerCorruptMiddleRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iids.log")
+
+	fi := openTestLog(t, path)
+	fi.Put("red")
+	fi.Put("blue")
+	fi.Put("green")
+	if err := fi.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Flip a bit in the string of a record that has another after it.
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[bytes.Index(b, []byte("blue"))] ^= 1
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenFileInternalizer(path); err == nil || !strings.Contains(err.Error(), "corrupt log") {
+		t.Errorf("Expected a corrupt log error got %v", err)
+	}
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(after, b) {
+		t.Errorf("Expected the log to be left as it was")
+	}
+}
+
+func TestFileInternalizerNotALog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iids.log")
+	if err := os.WriteFile(path, []byte("hello world"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := OpenFileInternalizer(path)
+	if err == nil {
+		t.Errorf("Expected error opening a file that is not a log")
+	}
+}
+
+func TestFileInternalizerConcurrentPut(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iids.log")
+	fi := openTestLog(t, path)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				fi.Put(fmt.Sprintf("iri-%d", i))
+			}
+		}()
+	}
+	wg.Wait()
+	if err := fi.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	re := openTestLog(t, path)
+	defer re.Close()
+	if re.Len() != 202 {
+		t.Errorf("Expected 202 strings got %d", re.Len())
+	}
+	for i := 0; i < 200; i++ {
+		s := fmt.Sprintf("iri-%d", i)
+		a, _ := fi.GetIid(s)
+		b, ok := re.GetIid(s)
+		if !ok || a != b {
+			t.Errorf("Expected %s to be iid %d after reopen got %d", s, a, b)
+		}
+	}
+}
+
+func TestFileInternalizerWriteError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iids.log")
+	fi := openTestLog(t, path)
+	fi.Close()
+
+	if i := fi.Put("red"); i != 0 {
+		t.Errorf("Expected the Error iid got %d", i)
+	}
+	if fi.Err() == nil {
+		t.Errorf("Expected a write error")
+	}
+
+	chain, err := ParseCommand(`Start[iri].HasType[ex:Gremlin].Eval`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := InternalizeSteps(chain, fi); err == nil {
+		t.Errorf("Expected InternalizeSteps to report the write error")
+	}
+}
+
+func TestFileInternalizerPutMany(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "iids.log")
+
+	fi := openTestLog(t, path)
+	red := fi.Put("red")
+	iids := fi.PutMany([]string{"blue", "red", "green", "blue"})
+	if iids[1] != red || iids[0] != iids[3] || iids[0] == iids[2] {
+		t.Errorf("Unexpected iids %v", iids)
+	}
+	if fi.Len() != 5 {
+		t.Errorf("Expected 5 strings got %d", fi.Len())
+	}
+	if err := fi.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fi = openTestLog(t, path)
+	defer fi.Close()
+	got, found := fi.GetIids([]string{"blue", "green"})
+	if !found[0] || !found[1] || got[0] != iids[0] || got[1] != iids[2] {
+		t.Errorf("Expected %v got %v", []Iid{iids[0], iids[2]}, got)
+	}
+	strs, _ := fi.GetStrings(got)
+	if strs[0] != "blue" || strs[1] != "green" {
+		t.Errorf("Unexpected strings %v", strs)
+	}
+}
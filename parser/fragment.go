@@ -0,0 +1,268 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// The pseudo-step that inserts a fragment: Use[Name, arg, ...]
+const useStep = "Use"
+
+// Fragment is a named sequence of steps defined in a rule file, which rules
+// and other fragments insert with Use[Name, arg, ...]. The arguments are
+// bound to the parameters of the fragment, written $name in its steps.
+//
+//	def Monster = Or(HasType[zoo:Gremlin].HasType[zoo:GooGrok])
+//	def Eats(food) = Follow[zoo:Eats].HasType[$food]
+//
+//	rule HungryMonsters {
+//	    Start[iri].Use[Monster].Use[Eats, zoo:TastyMeal].Eval
+//	}
+type Fragment struct {
+	Name      string
+	Params    []string
+	Comments  []string  // comments before the definition
+	Chain     []Step    // the steps as written, without Start and Eval
+	Line      int       // line of the def keyword
+	Positions []StepPos // where the steps are written in the file
+
+	uses []int // lines of the Use steps of the chain, in order
+}
+
+var paramName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Returns the fragment with the given name.
+func (rs *RuleSet) Fragment(name string) (*Fragment, bool) {
+	for i := range rs.Fragments {
+		if rs.Fragments[i].Name == name {
+			return &rs.Fragments[i], true
+		}
+	}
+	return nil, false
+}
+
+// Replaces the Use steps of a chain with the steps of the fragments they
+// name. uses holds the line of every Use step of the chain, so that errors
+// point to it, and stack the fragments being expanded, to detect cycles.
+func (rs *RuleSet) expand(chain []Step, uses []int, stack []string) ([]Step, error) {
+	n := 0
+	var walk func(chain []Step, inor bool) ([]Step, error)
+	walk = func(chain []Step, inor bool) ([]Step, error) {
+		out := make([]Step, 0, len(chain))
+		for _, s := range chain {
+			if s.token != useStep {
+				if s.subcmd != nil {
+					sub, err := walk(s.subcmd, true)
+					if err != nil {
+						return nil, err
+					}
+					s.subcmd = sub
+				}
+				out = append(out, s)
+				continue
+			}
+
+			line := 0
+			if n < len(uses) {
+				line = uses[n]
+			}
+			n++
+			steps, err := rs.use(s, stack)
+			if err == nil && inor && len(steps) != 1 {
+				f, _ := rs.Fragment(s.arg)
+				err = fmt.Errorf("fragment %s (line %d) has %d steps, an alternative must be a single step", f.Name, f.Line, len(steps))
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s at line %d: %w", formatUse(s), line, err)
+			}
+			out = append(out, steps...)
+		}
+		return out, nil
+	}
+	return walk(chain, false)
+}
+
+// Returns the steps a Use step stands for.
+func (rs *RuleSet) use(s Step, stack []string) ([]Step, error) {
+	f, ok := rs.Fragment(s.arg)
+	if !ok {
+		return nil, fmt.Errorf("unknown fragment %s", s.arg)
+	}
+	if slices.Contains(stack, f.Name) {
+		return nil, fmt.Errorf("fragment %s (line %d) uses itself: %s", f.Name, f.Line, strings.Join(append(stack, f.Name), " -> "))
+	}
+	if len(s.vals) != len(f.Params) {
+		return nil, fmt.Errorf("fragment %s (line %d) expects %d arguments got %d", f.Name, f.Line, len(f.Params), len(s.vals))
+	}
+
+	args := make(map[string]string, len(f.Params))
+	for i, p := range f.Params {
+		args["$"+p] = s.vals[i]
+	}
+	steps, err := rs.expand(bindParams(f.Chain, args), f.uses, append(slices.Clone(stack), f.Name))
+	if err != nil {
+		return nil, fmt.Errorf("fragment %s (line %d): %w", f.Name, f.Line, err)
+	}
+	return steps, nil
+}
+
+// Returns a copy of the chain with the parameters replaced by their
+// arguments.
+func bindParams(chain []Step, args map[string]string) []Step {
+	bind := func(a string) string {
+		if v, ok := args[a]; ok {
+			return v
+		}
+		return a
+	}
+	out := make([]Step, len(chain))
+	for i, s := range chain {
+		out[i] = s
+		out[i].arg = bind(s.arg)
+		if s.vals != nil {
+			out[i].vals = make([]string, len(s.vals))
+			for j, v := range s.vals {
+				out[i].vals[j] = bind(v)
+			}
+		}
+		if s.subcmd != nil {
+			out[i].subcmd = bindParams(s.subcmd, args)
+		}
+	}
+	return out
+}
+
+// Returns the first parameter of the chain that is not one of params.
+func unknownParam(chain []Step, params []string) (string, bool) {
+	for _, s := range chain {
+		for _, a := range append([]string{s.arg}, s.vals...) {
+			if strings.HasPrefix(a, "$") && !slices.Contains(params, a[1:]) {
+				return a, true
+			}
+		}
+		if p, ok := unknownParam(s.subcmd, params); ok {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// Returns the first Use step of the chain.
+func findUse(chain []Step) (Step, bool) {
+	for _, s := range chain {
+		if s.token == useStep {
+			return s, true
+		}
+		if u, ok := findUse(s.subcmd); ok {
+			return u, true
+		}
+	}
+	return Step{}, false
+}
+
+func formatUse(s Step) string {
+	args := append([]string{s.arg}, s.vals...)
+	for i, a := range args {
+		args[i] = bremlinArg(a)
+	}
+	return useStep + "[" + strings.Join(args, ", ") + "]"
+}
+
+var useCall = regexp.MustCompile(`(^|[\s.(])Use\s*\[`)
+
+// Returns the lines of the Use steps of a command that starts on the given
+// line. The comments of the command must have been blanked.
+func useLines(cmd string, line int) []int {
+	lines := make([]int, 0)
+	for _, m := range useCall.FindAllStringIndex(cmd, -1) {
+		lines = append(lines, line+strings.Count(cmd[:m[0]], "\n"))
+	}
+	return lines
+}
+
+// Parses the rest of a fragment definition: Name(params) = steps
+//
+// The steps run to the end of the line, or further while brackets are open
+// or the next line starts with a dot.
+func (p *ruleParser) def() (Fragment, error) {
+	p.skipSpace()
+	head := p.pos
+	for !p.eof() && strings.IndexByte(" \t\r\n(=", p.src[p.pos]) < 0 {
+		p.pos++
+	}
+	name := p.src[head:p.pos]
+	if !ruleName.MatchString(name) {
+		return Fragment{}, fmt.Errorf("invalid fragment name %q", name)
+	}
+
+	var params []string
+	p.skipSpace()
+	if !p.eof() && p.src[p.pos] == '(' {
+		end := strings.IndexByte(p.src[p.pos:], ')')
+		if end < 0 {
+			return Fragment{}, fmt.Errorf("expected def %s(param, ...) = steps", name)
+		}
+		for _, param := range strings.Split(p.src[p.pos+1:p.pos+end], ",") {
+			param = strings.TrimSpace(param)
+			if !paramName.MatchString(param) || slices.Contains(params, param) {
+				return Fragment{}, fmt.Errorf("invalid parameter %q of fragment %s", param, name)
+			}
+			params = append(params, param)
+		}
+		p.pos += end + 1
+		p.skipSpace()
+	}
+	if p.eof() || p.src[p.pos] != '=' {
+		return Fragment{}, fmt.Errorf("expected = after def %s", name)
+	}
+	p.pos++
+
+	start := p.pos
+	for {
+		p.restOfLine()
+		if !p.eof() && !Balanced(p.src[start:p.pos]) {
+			p.pos++
+			continue
+		}
+		next := p.pos
+		for next < len(p.src) && isSpace(p.src[next]) {
+			next++
+		}
+		if next < len(p.src) && p.src[next] == '.' {
+			p.pos = next
+			continue
+		}
+		break
+	}
+	body := p.orig[start:p.pos]
+	if strings.TrimSpace(body) == "" {
+		return Fragment{}, fmt.Errorf("fragment %s has no steps", name)
+	}
+
+	// Parsed as a command, so that the steps are checked the same way.
+	const wrap = "Start[iri].\n"
+	cmd := wrap + body + "\n.Eval"
+	chain, err := parseCommand(cmd)
+	if err != nil {
+		return Fragment{}, fmt.Errorf("fragment %s: %w", name, err)
+	}
+	positions := make([]StepPos, 0)
+	for _, pos := range StepPositions(cmd, chain) {
+		if pos.Start >= len(wrap) && pos.Start < len(wrap)+len(body) {
+			positions = append(positions, pos)
+		}
+	}
+	chain = chain[1 : len(chain)-1]
+	if s, ok := unknownParam(chain, params); ok {
+		return Fragment{}, fmt.Errorf("fragment %s: unknown parameter %s", name, s)
+	}
+	return Fragment{
+		Name:      name,
+		Params:    params,
+		Chain:     chain,
+		Positions: shiftPositions(positions, start-len(wrap), -1),
+		uses:      useLines(p.src[start:p.pos], strings.Count(p.src[:start], "\n")+1),
+	}, nil
+}
@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const fragmentRules = `PREFIX zoo: <http://example.org/>
+
+def Monster = Or(HasType[zoo:Gremlin].HasType[zoo:GooGrok])
+def Eats(food) = Follow[zoo:Eats]
+	.HasType[$food]
+
+rule HungryMonsters {
+	Start[iri]
+	.Use[Monster]
+	.Use[Eats, zoo:TastyMeal]
+	.Eval
+}
+
+rule Picky { Start[iri].Or(Use[Monster].IsActive[]).Eval }
+`
+
+func TestFragments(t *testing.T) {
+	rs, err := ParseRuleSet(fragmentRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs.Fragments) != 2 {
+		t.Fatalf("Expected 2 fragments got %d", len(rs.Fragments))
+	}
+	f, ok := rs.Fragment("Eats")
+	if !ok || f.Line != 4 || !reflect.DeepEqual(f.Params, []string{"food"}) {
+		t.Errorf("Unexpected fragment %+v", f)
+	}
+
+	r, _ := rs.Rule("HungryMonsters")
+	expected := "Start[iri].Or[](HasType[ex:Gremlin].HasType[ex:GooGrok]).Follow[ex:Eats].HasType[ex:TastyMeal].Eval[]"
+	if chainString(r.Chain) != expected {
+		t.Errorf("Expected %s got %s", expected, chainString(r.Chain))
+	}
+	r, _ = rs.Rule("Picky")
+	expected = "Start[iri].Or[](Or[](HasType[ex:Gremlin].HasType[ex:GooGrok]).IsActive[]).Eval[]"
+	if chainString(r.Chain) != expected {
+		t.Errorf("Expected %s got %s", expected, chainString(r.Chain))
+	}
+}
+
+func TestFragmentErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{"def A = Use[B]\ndef B = Use[A]", "line 1: fragment A: Use[B] at line 1: fragment B (line 2): Use[A] at line 2: fragment A (line 1) uses itself: A -> B -> A"},
+		{"def A(x) = HasType[$x]\nrule R {\n Start[iri]\n .Use[A]\n .Eval\n}", "line 2: rule R: Use[A] at line 4: fragment A (line 1) expects 1 arguments got 0"},
+		{"def A = HasType[a].IsActive[]\nrule R { Start[iri].Or(Use[A].HasType[b]).Eval }", "Use[A] at line 2: fragment A (line 1) has 2 steps"},
+		{"rule R { Start[iri].Use[Nope].Eval }", "line 1: rule R: Use[Nope] at line 1: unknown fragment Nope"},
+		{"def A = HasType[$y]", "line 1: fragment A: unknown parameter $y"},
+		{"def A = Bogus[]", "line 1: fragment A: unknown step Bogus"},
+		{"def A = IsActive[]\ndef A = IsActive[]", "line 2: fragment A is defined more than once"},
+		{"def A(x, x) = HasType[$x]", "invalid parameter \"x\""},
+		{"def A IsActive[]", "expected = after def A"},
+		{"def A =", "fragment A has no steps"},
+	}
+	for _, test := range tests {
+		_, err := ParseRuleSet(test.src)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: expected error containing %q got %v", test.src, test.err, err)
+		}
+	}
+}
+
+func TestUseOutsideRuleFile(t *testing.T) {
+	_, err := ParseCommand("Start[iri].Use[Monster].Eval")
+	if err == nil || !strings.Contains(err.Error(), "unknown fragment Monster") {
+		t.Errorf("Expected unknown fragment error got %v", err)
+	}
+}
+
+func TestFormatFragments(t *testing.T) {
+	rs, err := ParseRuleSet(fragmentRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := FormatRuleSet(rs)
+	for _, s := range []string{
+		"def Monster = Or(\n\tHasType[zoo:Gremlin]\n\t.HasType[zoo:GooGrok]\n)\n",
+		"def Eats(food) = Follow[zoo:Eats]\n.HasType[$food]\n",
+		"\t.Use[Eats, zoo:TastyMeal]\n",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Expected %q in\n%s", s, out)
+		}
+	}
+
+	again, err := ParseRuleSet(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if FormatRuleSet(again) != out {
+		t.Errorf("Expected formatting to be stable got\n%s", FormatRuleSet(again))
+	}
+}
@@ -0,0 +1,875 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Converts a SPARQL SELECT query to a chain of steps, using the default
+// vocabulary.
+func FromSPARQL(query string) ([]Step, error) {
+	t := SPARQLTranslator{Vocabulary: DefaultVocabulary()}
+	return t.Parse(query)
+}
+
+// Converts a SPARQL SELECT query to a chain of steps. Only the subset of
+// SPARQL that can be expressed as a chain is supported: a single selected
+// variable at the end of a path of triple patterns, rdf:type and the
+// vocabulary predicates, sequence and inverse property paths, UNION, VALUES,
+// FILTER IN, FILTER NOT EXISTS for inactive nodes and a LIMIT, which becomes
+// a Limit at the end of the chain.
+func (t *SPARQLTranslator) Parse(query string) ([]Step, error) {
+	toks, err := lexSPARQL(query)
+	if err != nil {
+		return nil, err
+	}
+	p := sparqlParser{
+		toks:     toks,
+		prefixes: make(PrefixMap),
+		vocab:    t.Vocabulary,
+	}
+	return p.query()
+}
+
+type sparqlTokenKind int
+
+const (
+	tokEOF sparqlTokenKind = iota
+	tokIRI
+	tokPName
+	tokVar
+	tokString
+	tokNumber
+	tokWord
+	tokPunct
+)
+
+type sparqlToken struct {
+	kind sparqlTokenKind
+	val  string
+	line int
+}
+
+func (t sparqlToken) String() string {
+	if t.kind == tokEOF {
+		return "end of query"
+	}
+	return fmt.Sprintf("%q on line %d", t.val, t.line)
+}
+
+func lexSPARQL(src string) ([]sparqlToken, error) {
+	toks := make([]sparqlToken, 0)
+	line := 1
+	rs := []rune(src)
+	isWord := func(r rune) bool {
+		return r == '_' || r == '-' || r == ':' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case r == '\n':
+			line++
+			i++
+		case unicode.IsSpace(r):
+			i++
+		case r == '#':
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+		case r == '<':
+			j := i + 1
+			for j < len(rs) && rs[j] != '>' && !unicode.IsSpace(rs[j]) {
+				j++
+			}
+			if j == len(rs) || rs[j] != '>' {
+				// A lone < is the less than operator.
+				toks = append(toks, sparqlToken{tokPunct, "<", line})
+				i++
+				continue
+			}
+			toks = append(toks, sparqlToken{tokIRI, string(rs[i+1 : j]), line})
+			i = j + 1
+		case r == '?' || r == '$':
+			j := i + 1
+			for j < len(rs) && (rs[j] == '_' || unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j])) {
+				j++
+			}
+			if j == i+1 {
+				toks = append(toks, sparqlToken{tokPunct, string(r), line})
+				i++
+				continue
+			}
+			toks = append(toks, sparqlToken{tokVar, string(rs[i+1 : j]), line})
+			i = j
+		case r == '"' || r == '\'':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(rs) && rs[j] != r; j++ {
+				if rs[j] == '\\' && j+1 < len(rs) {
+					j++
+					b.WriteRune(unescape(rs[j]))
+					continue
+				}
+				if rs[j] == '\n' {
+					return nil, fmt.Errorf("newline in string on line %d", line)
+				}
+				b.WriteRune(rs[j])
+			}
+			if j == len(rs) {
+				return nil, fmt.Errorf("unterminated string on line %d", line)
+			}
+			toks = append(toks, sparqlToken{tokString, b.String(), line})
+			i = j + 1
+			// Language tags and datatypes are accepted but not retained.
+			if i < len(rs) && rs[i] == '@' {
+				for i++; i < len(rs) && (rs[i] == '-' || unicode.IsLetter(rs[i])); i++ {
+				}
+			} else if i+1 < len(rs) && rs[i] == '^' && rs[i+1] == '^' {
+				for i += 2; i < len(rs) && !unicode.IsSpace(rs[i]) && !strings.ContainsRune(".;,)}", rs[i]); i++ {
+				}
+			}
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || (rs[j] == '.' && j+1 < len(rs) && unicode.IsDigit(rs[j+1]))) {
+				j++
+			}
+			toks = append(toks, sparqlToken{tokNumber, string(rs[i:j]), line})
+			i = j
+		case isWord(r) && r != '.' && r != '-':
+			j := i
+			for j < len(rs) && isWord(rs[j]) {
+				j++
+			}
+			// A trailing period ends the triple.
+			for j > i && rs[j-1] == '.' {
+				j--
+			}
+			w := string(rs[i:j])
+			kind := tokWord
+			if strings.Contains(w, ":") {
+				kind = tokPName
+			}
+			toks = append(toks, sparqlToken{kind, w, line})
+			i = j
+		default:
+			toks = append(toks, sparqlToken{tokPunct, string(r), line})
+			i++
+		}
+	}
+	return append(toks, sparqlToken{kind: tokEOF, line: line}), nil
+}
+
+type sparqlParser struct {
+	toks     []sparqlToken
+	pos      int
+	prefixes PrefixMap
+	vocab    Vocabulary
+}
+
+// A term in a triple pattern.
+type sparqlTerm struct {
+	val     string
+	isVar   bool
+	literal bool
+}
+
+type sparqlPathElem struct {
+	iri     string
+	inverse bool
+}
+
+// The patterns of a group, in the order they appear.
+type sparqlItem struct {
+	kind   string // triple, values, notexists, union, bind
+	s, o   sparqlTerm
+	path   []sparqlPathElem
+	vals   []sparqlTerm
+	groups [][]sparqlItem
+	line   int
+}
+
+func (p *sparqlParser) peek() sparqlToken {
+	return p.toks[p.pos]
+}
+
+func (p *sparqlParser) next() sparqlToken {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *sparqlParser) isKeyword(kw string) bool {
+	t := p.peek()
+	return t.kind == tokWord && strings.EqualFold(t.val, kw)
+}
+
+func (p *sparqlParser) isPunct(c string) bool {
+	t := p.peek()
+	return t.kind == tokPunct && t.val == c
+}
+
+func (p *sparqlParser) expectKeyword(kw string) error {
+	if !p.isKeyword(kw) {
+		return fmt.Errorf("expected %s got %s", kw, p.peek())
+	}
+	p.next()
+	return nil
+}
+
+func (p *sparqlParser) expectPunct(c string) error {
+	if !p.isPunct(c) {
+		return fmt.Errorf("expected %s got %s", c, p.peek())
+	}
+	p.next()
+	return nil
+}
+
+func unsupported(t sparqlToken) error {
+	return fmt.Errorf("unsupported SPARQL construct %s", t)
+}
+
+func (p *sparqlParser) query() ([]Step, error) {
+	for p.isKeyword("PREFIX") {
+		p.next()
+		name := p.next()
+		if name.kind != tokPName || !strings.HasSuffix(name.val, ":") {
+			return nil, fmt.Errorf("expected prefix name got %s", name)
+		}
+		iri := p.next()
+		if iri.kind != tokIRI {
+			return nil, fmt.Errorf("expected <iri> got %s", iri)
+		}
+		p.prefixes[strings.TrimSuffix(name.val, ":")] = iri.val
+	}
+
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+	if p.isKeyword("DISTINCT") || p.isKeyword("REDUCED") {
+		p.next()
+	}
+	sel := p.next()
+	if sel.kind != tokVar {
+		return nil, fmt.Errorf("expected a single selected variable got %s", sel)
+	}
+	if p.peek().kind == tokVar {
+		return nil, fmt.Errorf("expected a single selected variable got %s", p.peek())
+	}
+	if p.isKeyword("WHERE") {
+		p.next()
+	}
+
+	items, err := p.group()
+	if err != nil {
+		return nil, err
+	}
+	limit := ""
+	if p.isKeyword("LIMIT") {
+		p.next()
+		n := p.next()
+		if _, err := limitCount(n.val); n.kind != tokNumber || err != nil {
+			return nil, fmt.Errorf("expected a positive count after LIMIT got %s", n)
+		}
+		limit = n.val
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, unsupported(t)
+	}
+
+	steps, err := p.chain(items, sel.val)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := make([]Step, 0, len(steps)+3)
+	chain = append(chain, Step{token: "Start", arg: "iri"})
+	chain = append(chain, steps...)
+	if limit != "" {
+		chain = append(chain, Step{token: "Limit", arg: limit})
+	}
+	return append(chain, Step{token: "Eval"}), nil
+}
+
+// Parses a group graph pattern enclosed in braces.
+func (p *sparqlParser) group() ([]sparqlItem, error) {
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	items := make([]sparqlItem, 0)
+
+	for !p.isPunct("}") {
+		t := p.peek()
+		switch {
+		case t.kind == tokEOF:
+			return nil, fmt.Errorf("expected } got %s", t)
+		case p.isPunct("."):
+			p.next()
+		case p.isPunct("{"):
+			item, err := p.union()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		case p.isKeyword("VALUES"):
+			p.next()
+			v := p.next()
+			if v.kind != tokVar {
+				return nil, unsupported(v)
+			}
+			if err := p.expectPunct("{"); err != nil {
+				return nil, err
+			}
+			vals, err := p.terms("}")
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, sparqlItem{kind: "values", s: sparqlTerm{val: v.val, isVar: true}, vals: vals, line: t.line})
+		case p.isKeyword("FILTER"):
+			item, err := p.filter()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		case p.isKeyword("BIND"):
+			p.next()
+			if err := p.expectPunct("("); err != nil {
+				return nil, err
+			}
+			from := p.next()
+			if from.kind != tokVar || !p.isKeyword("AS") {
+				return nil, unsupported(from)
+			}
+			p.next()
+			to := p.next()
+			if to.kind != tokVar {
+				return nil, unsupported(to)
+			}
+			if err := p.expectPunct(")"); err != nil {
+				return nil, err
+			}
+			items = append(items, sparqlItem{
+				kind: "bind",
+				s:    sparqlTerm{val: from.val, isVar: true},
+				o:    sparqlTerm{val: to.val, isVar: true},
+				line: t.line,
+			})
+		case t.kind == tokVar || t.kind == tokIRI || t.kind == tokPName:
+			triples, err := p.triples()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, triples...)
+		default:
+			return nil, unsupported(t)
+		}
+	}
+	p.next()
+	return items, nil
+}
+
+// Parses { ... } UNION { ... } ...
+func (p *sparqlParser) union() (sparqlItem, error) {
+	item := sparqlItem{kind: "union", line: p.peek().line}
+	for {
+		g, err := p.group()
+		if err != nil {
+			return item, err
+		}
+		item.groups = append(item.groups, g)
+		if !p.isKeyword("UNION") {
+			break
+		}
+		p.next()
+	}
+	if len(item.groups) == 1 {
+		return item, fmt.Errorf("nested groups without UNION are not supported on line %d", item.line)
+	}
+	return item, nil
+}
+
+func (p *sparqlParser) filter() (sparqlItem, error) {
+	line := p.next().line
+	if p.isKeyword("NOT") {
+		p.next()
+		if err := p.expectKeyword("EXISTS"); err != nil {
+			return sparqlItem{}, err
+		}
+		g, err := p.group()
+		if err != nil {
+			return sparqlItem{}, err
+		}
+		return sparqlItem{kind: "notexists", groups: [][]sparqlItem{g}, line: line}, nil
+	}
+
+	if err := p.expectPunct("("); err != nil {
+		return sparqlItem{}, err
+	}
+	v := p.next()
+	if v.kind != tokVar {
+		return sparqlItem{}, unsupported(v)
+	}
+	var vals []sparqlTerm
+	switch {
+	case p.isKeyword("IN"):
+		p.next()
+		if err := p.expectPunct("("); err != nil {
+			return sparqlItem{}, err
+		}
+		var err error
+		vals, err = p.terms(")")
+		if err != nil {
+			return sparqlItem{}, err
+		}
+	case p.isPunct("="):
+		p.next()
+		t, err := p.term()
+		if err != nil {
+			return sparqlItem{}, err
+		}
+		vals = []sparqlTerm{t}
+	default:
+		return sparqlItem{}, unsupported(p.peek())
+	}
+	if err := p.expectPunct(")"); err != nil {
+		return sparqlItem{}, err
+	}
+	return sparqlItem{kind: "values", s: sparqlTerm{val: v.val, isVar: true}, vals: vals, line: line}, nil
+}
+
+// Parses a list of terms, optionally separated by commas, up to end.
+func (p *sparqlParser) terms(end string) ([]sparqlTerm, error) {
+	terms := make([]sparqlTerm, 0)
+	for !p.isPunct(end) {
+		if p.isPunct(",") {
+			p.next()
+			continue
+		}
+		t, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, t)
+	}
+	p.next()
+	return terms, nil
+}
+
+// Parses the triples of a subject with its predicate and object lists.
+func (p *sparqlParser) triples() ([]sparqlItem, error) {
+	line := p.peek().line
+	s, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	if !s.isVar {
+		return nil, fmt.Errorf("expected a variable as subject on line %d", line)
+	}
+
+	items := make([]sparqlItem, 0)
+	for {
+		var path []sparqlPathElem
+		var pvar bool
+		if p.peek().kind == tokVar {
+			p.next()
+			pvar = true
+		} else {
+			path, err = p.path()
+			if err != nil {
+				return nil, err
+			}
+		}
+		for {
+			o, err := p.term()
+			if err != nil {
+				return nil, err
+			}
+			if pvar {
+				// Patterns like ?s ?p ?o only bind the subject.
+				if !o.isVar {
+					return nil, fmt.Errorf("variable predicates are not supported on line %d", line)
+				}
+			} else {
+				items = append(items, sparqlItem{kind: "triple", s: s, path: path, o: o, line: line})
+			}
+			if !p.isPunct(",") {
+				break
+			}
+			p.next()
+		}
+		if !p.isPunct(";") {
+			break
+		}
+		p.next()
+		if p.isPunct(".") || p.isPunct("}") {
+			break
+		}
+	}
+	return items, nil
+}
+
+// Parses a sequence path of predicates and inverse predicates.
+func (p *sparqlParser) path() ([]sparqlPathElem, error) {
+	path := make([]sparqlPathElem, 0)
+	for {
+		inverse := false
+		if p.isPunct("^") {
+			p.next()
+			inverse = true
+		}
+		t := p.peek()
+		var iri string
+		if t.kind == tokWord && t.val == "a" {
+			p.next()
+			iri = rdfType
+		} else {
+			term, err := p.term()
+			if err != nil {
+				return nil, err
+			}
+			if term.isVar || term.literal {
+				return nil, unsupported(t)
+			}
+			iri = term.val
+		}
+		path = append(path, sparqlPathElem{iri: iri, inverse: inverse})
+		if !p.isPunct("/") {
+			break
+		}
+		p.next()
+	}
+	if t := p.peek(); t.kind == tokPunct && strings.Contains("*+?|!", t.val) {
+		return nil, fmt.Errorf("unsupported property path operator %s", t)
+	}
+	return path, nil
+}
+
+func (p *sparqlParser) term() (sparqlTerm, error) {
+	t := p.next()
+	switch t.kind {
+	case tokVar:
+		return sparqlTerm{val: t.val, isVar: true}, nil
+	case tokIRI:
+		return sparqlTerm{val: compactIri(t.val)}, nil
+	case tokPName:
+		i := strings.IndexByte(t.val, ':')
+		ns, ok := p.prefixes[t.val[:i]]
+		if !ok {
+			return sparqlTerm{}, fmt.Errorf("undefined prefix %s on line %d", t.val[:i+1], t.line)
+		}
+		return sparqlTerm{val: compactIri(ns + t.val[i+1:])}, nil
+	case tokString, tokNumber:
+		return sparqlTerm{val: t.val, literal: true}, nil
+	case tokWord:
+		if t.val == "true" || t.val == "false" {
+			return sparqlTerm{val: t.val, literal: true}, nil
+		}
+	}
+	return sparqlTerm{}, unsupported(t)
+}
+
+// A traversal between two variables.
+type sparqlEdge struct {
+	from, to string
+	steps    []Step
+	line     int
+}
+
+// The steps filtering each variable and the traversals between variables.
+type sparqlPattern struct {
+	filters map[string][]Step
+	edges   []sparqlEdge
+	vars    []string
+}
+
+func (sp *sparqlPattern) addVar(v string) {
+	if !slices.Contains(sp.vars, v) {
+		sp.vars = append(sp.vars, v)
+	}
+}
+
+func (sp *sparqlPattern) addFilter(v string, s Step) {
+	sp.addVar(v)
+	sp.filters[v] = append(sp.filters[v], s)
+}
+
+// Resolves the items of a group into filters and traversals.
+func (p *sparqlParser) resolve(items []sparqlItem) (*sparqlPattern, error) {
+	sp := &sparqlPattern{filters: make(map[string][]Step)}
+
+	// Variables restricted to literals are values of HasValue steps, those
+	// restricted to IRIs are IsInstance steps.
+	values := make(map[string][]string)
+	for _, it := range items {
+		if it.kind != "values" {
+			continue
+		}
+		lits := make([]string, 0)
+		iris := make([]string, 0)
+		for _, v := range it.vals {
+			if v.literal {
+				lits = append(lits, v.val)
+			} else if !v.isVar {
+				iris = append(iris, v.val)
+			}
+		}
+		if len(lits) > 0 && len(iris) > 0 {
+			return nil, fmt.Errorf("cannot mix literals and IRIs in VALUES on line %d", it.line)
+		}
+		if len(lits) > 0 {
+			values[it.s.val] = lits
+		}
+	}
+
+	broader := make(map[string][]string)
+	for _, it := range items {
+		switch it.kind {
+		case "values":
+			if _, ok := values[it.s.val]; ok {
+				continue
+			}
+			alts := make([]Step, 0, len(it.vals))
+			for _, v := range it.vals {
+				alts = append(alts, Step{token: "IsInstance", arg: v.val})
+			}
+			if len(alts) == 1 {
+				sp.addFilter(it.s.val, alts[0])
+			} else {
+				sp.addFilter(it.s.val, Step{token: "Or", subcmd: alts})
+			}
+		case "triple":
+			if err := p.resolveTriple(sp, it, values, broader); err != nil {
+				return nil, err
+			}
+		case "notexists":
+			g := it.groups[0]
+			if len(g) != 1 || g[0].kind != "triple" || !p.isActive(g[0]) {
+				return nil, fmt.Errorf("only FILTER NOT EXISTS { ?s %s %s } is supported on line %d",
+					p.vocab.Active, p.vocab.ActiveValue, it.line)
+			}
+			sp.addFilter(g[0].s.val, Step{token: "IsInactive"})
+		case "bind":
+			sp.addVar(it.s.val)
+			sp.addVar(it.o.val)
+			sp.edges = append(sp.edges, sparqlEdge{from: it.s.val, to: it.o.val, line: it.line})
+		case "union":
+			if err := p.resolveUnion(sp, it); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// HasBroader is expressed as an inScheme and a broader pattern on the
+	// same variable.
+	for v, targets := range broader {
+		for _, target := range targets {
+			i := slices.IndexFunc(sp.filters[v], func(s Step) bool { return s.token == "InScheme" })
+			if i < 0 {
+				return nil, fmt.Errorf("%s on ?%s requires a matching %s pattern", p.vocab.Broader, v, p.vocab.InScheme)
+			}
+			sp.filters[v][i] = Step{token: "HasBroader", arg: sp.filters[v][i].arg, vals: []string{target}}
+		}
+	}
+
+	return sp, nil
+}
+
+func (p *sparqlParser) isActive(it sparqlItem) bool {
+	return len(it.path) == 1 && !it.path[0].inverse && it.path[0].iri == p.vocab.Active &&
+		it.o.literal && it.o.val == p.vocab.ActiveValue
+}
+
+func (p *sparqlParser) resolveTriple(sp *sparqlPattern, it sparqlItem, values map[string][]string, broader map[string][]string) error {
+	s := it.s.val
+	sp.addVar(s)
+
+	if it.o.isVar {
+		if vals, ok := values[it.o.val]; ok {
+			if len(it.path) != 1 || it.path[0].inverse {
+				return fmt.Errorf("expected a single predicate for ?%s on line %d", it.o.val, it.line)
+			}
+			sp.addFilter(s, Step{token: "HasValue", arg: it.path[0].iri, vals: vals})
+			return nil
+		}
+		steps := make([]Step, len(it.path))
+		for i, e := range it.path {
+			steps[i] = Step{token: "Follow", arg: e.iri}
+			if e.inverse {
+				steps[i].token = "FollowInverse"
+			}
+		}
+		sp.addVar(it.o.val)
+		sp.edges = append(sp.edges, sparqlEdge{from: s, to: it.o.val, steps: steps, line: it.line})
+		return nil
+	}
+
+	if len(it.path) != 1 || it.path[0].inverse {
+		return fmt.Errorf("property paths must end in a variable on line %d", it.line)
+	}
+	pred := it.path[0].iri
+
+	if it.o.literal {
+		if p.isActive(it) {
+			sp.addFilter(s, Step{token: "IsActive"})
+			return nil
+		}
+		sp.addFilter(s, Step{token: "HasValue", arg: pred, vals: []string{it.o.val}})
+		return nil
+	}
+
+	switch pred {
+	case p.vocab.Type:
+		sp.addFilter(s, Step{token: "HasType", arg: it.o.val})
+	case p.vocab.Category:
+		sp.addFilter(s, Step{token: "HasCategory", arg: it.o.val})
+	case p.vocab.InScheme:
+		sp.addFilter(s, Step{token: "InScheme", arg: it.o.val})
+	case p.vocab.Broader:
+		broader[s] = append(broader[s], it.o.val)
+	default:
+		return fmt.Errorf("cannot convert ?%s %s %s on line %d, only %s, %s, %s and %s may have an IRI object",
+			s, pred, it.o.val, it.line, p.vocab.Type, p.vocab.Category, p.vocab.InScheme, p.vocab.Broader)
+	}
+	return nil
+}
+
+// Resolves a UNION to an Or step. Every alternative must translate to a
+// single step on the same variable.
+func (p *sparqlParser) resolveUnion(sp *sparqlPattern, it sparqlItem) error {
+	var from, to string
+	alts := make([]Step, 0, len(it.groups))
+
+	for _, g := range it.groups {
+		bp, err := p.resolve(g)
+		if err != nil {
+			return err
+		}
+		var f, t string
+		var step Step
+		switch len(bp.edges) {
+		case 0:
+			if len(bp.vars) != 1 || len(bp.filters[bp.vars[0]]) != 1 {
+				return fmt.Errorf("every UNION alternative must be a single step on line %d", it.line)
+			}
+			f = bp.vars[0]
+			step = bp.filters[f][0]
+		case 1:
+			e := bp.edges[0]
+			f, t = e.from, e.to
+			switch {
+			case len(e.steps) == 1 && len(bp.filters) == 0:
+				step = e.steps[0]
+			case len(e.steps) == 0 && len(bp.filters[f]) == 1 && len(bp.filters) == 1:
+				step = bp.filters[f][0]
+			default:
+				return fmt.Errorf("every UNION alternative must be a single step on line %d", it.line)
+			}
+		default:
+			return fmt.Errorf("every UNION alternative must be a single step on line %d", it.line)
+		}
+
+		if from == "" {
+			from = f
+		}
+		if to == "" {
+			to = t
+		}
+		if f != from || (t != "" && t != to) {
+			return fmt.Errorf("UNION alternatives must use the same variables on line %d", it.line)
+		}
+		alts = append(alts, step)
+	}
+
+	or := Step{token: "Or", subcmd: alts}
+	if to == "" {
+		sp.addFilter(from, or)
+		return nil
+	}
+	sp.addVar(from)
+	sp.addVar(to)
+	sp.edges = append(sp.edges, sparqlEdge{from: from, to: to, steps: []Step{or}, line: it.line})
+	return nil
+}
+
+// Orders the filters and traversals into a chain ending at sel.
+func (p *sparqlParser) chain(items []sparqlItem, sel string) ([]Step, error) {
+	sp, err := p.resolve(items)
+	if err != nil {
+		return nil, err
+	}
+
+	degree := make(map[string]int)
+	for _, e := range sp.edges {
+		degree[e.from]++
+		degree[e.to]++
+	}
+	for _, v := range sp.vars {
+		if degree[v] > 2 {
+			return nil, fmt.Errorf("?%s branches, only a single path of variables is supported", v)
+		}
+	}
+	if len(sp.edges) > 0 && degree[sel] != 1 {
+		return nil, fmt.Errorf("selected variable ?%s must be at the end of the path", sel)
+	}
+
+	// Walk backwards from the selected variable to find the start.
+	edges := slices.Clone(sp.edges)
+	path := []string{sel}
+	walk := make([]sparqlEdge, 0)
+	for cur := sel; ; {
+		i := slices.IndexFunc(edges, func(e sparqlEdge) bool { return e.from == cur || e.to == cur })
+		if i < 0 {
+			break
+		}
+		e := edges[i]
+		edges = slices.Delete(edges, i, i+1)
+		walk = append(walk, e)
+		if e.from == cur {
+			cur = e.to
+		} else {
+			cur = e.from
+		}
+		path = append(path, cur)
+	}
+	if len(edges) > 0 {
+		return nil, fmt.Errorf("patterns on line %d are not connected to ?%s", edges[0].line, sel)
+	}
+	for _, v := range sp.vars {
+		if !slices.Contains(path, v) {
+			return nil, fmt.Errorf("?%s is not connected to ?%s", v, sel)
+		}
+	}
+	slices.Reverse(path)
+	slices.Reverse(walk)
+
+	steps := make([]Step, 0)
+	for i, v := range path {
+		steps = append(steps, sp.filters[v]...)
+		if i == len(walk) {
+			break
+		}
+		e := walk[i]
+		if e.from == v {
+			steps = append(steps, e.steps...)
+			continue
+		}
+		// The traversal is written in the other direction.
+		for j := len(e.steps) - 1; j >= 0; j-- {
+			s := e.steps[j]
+			switch s.token {
+			case "Follow":
+				s.token = "FollowInverse"
+			case "FollowInverse":
+				s.token = "Follow"
+			default:
+				return nil, fmt.Errorf("cannot reverse the UNION on line %d", e.line)
+			}
+			steps = append(steps, s)
+		}
+	}
+	return steps, nil
+}
@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Renders a chain compactly for comparisons in tests.
+func chainString(chain []Step) string {
+	parts := make([]string, len(chain))
+	for i, s := range chain {
+		parts[i] = s.token + "[" + strings.Join(append([]string{s.arg}, s.vals...), ",") + "]"
+		if len(s.subcmd) > 0 {
+			parts[i] += "(" + chainString(s.subcmd) + ")"
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func TestFromSPARQL(t *testing.T) {
+	query := `
+PREFIX ex: <http://example.org/>
+PREFIX skos: <http://www.w3.org/2004/02/skos/core#>
+SELECT DISTINCT ?meal WHERE {
+  { ?monster a ex:Gremlin } UNION { ?monster a ex:GooGrok }
+  ?monster ex:FurColor ?color ;
+           skos:inScheme ex:Animals ;
+           skos:broader ex:Preditor .
+  FILTER(?color IN ("green", "blue"))
+  ?monster ex:SmellOfFood/ex:ComesFrom ?meal .
+  ?meal a ex:TastyMeal .
+}`
+	chain, err := FromSPARQL(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Start[iri].Or[](HasType[ex:Gremlin].HasType[ex:GooGrok])." +
+		"HasValue[ex:FurColor,green,blue].HasBroader[ex:Animals,ex:Preditor]." +
+		"Follow[ex:SmellOfFood].Follow[ex:ComesFrom].HasType[ex:TastyMeal].Eval[]"
+	if s := chainString(chain); s != expected {
+		t.Errorf("Expected %s got %s", expected, s)
+	}
+}
+
+func TestFromSPARQLInversePath(t *testing.T) {
+	query := `
+PREFIX ex: <http://example.org/>
+SELECT ?food WHERE {
+  ?food ex:Eats ?gremlin .
+  VALUES ?gremlin { ex:Gizmo }
+  ?gremlin ex:Name "Gizmo" .
+}`
+	chain, err := FromSPARQL(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Start[iri].IsInstance[ex:Gizmo].HasValue[ex:Name,Gizmo].FollowInverse[ex:Eats].Eval[]"
+	if s := chainString(chain); s != expected {
+		t.Errorf("Expected %s got %s", expected, s)
+	}
+}
+
+func TestFromSPARQLRoundTrip(t *testing.T) {
+	cmds := []string{
+		`Start[iri].Eval`,
+		`Start[iri].HasType[ex:Gremlin].Or(Follow[ex:Eats].HasType[ex:Food]).IsActive[].Eval`,
+		`Start[iri].IsInactive[].HasCategory[bsm:Monster].Eval`,
+		`Start[iri].IsInstance[ex:Gizmo].Follow[ex:Eats].FollowInverse[ex:ComesFrom].Eval`,
+		`Start[iri].HasValue[ex:Name, "Gizmo, the mogwai", "3.14"].HasBroader[ex:Animals, ex:Fantasy].Eval`,
+		`Start[iri].HasType[ex:Gremlin].Follow[ex:Eats].Limit[10].Eval`,
+	}
+
+	pm := DefaultPrefixes()
+	pm["skos"] = skosNs
+	for _, cmd := range cmds {
+		chain, err := ParseCommand(cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		query, err := ToSPARQL(chain, pm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		back, err := FromSPARQL(query)
+		if err != nil {
+			t.Errorf("%s: %s\n%s", cmd, err, query)
+			continue
+		}
+		if chainString(back) != chainString(chain) {
+			t.Errorf("Expected %s got %s", chainString(chain), chainString(back))
+		}
+	}
+}
+
+func TestFromSPARQLEscapes(t *testing.T) {
+	query := `SELECT ?n0 WHERE { ?n0 <http://example.org/Name> ?v0 . VALUES ?v0 { "caf\é \"mogwaï\"\t" } }`
+	chain, err := FromSPARQL(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"café \"mogwaï\"\t"}
+	if vals := chain[1].Vals(); !reflect.DeepEqual(vals, expected) {
+		t.Errorf("Expected %q got %q", expected, vals)
+	}
+}
+
+func TestFromSPARQLUnsupported(t *testing.T) {
+	queries := map[string]string{
+		"optional":   `SELECT ?s WHERE { ?s a <http://example.org/A> OPTIONAL { ?s <http://example.org/p> ?o } }`,
+		"star path":  `SELECT ?s WHERE { ?x <http://example.org/p>* ?s }`,
+		"two vars":   `SELECT ?s ?o WHERE { ?s <http://example.org/p> ?o }`,
+		"branching":  `SELECT ?s WHERE { ?s <http://example.org/p> ?a . ?s <http://example.org/q> ?b . ?s <http://example.org/r> ?c }`,
+		"iri object": `SELECT ?s WHERE { ?s <http://example.org/p> <http://example.org/o> }`,
+		"broader":    `SELECT ?s WHERE { ?s <http://www.w3.org/2004/02/skos/core#broader> <http://example.org/o> }`,
+		"prefix":     `SELECT ?s WHERE { ?s a foo:Bar }`,
+		"zero limit": `SELECT ?s WHERE { ?s a <http://example.org/A> } LIMIT 0`,
+		"offset":     `SELECT ?s WHERE { ?s a <http://example.org/A> } LIMIT 10 OFFSET 5`,
+	}
+
+	for name, q := range queries {
+		_, err := FromSPARQL(q)
+		if err == nil {
+			t.Errorf("%s: expected error when converting %s", name, q)
+		}
+	}
+}
+
+func TestFromSPARQLErrorLine(t *testing.T) {
+	query := "SELECT ?s WHERE {\n  ?s a <http://example.org/A> .\n  OPTIONAL { ?s ?p ?o }\n}"
+	_, err := FromSPARQL(query)
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("line %d", 3)) {
+		t.Errorf("Expected error on line 3 got %v", err)
+	}
+}
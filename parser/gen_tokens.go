@@ -0,0 +1,92 @@
+//go:build ignore
+
+// Generates the name tables and String method of Token from the constants
+// declared in token.go.
+//
+//	go generate ./parser
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"text/template"
+)
+
+var tmpl = template.Must(template.New("tokens").Parse(`// Code generated by "go run gen_tokens.go"; DO NOT EDIT.
+
+package parser
+
+import "strconv"
+
+var tokenNames = [...]string{
+{{- range .}}
+	{{.}}: "{{.}}",
+{{- end}}
+}
+
+var tokensByName = map[string]Token{
+{{- range .}}
+	"{{.}}": {{.}},
+{{- end}}
+}
+
+func (t Token) String() string {
+	if t > 0 && int(t) < len(tokenNames) {
+		return tokenNames[t]
+	}
+	return "Token(" + strconv.Itoa(int(t)) + ")"
+}
+`))
+
+func main() {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "token.go", nil, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var names []string
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.CONST {
+			continue
+		}
+		// Constants that follow the first one of type Token repeat its
+		// type implicitly.
+		isToken := false
+		for _, spec := range gd.Specs {
+			vs := spec.(*ast.ValueSpec)
+			if id, ok := vs.Type.(*ast.Ident); ok {
+				isToken = id.Name == "Token"
+			}
+			if !isToken {
+				continue
+			}
+			for _, n := range vs.Names {
+				if n.Name != "_" {
+					names = append(names, n.Name)
+				}
+			}
+		}
+	}
+	if len(names) == 0 {
+		log.Fatal("no Token constants found in token.go")
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, names); err != nil {
+		log.Fatal(err)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("token_string.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,89 @@
+package parser
+
+import "slices"
+
+// Graph is the data that internalized steps are evaluated against. Nodes
+// and predicates are identified by the Iids of the Internalizer the steps
+// were internalized with.
+type Graph interface {
+	// Returns every node of the graph.
+	Nodes() []Iid
+	// Returns the nodes the node links to with the predicate.
+	Out(node, pred Iid) []Iid
+	// Returns the nodes that link to the node with the predicate.
+	In(node, pred Iid) []Iid
+	// Returns the literal values of the predicate on the node.
+	Values(node, pred Iid) []string
+}
+
+type edgeKey struct {
+	node Iid
+	pred Iid
+}
+
+// MemGraph is a Graph held in memory, built from triples.
+type MemGraph struct {
+	is     Internalizer
+	nodes  map[Iid]bool
+	out    map[edgeKey][]Iid
+	in     map[edgeKey][]Iid
+	values map[edgeKey][]string
+}
+
+// Creates a graph holding the triples, internalizing their IRIs with is.
+func NewMemGraph(triples []Triple, is Internalizer) *MemGraph {
+	g := &MemGraph{
+		is:     is,
+		nodes:  make(map[Iid]bool),
+		out:    make(map[edgeKey][]Iid),
+		in:     make(map[edgeKey][]Iid),
+		values: make(map[edgeKey][]string),
+	}
+	for _, t := range triples {
+		g.Add(t)
+	}
+	return g
+}
+
+// Adds a triple to the graph.
+func (g *MemGraph) Add(t Triple) {
+	s := g.is.Put(t.S)
+	p := g.is.Put(t.P)
+	g.nodes[s] = true
+	if t.Literal {
+		k := edgeKey{s, p}
+		if !slices.Contains(g.values[k], t.O) {
+			g.values[k] = append(g.values[k], t.O)
+		}
+		return
+	}
+
+	o := g.is.Put(t.O)
+	g.nodes[o] = true
+	k := edgeKey{s, p}
+	if !slices.Contains(g.out[k], o) {
+		g.out[k] = append(g.out[k], o)
+		g.in[edgeKey{o, p}] = append(g.in[edgeKey{o, p}], s)
+	}
+}
+
+func (g *MemGraph) Nodes() []Iid {
+	nodes := make([]Iid, 0, len(g.nodes))
+	for n := range g.nodes {
+		nodes = append(nodes, n)
+	}
+	slices.Sort(nodes)
+	return nodes
+}
+
+func (g *MemGraph) Out(node, pred Iid) []Iid {
+	return g.out[edgeKey{node, pred}]
+}
+
+func (g *MemGraph) In(node, pred Iid) []Iid {
+	return g.in[edgeKey{node, pred}]
+}
+
+func (g *MemGraph) Values(node, pred Iid) []string {
+	return g.values[edgeKey{node, pred}]
+}
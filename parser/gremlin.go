@@ -0,0 +1,256 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Translates a chain of steps to a Gremlin-Groovy traversal.
+func ToGremlin(chain []Step, m GraphMapping) (string, error) {
+	t, err := gremlinQuery(chain, m)
+	if err != nil {
+		return "", err
+	}
+	return t.groovy("g"), nil
+}
+
+// Translates a chain of steps to the bytecode of a Gremlin traversal, as
+// the GraphSON 3.0 JSON that drivers send to a Gremlin server.
+func ToGremlinBytecode(chain []Step, m GraphMapping) (string, error) {
+	t, err := gremlinQuery(chain, m)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(t.graphson())
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// gremlinTraversal is a traversal as its list of steps, which both the
+// Groovy text and the bytecode are rendered from.
+type gremlinTraversal []gremlinStep
+
+// gremlinStep is a step of a traversal with its arguments: strings, bare
+// literals, counts, predicates and anonymous traversals.
+type gremlinStep struct {
+	op   string
+	args []any
+}
+
+// A P predicate such as within.
+type gremlinPredicate struct {
+	op     string
+	values []any
+}
+
+// A boolean or number, written without quotes.
+type gremlinBare string
+
+func gstep(op string, args ...any) gremlinStep {
+	return gremlinStep{op: op, args: args}
+}
+
+func gremlinQuery(chain []Step, m GraphMapping) (gremlinTraversal, error) {
+	if len(chain) < 2 || chain[0].token != "Start" || chain[len(chain)-1].token != "Eval" {
+		return nil, fmt.Errorf("expected chain to begin with Start and end with Eval")
+	}
+
+	steps, err := gremlinSteps(chain[1:len(chain)-1], m)
+	if err != nil {
+		return nil, err
+	}
+	t := gremlinTraversal{gstep("V")}
+	t = append(t, steps...)
+	// A trailing Limit already returns distinct nodes.
+	if n := len(t); n >= 2 && t[n-2].op == "dedup" && t[n-1].op == "limit" {
+		return t, nil
+	}
+	return append(t, gstep("dedup")), nil
+}
+
+func gremlinSteps(chain []Step, m GraphMapping) (gremlinTraversal, error) {
+	steps := make(gremlinTraversal, 0, len(chain))
+	for _, s := range chain {
+		if s.token == "NoOp" {
+			continue
+		}
+		g, err := gremlinStepsOf(s, m)
+		if err != nil {
+			return nil, err
+		}
+		steps = append(steps, g...)
+	}
+	return steps, nil
+}
+
+func gremlinStepsOf(s Step, m GraphMapping) (gremlinTraversal, error) {
+	switch s.token {
+	case "HasType":
+		if m.TypeAsLabel {
+			return gremlinTraversal{gstep("hasLabel", m.name(s.arg))}, nil
+		}
+		return gremlinTraversal{gstep("has", m.TypeProperty, m.name(s.arg))}, nil
+	case "HasCategory":
+		return gremlinTraversal{gstep("has", m.CategoryProperty, m.name(s.arg))}, nil
+	case "HasValue":
+		vals := make([]any, len(s.vals))
+		for i, v := range s.vals {
+			vals[i] = v
+		}
+		return gremlinTraversal{gstep("has", m.name(s.arg), gremlinPredicate{"within", vals})}, nil
+	case "IsInstance":
+		return gremlinTraversal{gstep("has", m.IdProperty, m.name(s.arg))}, nil
+	case "InScheme":
+		return gremlinTraversal{gremlinEdgeTo(m, m.SchemeEdge, s.arg)}, nil
+	case "HasBroader":
+		if len(s.vals) != 1 {
+			return nil, fmt.Errorf("expected HasBroader[taxonomy, target] got %d values", len(s.vals))
+		}
+		return gremlinTraversal{gremlinEdgeTo(m, m.SchemeEdge, s.arg), gremlinEdgeTo(m, m.BroaderEdge, s.vals[0])}, nil
+	case "Follow":
+		return gremlinTraversal{gstep("out", m.name(s.arg))}, nil
+	case "FollowInverse":
+		return gremlinTraversal{gstep("in", m.name(s.arg))}, nil
+	case "IsActive":
+		return gremlinTraversal{gstep("has", m.ActiveProperty, gremlinLiteral(m.ActiveValue))}, nil
+	case "IsInactive":
+		active := gremlinTraversal{gstep("has", m.ActiveProperty, gremlinLiteral(m.ActiveValue))}
+		return gremlinTraversal{gstep("not", active)}, nil
+	case "Or":
+		// Alternatives that only filter the current node use or(), otherwise
+		// the results of the alternatives are merged with union().
+		alts := make([]any, 0, len(s.subcmd))
+		for _, sub := range s.subcmd {
+			// A limit in a union would apply to each node on its own.
+			if sub.token == "Limit" {
+				return nil, fmt.Errorf("cannot translate Limit in an Or to Gremlin")
+			}
+			g, err := gremlinSteps([]Step{sub}, m)
+			if err != nil {
+				return nil, err
+			}
+			alts = append(alts, g)
+		}
+		if moves(s) {
+			return gremlinTraversal{gstep("union", alts...)}, nil
+		}
+		return gremlinTraversal{gstep("or", alts...)}, nil
+	case "Limit":
+		n, err := limitCount(s.arg)
+		if err != nil {
+			return nil, err
+		}
+		return gremlinTraversal{gstep("dedup"), gstep("limit", int64(n))}, nil
+	default:
+		return nil, fmt.Errorf("cannot translate %s to Gremlin", s.token)
+	}
+}
+
+// Filters on an edge to the node identified by target.
+func gremlinEdgeTo(m GraphMapping, edge, target string) gremlinStep {
+	return gstep("where", gremlinTraversal{gstep("out", edge), gstep("has", m.IdProperty, m.name(target))})
+}
+
+// Renders the traversal as Groovy, starting from the source: g for the
+// traversal itself and __ for anonymous traversals.
+func (t gremlinTraversal) groovy(source string) string {
+	var b strings.Builder
+	b.WriteString(source)
+	for _, s := range t {
+		args := make([]string, len(s.args))
+		for i, a := range s.args {
+			args[i] = groovyArg(a)
+		}
+		fmt.Fprintf(&b, ".%s(%s)", s.op, strings.Join(args, ", "))
+	}
+	return b.String()
+}
+
+func groovyArg(a any) string {
+	switch a := a.(type) {
+	case string:
+		return gremlinString(a)
+	case gremlinBare:
+		return string(a)
+	case int64:
+		return strconv.FormatInt(a, 10)
+	case gremlinPredicate:
+		vals := make([]string, len(a.values))
+		for i, v := range a.values {
+			vals[i] = groovyArg(v)
+		}
+		return fmt.Sprintf("%s(%s)", a.op, strings.Join(vals, ", "))
+	case gremlinTraversal:
+		return a.groovy("__")
+	}
+	panic(fmt.Sprintf("unexpected Gremlin argument %T", a))
+}
+
+// Returns the traversal as a GraphSON 3.0 g:Bytecode value.
+func (t gremlinTraversal) graphson() any {
+	steps := make([][]any, len(t))
+	for i, s := range t {
+		steps[i] = append([]any{s.op}, graphsonArgs(s.args)...)
+	}
+	return graphsonValue("g:Bytecode", map[string]any{"step": steps})
+}
+
+func graphsonArgs(args []any) []any {
+	out := make([]any, len(args))
+	for i, a := range args {
+		out[i] = graphsonArg(a)
+	}
+	return out
+}
+
+func graphsonArg(a any) any {
+	switch a := a.(type) {
+	case string:
+		return a
+	case gremlinBare:
+		if a == "true" || a == "false" {
+			return a == "true"
+		}
+		if n, err := strconv.ParseInt(string(a), 10, 64); err == nil {
+			if n >= math.MinInt32 && n <= math.MaxInt32 {
+				return graphsonValue("g:Int32", n)
+			}
+			return graphsonValue("g:Int64", n)
+		}
+		f, _ := strconv.ParseFloat(string(a), 64)
+		return graphsonValue("g:Double", f)
+	case int64:
+		return graphsonValue("g:Int64", a)
+	case gremlinPredicate:
+		return graphsonValue("g:P", map[string]any{
+			"predicate": a.op,
+			"value":     graphsonValue("g:List", graphsonArgs(a.values)),
+		})
+	case gremlinTraversal:
+		return a.graphson()
+	}
+	panic(fmt.Sprintf("unexpected Gremlin argument %T", a))
+}
+
+func graphsonValue(typ string, v any) map[string]any {
+	return map[string]any{"@type": typ, "@value": v}
+}
+
+func gremlinString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`, "\t", `\t`)
+	return "'" + r.Replace(s) + "'"
+}
+
+// Returns booleans and numbers as bare literals and everything else as
+// strings.
+func gremlinLiteral(s string) any {
+	if isBareLiteral(s) {
+		return gremlinBare(s)
+	}
+	return s
+}
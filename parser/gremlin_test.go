@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestGremlinGolden(t *testing.T) {
+	testGolden(t, ".groovy", func(chain []Step) (string, error) {
+		q, err := ToGremlin(chain, DefaultGraphMapping())
+		return q + "\n", err
+	})
+}
+
+func TestGremlinBytecodeGolden(t *testing.T) {
+	testGolden(t, ".bytecode.json", func(chain []Step) (string, error) {
+		q, err := ToGremlinBytecode(chain, DefaultGraphMapping())
+		return q + "\n", err
+	})
+}
+
+func TestGremlinBytecodeLiterals(t *testing.T) {
+	chain, err := ParseCommand(`Start[iri].IsActive[].Limit[3].Eval`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := DefaultGraphMapping()
+	m.ActiveValue = "1"
+	q, err := ToGremlinBytecode(chain, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"@type":"g:Bytecode","@value":{"step":[["V"],["has","active",{"@type":"g:Int32","@value":1}],["dedup"],["limit",{"@type":"g:Int64","@value":3}]]}}`
+	if q != expected {
+		t.Errorf("Expected %s got %s", expected, q)
+	}
+}
+
+func TestGremlinMapping(t *testing.T) {
+	chain, err := ParseCommand(`Start[iri].HasType[ex:Gremlin].InScheme[ex:Animals].Eval`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := DefaultGraphMapping()
+	m.IdProperty = "uri"
+	m.SchemeEdge = "partOf"
+	m.Names["ex:Gremlin"] = "Gremlin"
+
+	q, err := ToGremlin(chain, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "g.V().hasLabel('Gremlin').where(__.out('partOf').has('uri', 'ex:Animals')).dedup()"
+	if q != expected {
+		t.Errorf("Expected %s got %s", expected, q)
+	}
+}
@@ -0,0 +1,147 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// StepTypes holds the possible rdf:types of the current node after a step.
+// Types is nil when nothing is known about the node.
+type StepTypes struct {
+	Path  []int
+	Token string
+	Types []string
+}
+
+// TypeInfo is the result of inferring types across a chain of steps.
+type TypeInfo struct {
+	Steps       []StepTypes
+	Diagnostics []Diagnostic
+}
+
+// Returns the inferred types after the step with the given path.
+func (ti TypeInfo) At(path []int) (StepTypes, bool) {
+	for _, st := range ti.Steps {
+		if slices.Equal(st.Path, path) {
+			return st, true
+		}
+	}
+	return StepTypes{}, false
+}
+
+// Infers the possible types of the current node after every step in the
+// chain, using the rdfs:domain and rdfs:range declared in the schema. Steps
+// that can never match because of disjoint classes are reported as warnings.
+func InferTypes(chain []Step, s *Schema) TypeInfo {
+	ti := TypeInfo{
+		Steps:       make([]StepTypes, 0),
+		Diagnostics: make([]Diagnostic, 0),
+	}
+	inferTypes(chain, s, nil, nil, &ti)
+	return ti
+}
+
+func inferTypes(chain []Step, s *Schema, parent []int, types []string, ti *TypeInfo) []string {
+	for i, st := range chain {
+		types = inferStep(st, s, append(slices.Clone(parent), i), types, ti)
+	}
+	return types
+}
+
+func inferStep(st Step, s *Schema, path []int, types []string, ti *TypeInfo) []string {
+	warn := func(format string, args ...any) {
+		ti.Diagnostics = append(ti.Diagnostics, Diagnostic{
+			Severity: SeverityWarning,
+			Path:     path,
+			Token:    st.token,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	switch st.token {
+	case "Start":
+		types = nil
+	case "HasType":
+		if d := disjointWith(s, st.arg, types); d != "" {
+			warn("%s is disjoint with %s, the step can never match", st.arg, d)
+		}
+		types = narrow(s, types, st.arg)
+	case "Follow", "FollowInverse":
+		p, ok := s.Property(st.arg)
+		if !ok {
+			types = nil
+			break
+		}
+		in, out := p.Domain, p.Range
+		if st.token == "FollowInverse" {
+			in, out = p.Range, p.Domain
+		}
+		for _, c := range in {
+			if d := disjointWith(s, c, types); d != "" {
+				warn("%s expects %s which is disjoint with %s", st.arg, c, d)
+				break
+			}
+		}
+		types = nil
+		if len(out) > 0 {
+			types = slices.Clone(out)
+		}
+	case "Or":
+		// Every subcommand is an alternative applied to the same node, the
+		// result is the union of the alternatives.
+		union := make([]string, 0)
+		known := true
+		for j, sub := range st.subcmd {
+			t := inferStep(sub, s, append(slices.Clone(path), j), types, ti)
+			if t == nil {
+				known = false
+			}
+			for _, c := range t {
+				if !slices.Contains(union, c) {
+					union = append(union, c)
+				}
+			}
+		}
+		types = nil
+		if known {
+			types = union
+		}
+	}
+
+	ti.Steps = append(ti.Steps, StepTypes{
+		Path:  path,
+		Token: st.token,
+		Types: types,
+	})
+	return types
+}
+
+// Returns the classes in types that class is disjoint with, or "" if the
+// class is compatible with at least one of them.
+func disjointWith(s *Schema, class string, types []string) string {
+	if len(types) == 0 {
+		return ""
+	}
+	for _, t := range types {
+		if !s.IsDisjoint(class, t) {
+			return ""
+		}
+	}
+	return strings.Join(types, ", ")
+}
+
+// Narrows types by a HasType step. Known types that are already subclasses
+// of class are kept, otherwise the node is known to be of the class.
+func narrow(s *Schema, types []string, class string) []string {
+	out := make([]string, 0)
+	for _, t := range types {
+		if s.IsSubClassOf(t, class) {
+			out = append(out, t)
+		}
+	}
+	if len(out) == 0 {
+		out = append(out, class)
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func inferCmd(t *testing.T, cmd string) TypeInfo {
+	t.Helper()
+	chain, err := ParseCommand(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return InferTypes(chain, loadTestSchema(t))
+}
+
+func TestInferFollowRange(t *testing.T) {
+	ti := inferCmd(t, `Start[iri].HasType[ex:Gremlin].Follow[ex:Eats].HasType[ex:TastyMeal].Eval`)
+	if len(ti.Diagnostics) != 0 {
+		t.Errorf("Expected no diagnostics, got %v", ti.Diagnostics)
+	}
+
+	expected := [][]string{
+		nil,
+		{"ex:Gremlin"},
+		{"ex:Food"},
+		{"ex:TastyMeal"},
+		{"ex:TastyMeal"},
+	}
+	if len(ti.Steps) != len(expected) {
+		t.Fatalf("Expected %d steps, got %d", len(expected), len(ti.Steps))
+	}
+	for i, e := range expected {
+		if !reflect.DeepEqual(ti.Steps[i].Types, e) {
+			t.Errorf("expected %v got %v in step %d", e, ti.Steps[i].Types, i)
+		}
+	}
+}
+
+func TestInferDisjointHasType(t *testing.T) {
+	ti := inferCmd(t, `Start[iri].Follow[ex:SmellOfFood].HasType[ex:TastyMeal].Eval`)
+	if len(ti.Diagnostics) != 1 {
+		t.Fatalf("Expected 1 diagnostic, got %v", ti.Diagnostics)
+	}
+
+	d := ti.Diagnostics[0]
+	if d.Severity != SeverityWarning || !reflect.DeepEqual(d.Path, []int{2}) {
+		t.Errorf("Unexpected diagnostic %v", d)
+	}
+	if !strings.Contains(d.Message, "ex:TastyMeal is disjoint with ex:Smell") {
+		t.Errorf("Unexpected message %s", d.Message)
+	}
+}
+
+func TestInferDisjointFollow(t *testing.T) {
+	ti := inferCmd(t, `Start[iri].Follow[ex:LivesIn].Follow[ex:Eats].Eval`)
+	if len(ti.Diagnostics) != 1 {
+		t.Fatalf("Expected 1 diagnostic, got %v", ti.Diagnostics)
+	}
+}
+
+func TestInferOr(t *testing.T) {
+	ti := inferCmd(t, `Start[iri].Or(HasType[ex:Gremlin].HasType[ex:GooGrok]).Follow[ex:LivesIn].Eval`)
+
+	st, ok := ti.At([]int{1})
+	if !ok {
+		t.Fatalf("Expected types for step 1")
+	}
+	if !reflect.DeepEqual(st.Types, []string{"ex:Gremlin", "ex:GooGrok"}) {
+		t.Errorf("Expected [ex:Gremlin ex:GooGrok] got %v", st.Types)
+	}
+
+	st, ok = ti.At([]int{1, 1})
+	if !ok || !reflect.DeepEqual(st.Types, []string{"ex:GooGrok"}) {
+		t.Errorf("Expected [ex:GooGrok] got %v", st.Types)
+	}
+
+	st, _ = ti.At([]int{2})
+	if !reflect.DeepEqual(st.Types, []string{"ex:Location"}) {
+		t.Errorf("Expected [ex:Location] got %v", st.Types)
+	}
+}
+
+func TestInferUnknownProperty(t *testing.T) {
+	ti := inferCmd(t, `Start[iri].HasType[ex:Gremlin].Follow[ex:Unknown].Eval`)
+
+	st, _ := ti.At([]int{2})
+	if st.Types != nil {
+		t.Errorf("Expected unknown types got %v", st.Types)
+	}
+}
+
+func TestSchemaDisjoint(t *testing.T) {
+	s := loadTestSchema(t)
+
+	if !s.IsDisjoint("ex:TastyMeal", "ex:Smell") {
+		t.Errorf("Expected ex:TastyMeal to be disjoint with ex:Smell")
+	}
+	if s.IsDisjoint("ex:TastyMeal", "ex:Gremlin") {
+		t.Errorf("Expected ex:TastyMeal not to be disjoint with ex:Gremlin")
+	}
+}
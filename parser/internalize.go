@@ -1,103 +1,49 @@
 package parser
 
-type Iid uint64
-
-type Token int
-
-const (
-	_ Token = iota
-	NoOp
-	Start
-	Eval
-	HasType
-	HasCategory
-	HasValue
-	InScheme
-	HasBroader
-	IsInstance
-	Follow
-	FollowInverse
-	IsActive
-	IsInactive
-	Or
+import (
+	"fmt"
+	"slices"
 )
 
+type Iid uint64
+
 // Internalizer is an interface for a store that maps between
 // strings and internal ids.
+//
+// Implementations must be safe for concurrent use by multiple goroutines.
+// Put must return the same Iid for the same string even when it is called
+// concurrently, and an Iid returned by Put must be visible to GetIid and
+// GetString in every goroutine once Put has returned.
 type Internalizer interface {
 	GetIid(string) (Iid, bool)
 	GetString(Iid) (string, bool)
 	Put(string) Iid
 }
 
-// ASCII string to token
-func Atot(s string) Token {
-	switch s {
-	case "Start":
-		return Start
-	case "Eval":
-		return Eval
-	case "HasType":
-		return HasType
-	case "HasCategory":
-		return HasCategory
-	case "HasValue":
-		return HasValue
-	case "InScheme":
-		return InScheme
-	case "HasBroader":
-		return HasBroader
-	case "IsInstance":
-		return IsInstance
-	case "Follow":
-		return Follow
-	case "FollowInverse":
-		return FollowInverse
-	case "IsActive":
-		return IsActive
-	case "IsInactive":
-		return IsInactive
-	case "Or":
-		return Or
-	default:
-		return 0
-	}
+// BatchInternalizer is an Internalizer that can resolve many strings in a
+// single call, for stores where every call is a round trip. The slices
+// returned are in the order of the arguments.
+type BatchInternalizer interface {
+	Internalizer
+	PutMany([]string) []Iid
+	GetIids([]string) ([]Iid, []bool)
+	GetStrings([]Iid) ([]string, []bool)
+}
+
+// FallibleInternalizer is an Internalizer whose Put can fail, such as one
+// backed by storage. Once Put fails it returns the Error Iid and Err reports
+// why, so callers must check Err before trusting the Iids it handed out.
+type FallibleInternalizer interface {
+	Internalizer
+	Err() error
 }
 
-// Token to ASCII string
-func Ttoa(t Token) string {
-	switch t {
-	case Start:
-		return "Start"
-	case Eval:
-		return "Eval"
-	case HasType:
-		return "HasType"
-	case HasCategory:
-		return "HasCategory"
-	case HasValue:
-		return "HasValue"
-	case InScheme:
-		return "InScheme"
-	case HasBroader:
-		return "HasBroader"
-	case IsInstance:
-		return "IsInstance"
-	case Follow:
-		return "Follow"
-	case FollowInverse:
-		return "FollowInverse"
-	case IsActive:
-		return "IsActive"
-	case IsInactive:
-		return "IsInactive"
-	case Or:
-		return "Or"
-	case NoOp:
-		return "NoOp"
-	default:
-		return "**error**"
+// Returns the failure of the Internalizer, if it is a FallibleInternalizer.
+func internalizerErr(is Internalizer) error {
+	if fi, ok := is.(FallibleInternalizer); ok {
+		return fi.Err()
 	}
+	return nil
 }
 
 type istep struct {
@@ -109,48 +55,147 @@ type istep struct {
 }
 
 // Convert a chain of steps to internalized form that is ready for evaluation.
+// Fails when is is a FallibleInternalizer that could not store a string.
 func InternalizeSteps(chain []Step, is Internalizer) ([]istep, error) {
-	steps := make([]istep, 0)
-	var step istep
+	resolved, err := resolveSteps(chain)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, s := range chain {
-		switch s.token {
-		case "Start", "Eval", "NoOp", "IsActive", "IsInactive":
-			step = istep{
-				Token: Atot(s.token),
-			}
-		case "HasType", "HasCategory", "IsInstance", "Follow", "FollowInverse", "InScheme":
-			iarg := is.Put(s.arg)
-			step = istep{
-				Token: Atot(s.token),
-				Arg:   iarg,
-			}
-		case "HasValue":
-			iarg := is.Put(s.arg)
-			step = istep{
-				Token: Atot(s.token),
-				Arg:   iarg,
-				Svals: s.vals,
-			}
-		case "HasBroader":
-			iagr := is.Put(s.arg)
-			ival := is.Put(s.vals[0])
-			step = istep{
-				Token: Atot(s.token),
-				Arg:   iagr,
-				Ivals: []Iid{ival},
+	put := is.Put
+	if bi, ok := is.(BatchInternalizer); ok {
+		strs := chainStrings(resolved, nil)
+		iids := make(map[string]Iid, len(strs))
+		for i, iid := range bi.PutMany(strs) {
+			iids[strs[i]] = iid
+		}
+		put = func(s string) Iid {
+			return iids[s]
+		}
+	}
+
+	steps := internalizeSteps(resolved, put)
+	if err := internalizerErr(is); err != nil {
+		return nil, fmt.Errorf("cannot internalize step: %w", err)
+	}
+	return steps, nil
+}
+
+// Convert a chain of steps to internalized form without adding strings to
+// the Internalizer. Strings that are not known are mapped to the NoMatch
+// Iid, so the steps using them match nothing, and are returned so the caller
+// can report them.
+func InternalizeStepsReadOnly(chain []Step, is Internalizer) ([]istep, []string, error) {
+	nomatch, ok := is.GetIid("NoMatch")
+	if !ok {
+		return nil, nil, fmt.Errorf("internalizer has no NoMatch iid")
+	}
+	resolved, err := resolveSteps(chain)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	getIid := is.GetIid
+	if bi, ok := is.(BatchInternalizer); ok {
+		strs := chainStrings(resolved, nil)
+		iids, found := bi.GetIids(strs)
+		known := make(map[string]Iid, len(strs))
+		for i, s := range strs {
+			if found[i] {
+				known[s] = iids[i]
 			}
-		case "Or":
-			substeps, err := InternalizeSteps(s.subcmd, is)
-			if err != nil {
+		}
+		getIid = func(s string) (Iid, bool) {
+			i, ok := known[s]
+			return i, ok
+		}
+	}
+
+	unknown := make([]string, 0)
+	steps := internalizeSteps(resolved, func(s string) Iid {
+		if i, ok := getIid(s); ok {
+			return i
+		}
+		if !slices.Contains(unknown, s) {
+			unknown = append(unknown, s)
+		}
+		return nomatch
+	})
+	return steps, unknown, nil
+}
+
+// resolvedStep is a step checked against its declaration, with the arguments
+// returned by the internalization hook of a custom step.
+type resolvedStep struct {
+	def    StepDef
+	args   []string
+	subcmd []resolvedStep
+}
+
+// Checks the steps and resolves their arguments, running the internalization
+// hook of every custom step once.
+func resolveSteps(chain []Step) ([]resolvedStep, error) {
+	steps := make([]resolvedStep, 0, len(chain))
+	for _, s := range chain {
+		d, err := checkStep(s)
+		if err != nil {
+			return nil, fmt.Errorf("cannot internalize step: %w", err)
+		}
+		args, err := resolveArgs(d, s)
+		if err != nil {
+			return nil, fmt.Errorf("cannot internalize step: %w", err)
+		}
+
+		step := resolvedStep{def: d, args: args}
+		if d.Subcmd {
+			if step.subcmd, err = resolveSteps(s.subcmd); err != nil {
 				return nil, err
 			}
-			step = istep{
-				Token:  Atot(s.token),
-				Subcmd: substeps,
-			}
 		}
 		steps = append(steps, step)
 	}
 	return steps, nil
 }
+
+// Returns the distinct strings of the steps that are internalized, including
+// those of nested subcommands, in the order they appear.
+func chainStrings(steps []resolvedStep, strs []string) []string {
+	for _, s := range steps {
+		if s.def.Token == Start {
+			continue
+		}
+		for i, a := range s.args {
+			if s.def.ArgKind(i) == IdentArg && !slices.Contains(strs, a) {
+				strs = append(strs, a)
+			}
+		}
+		strs = chainStrings(s.subcmd, strs)
+	}
+	return strs
+}
+
+func internalizeSteps(resolved []resolvedStep, put func(string) Iid) []istep {
+	steps := make([]istep, 0, len(resolved))
+	for _, s := range resolved {
+		step := istep{
+			Token: s.def.Token,
+		}
+		if s.def.Token != Start {
+			for i, a := range s.args {
+				switch {
+				case s.def.ArgKind(i) == LiteralArg:
+					step.Svals = append(step.Svals, a)
+				case i == 0:
+					step.Arg = put(a)
+				default:
+					step.Ivals = append(step.Ivals, put(a))
+				}
+			}
+		}
+		if s.def.Subcmd {
+			step.Subcmd = internalizeSteps(s.subcmd, put)
+		}
+		steps = append(steps, step)
+	}
+	return steps
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"reflect"
+	"sync"
 	"testing"
 )
 
@@ -195,3 +196,277 @@ func TestInternalizeOrCmd(t *testing.T) {
 		t.Errorf("Expected %+v got %+v", x, s)
 	}
 }
+
+func TestInternalizeReadOnly(t *testing.T) {
+	is := NewIidStore()
+	red := is.Put("red")
+	nomatch, _ := is.GetIid("NoMatch")
+	cmd := `Start[iri].Or(IsInstance[red].IsInstance[green]).HasBroader[tax, red].Follow[rel].Eval`
+
+	steps, err := ParseCommand(cmd)
+	if err != nil {
+		t.Error(err)
+	}
+
+	isteps, unknown, err := InternalizeStepsReadOnly(steps, is)
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := []string{"green", "tax", "rel"}
+	if !reflect.DeepEqual(unknown, expected) {
+		t.Errorf("Expected unknown %v got %v", expected, unknown)
+	}
+
+	x := istep{
+		Token: Or,
+		Subcmd: []istep{
+			{
+				Token: IsInstance,
+				Arg:   red,
+			},
+			{
+				Token: IsInstance,
+				Arg:   nomatch,
+			},
+		},
+	}
+	if !reflect.DeepEqual(isteps[1], x) {
+		t.Errorf("Expected %+v got %+v", x, isteps[1])
+	}
+
+	x = istep{
+		Token: HasBroader,
+		Arg:   nomatch,
+		Ivals: []Iid{red},
+	}
+	if !reflect.DeepEqual(isteps[2], x) {
+		t.Errorf("Expected %+v got %+v", x, isteps[2])
+	}
+
+	for _, s := range expected {
+		if _, ok := is.GetIid(s); ok {
+			t.Errorf("Expected %s not to be added to the store", s)
+		}
+	}
+}
+
+func TestInternalizeReadOnlyNoSentinel(t *testing.T) {
+	is := &IidStore{
+		toIid:   make(map[string]Iid),
+		fromIid: make(map[Iid]string),
+	}
+
+	steps, err := ParseCommand(`Start[iri].IsInstance[red].Eval`)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, _, err = InternalizeStepsReadOnly(steps, is)
+	if err == nil {
+		t.Errorf("Expected error when the store has no NoMatch iid")
+	}
+}
+
+// A store that counts its calls, to check that batches are used.
+type batchStore struct {
+	*IidStore
+	puts, batches int
+}
+
+func (s *batchStore) Put(iri string) Iid {
+	s.puts++
+	return s.IidStore.Put(iri)
+}
+
+func (s *batchStore) PutMany(strs []string) []Iid {
+	s.batches++
+	iids := make([]Iid, len(strs))
+	for i, str := range strs {
+		iids[i] = s.IidStore.Put(str)
+	}
+	return iids
+}
+
+func (s *batchStore) GetIids(strs []string) ([]Iid, []bool) {
+	s.batches++
+	iids := make([]Iid, len(strs))
+	found := make([]bool, len(strs))
+	for i, str := range strs {
+		iids[i], found[i] = s.IidStore.GetIid(str)
+	}
+	return iids, found
+}
+
+func (s *batchStore) GetStrings(iids []Iid) ([]string, []bool) {
+	s.batches++
+	strs := make([]string, len(iids))
+	found := make([]bool, len(iids))
+	for i, iid := range iids {
+		strs[i], found[i] = s.IidStore.GetString(iid)
+	}
+	return strs, found
+}
+
+func TestInternalizeBatch(t *testing.T) {
+	cmd := `Start[iri].Or(IsInstance[red].IsInstance[blue]).HasBroader[tax, red].Follow[rel].Eval`
+	steps, err := ParseCommand(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := InternalizeSteps(steps, NewIidStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs := &batchStore{IidStore: NewIidStore()}
+	isteps, err := InternalizeSteps(steps, bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bs.batches != 1 || bs.puts != 0 {
+		t.Errorf("Expected 1 batch and no puts got %d batches and %d puts", bs.batches, bs.puts)
+	}
+	if !reflect.DeepEqual(isteps, expected) {
+		t.Errorf("Expected %+v got %+v", expected, isteps)
+	}
+
+	bs.batches = 0
+	isteps, unknown, err := InternalizeStepsReadOnly(steps, bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bs.batches != 1 {
+		t.Errorf("Expected 1 batch got %d", bs.batches)
+	}
+	if len(unknown) != 0 {
+		t.Errorf("Expected no unknown strings got %v", unknown)
+	}
+	if !reflect.DeepEqual(isteps, expected) {
+		t.Errorf("Expected %+v got %+v", expected, isteps)
+	}
+}
+
+// Calls to the internalization hook of Tagged.
+var taggedHookCalls int
+
+// Registers Tagged[tag], whose hook prefixes the tag and counts its calls.
+var registerTagged = sync.OnceValues(func() (Token, error) {
+	return RegisterStep(CustomStep{
+		Name: "Tagged",
+		Args: []ArgKind{IdentArg},
+		Internalize: func(args []string) ([]string, error) {
+			taggedHookCalls++
+			return []string{"tag:" + args[0]}, nil
+		},
+		Eval: func(g Graph, nodes []Iid, args StepArgs) ([]Iid, error) {
+			return nodes, nil
+		},
+	})
+})
+
+func TestInternalizeHookRunsOnce(t *testing.T) {
+	if _, err := registerTagged(); err != nil {
+		t.Fatal(err)
+	}
+	steps, err := ParseCommand(`Start[iri].Or(Tagged[red].IsActive[]).Eval`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bs := &batchStore{IidStore: NewIidStore()}
+	taggedHookCalls = 0
+	isteps, err := InternalizeSteps(steps, bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if taggedHookCalls != 1 {
+		t.Errorf("Expected 1 call to the hook got %d", taggedHookCalls)
+	}
+	if s, _ := bs.GetString(isteps[1].Subcmd[0].Arg); s != "tag:red" {
+		t.Errorf("Expected tag:red got %s", s)
+	}
+
+	taggedHookCalls = 0
+	if _, _, err := InternalizeStepsReadOnly(steps, bs); err != nil {
+		t.Fatal(err)
+	}
+	if taggedHookCalls != 1 {
+		t.Errorf("Expected 1 call to the hook got %d", taggedHookCalls)
+	}
+}
+
+// Returns a well formed step for the token.
+func stepFor(t Token) Step {
+	s := Step{token: Ttoa(t)}
+	switch t {
+	case Start:
+		s.arg = "iri"
+	case HasType, HasCategory, IsInstance, Follow, FollowInverse, InScheme:
+		s.arg = "arg"
+	case HasValue:
+		s.arg = "field"
+		s.vals = []string{"a", "b"}
+	case HasBroader:
+		s.arg = "tax"
+		s.vals = []string{"target"}
+	case Or:
+		s.subcmd = []Step{{token: "IsActive"}}
+	case Limit:
+		s.arg = "10"
+	}
+	return s
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	for tok := NoOp; tok <= Limit; tok++ {
+		name := Ttoa(tok)
+		if name == "**error**" {
+			t.Errorf("Token %d has no name", tok)
+			continue
+		}
+		if Atot(name) != tok {
+			t.Errorf("Expected Atot(%q) to be %d got %d", name, tok, Atot(name))
+		}
+
+		is := NewIidStore()
+		steps, err := InternalizeSteps([]Step{stepFor(tok)}, is)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if len(steps) != 1 || steps[0].Token != tok {
+			t.Errorf("Expected a single %s step got %+v", name, steps)
+		}
+		chain, err := ExternalizeSteps(steps, is)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(chain[0], stepFor(tok)) {
+			t.Errorf("Expected %+v got %+v", stepFor(tok), chain[0])
+		}
+	}
+	if int(Limit)+1 != len(tokenNames) {
+		t.Errorf("Expected the test to cover token %s", Token(int(Limit)+1))
+	}
+}
+
+func TestInternalizeMalformed(t *testing.T) {
+	chains := map[string][]Step{
+		"unknown":  {{token: "Start", arg: "iri"}, {token: "Bogus"}, {token: "Eval"}},
+		"nested":   {{token: "Or", subcmd: []Step{{token: "Bogus"}}}},
+		"broader":  {{token: "HasBroader", arg: "tax"}},
+		"broader2": {{token: "HasBroader", arg: "tax", vals: []string{"a", "b"}}},
+		"value":    {{token: "HasValue", arg: "field"}},
+	}
+	for name, chain := range chains {
+		if _, err := InternalizeSteps(chain, NewIidStore()); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+		if _, err := InternalizeSteps(chain, NewSyncInternalizer()); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"strconv"
+)
+
+// NodeIterator pulls the nodes a plan leads to one at a time, so that every
+// step only does the work needed for the nodes asked for. Next returns false
+// once the nodes are exhausted or evaluation failed, which Err then reports.
+// Close releases the iterator and those it pulls from; it may be called
+// before the nodes are exhausted.
+type NodeIterator interface {
+	Next() (Iid, bool)
+	Err() error
+	Close() error
+}
+
+// Iterates over the nodes the steps lead to in the graph, using the default
+// vocabulary.
+func Iterate(steps []istep, g Graph, is Internalizer) (NodeIterator, error) {
+	return NewEvaluator(g, DefaultVocabulary(), is).Iterate(steps)
+}
+
+// Returns an iterator over the nodes the steps lead to. Filters, Follow and
+// Or pass the nodes on as they are pulled, so a Limit stops the steps before
+// it once it has its nodes. Custom steps and Or with a Limit in an
+// alternative work on the whole set of nodes before them.
+//
+// The nodes are distinct but, unlike those of Evaluate, not in Iid order: a
+// Follow yields the nodes it leads to in the order the graph returns them.
+func (e *Evaluator) Iterate(steps []istep) (NodeIterator, error) {
+	return e.IterateContext(context.Background(), steps)
+}
+
+// Returns an iterator over the nodes the steps lead to, like Iterate. Once
+// the context is done the iterator stops with its error, and once a step
+// goes over the limits of the evaluator with a *LimitExceeded. The iterator
+// must be closed to release the context.
+func (e *Evaluator) IterateContext(ctx context.Context, steps []istep) (NodeIterator, error) {
+	if len(steps) < 2 || steps[0].Token != Start || steps[len(steps)-1].Token != Eval {
+		return nil, fmt.Errorf("expected plan to begin with Start and end with Eval")
+	}
+
+	ev := e.newEvaluation(ctx, steps)
+	if err := ev.checkHops(); err != nil {
+		ev.cancel()
+		return nil, err
+	}
+	// The nodes of Start are the graph itself, they do not count as nodes
+	// held by a step.
+	var it NodeIterator = &guardIterator{
+		in:   &sliceIterator{nodes: ev.graph(0).Nodes()},
+		ev:   ev,
+		step: 0,
+	}
+	for i, s := range steps[1 : len(steps)-1] {
+		next, err := e.iterate(s, ev.graph(i+1), it)
+		if err != nil {
+			it.Close()
+			ev.cancel()
+			return nil, err
+		}
+		it = &guardIterator{in: next, ev: ev, step: i + 1, count: true}
+	}
+	ev.it = it
+	return ev, nil
+}
+
+// Returns the nodes of the iterator as a sequence for range loops. The
+// iterator is closed when the loop ends, Err reports whether it failed.
+func All(it NodeIterator) iter.Seq[Iid] {
+	return func(yield func(Iid) bool) {
+		defer it.Close()
+		for n, ok := it.Next(); ok; n, ok = it.Next() {
+			if !yield(n) {
+				return
+			}
+		}
+	}
+}
+
+// Returns an iterator applying the step to the nodes of in, looked up in g.
+func (e *Evaluator) iterate(s istep, g Graph, in NodeIterator) (NodeIterator, error) {
+	if s.Token == NoOp {
+		return in, nil
+	}
+	if s.Token == Limit {
+		n, err := istepCount(s)
+		if err != nil {
+			return nil, err
+		}
+		return &limitIterator{in: in, n: n}, nil
+	}
+
+	f, ok, err := e.nodeFunc(s, g)
+	switch {
+	case err != nil:
+		return nil, err
+	case !ok:
+		return &setIterator{in: in, step: func(nodes []Iid) ([]Iid, error) { return e.step(s, g, nodes) }}, nil
+	case f.keep != nil:
+		return &filterIterator{in: in, keep: f.keep}, nil
+	default:
+		return &expandIterator{in: in, expand: f.expand, seen: make(map[Iid]bool)}, nil
+	}
+}
+
+// nodeFunc applies a step to each node on its own: a filter keeps or drops
+// the node, an expansion replaces it with the nodes it leads to. One of keep
+// and expand is set.
+type nodeFunc struct {
+	keep   func(Iid) bool
+	expand func(Iid) []Iid
+}
+
+// Returns how the step applies to each node on its own, or false when it
+// needs the whole set of nodes, as custom steps and Limit do.
+func (e *Evaluator) nodeFunc(s istep, g Graph) (nodeFunc, bool, error) {
+	has := func(pred, obj Iid) func(Iid) bool {
+		return func(n Iid) bool { return slices.Contains(g.Out(n, pred), obj) }
+	}
+	switch s.Token {
+	case HasType:
+		return nodeFunc{keep: has(e.typ, s.Arg)}, true, nil
+	case HasCategory:
+		return nodeFunc{keep: has(e.category, s.Arg)}, true, nil
+	case HasValue:
+		return nodeFunc{keep: func(n Iid) bool {
+			return slices.ContainsFunc(g.Values(n, s.Arg), func(v string) bool {
+				return slices.Contains(s.Svals, v)
+			})
+		}}, true, nil
+	case InScheme:
+		return nodeFunc{keep: has(e.inScheme, s.Arg)}, true, nil
+	case HasBroader:
+		if len(s.Ivals) != 1 {
+			return nodeFunc{}, false, fmt.Errorf("expected HasBroader to have 1 target got %d", len(s.Ivals))
+		}
+		inScheme, broader := has(e.inScheme, s.Arg), has(e.broader, s.Ivals[0])
+		return nodeFunc{keep: func(n Iid) bool { return inScheme(n) && broader(n) }}, true, nil
+	case IsInstance:
+		return nodeFunc{keep: func(n Iid) bool { return n == s.Arg }}, true, nil
+	case Follow:
+		return nodeFunc{expand: func(n Iid) []Iid { return g.Out(n, s.Arg) }}, true, nil
+	case FollowInverse:
+		return nodeFunc{expand: func(n Iid) []Iid { return g.In(n, s.Arg) }}, true, nil
+	case IsActive:
+		return nodeFunc{keep: func(n Iid) bool { return slices.Contains(g.Values(n, e.active), e.activeValue) }}, true, nil
+	case IsInactive:
+		return nodeFunc{keep: func(n Iid) bool { return !slices.Contains(g.Values(n, e.active), e.activeValue) }}, true, nil
+	case Or:
+		return e.orFunc(s, g)
+	}
+	return nodeFunc{}, false, nil
+}
+
+// Combines the alternatives of an Or that all apply to each node on its
+// own. Alternatives that filter keep a node when any of them keeps it,
+// otherwise a node leads to the union of what the alternatives lead to.
+func (e *Evaluator) orFunc(s istep, g Graph) (nodeFunc, bool, error) {
+	alts := make([]nodeFunc, len(s.Subcmd))
+	filters := true
+	for i, sub := range s.Subcmd {
+		f, ok, err := e.nodeFunc(sub, g)
+		if err != nil || !ok {
+			return nodeFunc{}, ok, err
+		}
+		alts[i] = f
+		filters = filters && f.keep != nil
+	}
+
+	if filters {
+		return nodeFunc{keep: func(n Iid) bool {
+			return slices.ContainsFunc(alts, func(f nodeFunc) bool { return f.keep(n) })
+		}}, true, nil
+	}
+	return nodeFunc{expand: func(n Iid) []Iid {
+		union := make([]Iid, 0)
+		for _, f := range alts {
+			switch {
+			case f.expand != nil:
+				union = append(union, f.expand(n)...)
+			case f.keep(n):
+				union = append(union, n)
+			}
+		}
+		return union
+	}}, true, nil
+}
+
+// Returns the count of a Limit step.
+func istepCount(s istep) (int, error) {
+	if len(s.Svals) != 1 {
+		return 0, fmt.Errorf("expected Limit to have 1 count got %d", len(s.Svals))
+	}
+	return limitCount(s.Svals[0])
+}
+
+// Parses the count of a Limit step, which must be a positive integer.
+func limitCount(arg string) (int, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("expected Limit[count] with a positive count got %q", arg)
+	}
+	return n, nil
+}
+
+// Iterates over a slice of nodes.
+type sliceIterator struct {
+	nodes []Iid
+}
+
+func (it *sliceIterator) Next() (Iid, bool) {
+	if len(it.nodes) == 0 {
+		return 0, false
+	}
+	n := it.nodes[0]
+	it.nodes = it.nodes[1:]
+	return n, true
+}
+
+func (it *sliceIterator) Err() error { return nil }
+
+func (it *sliceIterator) Close() error {
+	it.nodes = nil
+	return nil
+}
+
+// Passes on the nodes that keep returns true for.
+type filterIterator struct {
+	in   NodeIterator
+	keep func(Iid) bool
+}
+
+func (it *filterIterator) Next() (Iid, bool) {
+	for {
+		n, ok := it.in.Next()
+		if !ok || it.keep(n) {
+			return n, ok
+		}
+	}
+}
+
+func (it *filterIterator) Err() error   { return it.in.Err() }
+func (it *filterIterator) Close() error { return it.in.Close() }
+
+// Replaces every node with the nodes it leads to, leaving out the nodes
+// already passed on.
+type expandIterator struct {
+	in     NodeIterator
+	expand func(Iid) []Iid
+	queue  []Iid
+	seen   map[Iid]bool
+}
+
+func (it *expandIterator) Next() (Iid, bool) {
+	for {
+		for len(it.queue) > 0 {
+			n := it.queue[0]
+			it.queue = it.queue[1:]
+			if !it.seen[n] {
+				it.seen[n] = true
+				return n, true
+			}
+		}
+		n, ok := it.in.Next()
+		if !ok {
+			return 0, false
+		}
+		it.queue = it.expand(n)
+	}
+}
+
+func (it *expandIterator) Err() error   { return it.in.Err() }
+func (it *expandIterator) Close() error { return it.in.Close() }
+
+// Passes on the first n nodes, after which it stops pulling from in.
+type limitIterator struct {
+	in NodeIterator
+	n  int
+}
+
+func (it *limitIterator) Next() (Iid, bool) {
+	if it.n == 0 {
+		return 0, false
+	}
+	n, ok := it.in.Next()
+	if ok {
+		it.n--
+	}
+	return n, ok
+}
+
+func (it *limitIterator) Err() error   { return it.in.Err() }
+func (it *limitIterator) Close() error { return it.in.Close() }
+
+// Collects the nodes of in and applies a step to them as a set, for the
+// steps that cannot work one node at a time.
+type setIterator struct {
+	in   NodeIterator
+	step func([]Iid) ([]Iid, error)
+	out  *sliceIterator
+	err  error
+}
+
+func (it *setIterator) Next() (Iid, bool) {
+	if it.out == nil {
+		it.out = &sliceIterator{}
+		nodes := make([]Iid, 0)
+		for n, ok := it.in.Next(); ok; n, ok = it.in.Next() {
+			nodes = append(nodes, n)
+		}
+		if it.err = it.in.Err(); it.err != nil {
+			return 0, false
+		}
+		var out []Iid
+		if out, it.err = it.step(nodeSet(nodes)); it.err != nil {
+			return 0, false
+		}
+		it.out.nodes = out
+	}
+	return it.out.Next()
+}
+
+func (it *setIterator) Err() error   { return it.err }
+func (it *setIterator) Close() error { return it.in.Close() }
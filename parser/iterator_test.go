@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Counts the calls made to a graph.
+type countingGraph struct {
+	Graph
+	calls int
+}
+
+func (g *countingGraph) Out(node, pred Iid) []Iid {
+	g.calls++
+	return g.Graph.Out(node, pred)
+}
+
+func (g *countingGraph) In(node, pred Iid) []Iid {
+	g.calls++
+	return g.Graph.In(node, pred)
+}
+
+func (g *countingGraph) Values(node, pred Iid) []string {
+	g.calls++
+	return g.Graph.Values(node, pred)
+}
+
+// Parses and internalizes the command and returns an iterator over the test
+// graph, wrapped so that the calls to it are counted.
+func iterateCmd(t *testing.T, cmd string) (NodeIterator, *countingGraph, Internalizer) {
+	t.Helper()
+	is := NewSyncInternalizer()
+	g := &countingGraph{Graph: loadTestGraph(t, is)}
+	chain, err := ParseCommand(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps, err := InternalizeSteps(chain, is)
+	if err != nil {
+		t.Fatal(err)
+	}
+	it, err := Iterate(steps, g, is)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return it, g, is
+}
+
+func names(is Internalizer, nodes []Iid) []string {
+	out := make([]string, len(nodes))
+	for i, n := range nodes {
+		out[i], _ = is.GetString(n)
+	}
+	return out
+}
+
+func TestIterate(t *testing.T) {
+	cmds := []string{
+		`Start[iri].HasType[ex:Gremlin].Eval`,
+		`Start[iri].Or(HasType[ex:Gremlin].HasType[ex:GooGrok]).HasValue[ex:FurColor, "green", "blue"].Eval`,
+		`Start[iri].HasBroader[ex:Animals, ex:Preditor].Follow[ex:SmellOfFood].Follow[ex:ComesFrom].HasType[ex:TastyMeal].Eval`,
+		`Start[iri].HasType[ex:Food].FollowInverse[ex:Eats].IsInactive[].Eval`,
+		`Start[iri].IsInstance[ex:gizmo].Or(Follow[ex:Eats].Follow[ex:LivesIn].IsActive[]).Eval`,
+		`Start[iri].Follow[ex:Eats].Eval`,
+	}
+	for _, cmd := range cmds {
+		it, _, is := iterateCmd(t, cmd)
+		got := names(is, slices.Collect(All(it)))
+		slices.Sort(got)
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+		expected := evalCmd(t, cmd)
+		slices.Sort(expected)
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected %v got %v", cmd, expected, got)
+		}
+	}
+}
+
+func TestLimit(t *testing.T) {
+	tests := []struct {
+		cmd      string
+		expected []string
+	}{
+		{`Start[iri].HasType[ex:Gremlin].Limit[1].Eval`, []string{"ex:gizmo"}},
+		{`Start[iri].HasType[ex:Gremlin].Limit[5].Eval`, []string{"ex:gizmo", "ex:stripe"}},
+		{`Start[iri].Limit[2].HasType[ex:Gremlin].Eval`, []string{"ex:gizmo"}},
+		{`Start[iri].InScheme[ex:Animals].Or(Limit[1].HasType[ex:GooGrok]).Eval`, []string{"ex:gizmo", "ex:blob"}},
+	}
+	for _, test := range tests {
+		if got := evalCmd(t, test.cmd); !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%s: expected %v got %v", test.cmd, test.expected, got)
+		}
+	}
+
+	for _, cmd := range []string{`Start[iri].Limit[0].Eval`, `Start[iri].Limit[many].Eval`} {
+		chain, err := ParseCommand(cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := InternalizeSteps(chain, NewIidStore()); err == nil || !strings.Contains(err.Error(), "positive count") {
+			t.Errorf("%s: expected a count error got %v", cmd, err)
+		}
+	}
+}
+
+func TestLimitStopsUpstream(t *testing.T) {
+	full, fg, _ := iterateCmd(t, `Start[iri].HasType[ex:Gremlin].Follow[ex:Eats].Eval`)
+	for range All(full) {
+	}
+
+	it, g, _ := iterateCmd(t, `Start[iri].HasType[ex:Gremlin].Follow[ex:Eats].Limit[1].Eval`)
+	if g.calls != 0 {
+		t.Errorf("Expected no calls before the first pull got %d", g.calls)
+	}
+	if n := len(slices.Collect(All(it))); n != 1 {
+		t.Errorf("Expected 1 node got %d", n)
+	}
+	if g.calls >= fg.calls {
+		t.Errorf("Expected fewer than %d calls to the graph got %d", fg.calls, g.calls)
+	}
+}
+
+func TestAllStopsEarly(t *testing.T) {
+	it, g, _ := iterateCmd(t, `Start[iri].HasType[ex:Food].Eval`)
+	for range All(it) {
+		break
+	}
+	calls := g.calls
+	if _, ok := it.Next(); ok {
+		t.Errorf("Expected the iterator to be closed")
+	}
+	if g.calls != calls {
+		t.Errorf("Expected no calls after the loop got %d", g.calls-calls)
+	}
+}
+
+func TestIterateCustomStep(t *testing.T) {
+	if _, err := registerHasName(); err != nil {
+		t.Fatal(err)
+	}
+	it, _, is := iterateCmd(t, `Start[iri].HasType[ex:Gremlin].HasName[ex:Name, "stripe"].Limit[1].Eval`)
+	got := names(is, slices.Collect(All(it)))
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, []string{"ex:stripe"}) {
+		t.Errorf("Expected [ex:stripe] got %v", got)
+	}
+}
@@ -0,0 +1,252 @@
+package parser
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+// JSONVersion is the version of the JSON format for chains and plans. It is
+// incremented whenever the format changes in an incompatible way.
+const JSONVersion = 1
+
+// JSONSchema is the JSON Schema describing the chain and plan payloads.
+//
+//go:embed bremlin.schema.json
+var JSONSchema []byte
+
+type stepJSON struct {
+	Token  string   `json:"token"`
+	Arg    string   `json:"arg,omitempty"`
+	Vals   []string `json:"vals,omitempty"`
+	Subcmd []Step   `json:"subcmd,omitempty"`
+}
+
+func (s Step) MarshalJSON() ([]byte, error) {
+	return json.Marshal(stepJSON{
+		Token:  s.token,
+		Arg:    s.arg,
+		Vals:   s.vals,
+		Subcmd: s.subcmd,
+	})
+}
+
+func (s *Step) UnmarshalJSON(b []byte) error {
+	var sj stepJSON
+	if err := json.Unmarshal(b, &sj); err != nil {
+		return err
+	}
+	if Atot(sj.Token) == 0 {
+		return fmt.Errorf("unknown token %q", sj.Token)
+	}
+	*s = Step{
+		token:  sj.Token,
+		arg:    sj.Arg,
+		vals:   sj.Vals,
+		subcmd: sj.Subcmd,
+	}
+	return nil
+}
+
+type chainJSON struct {
+	Version int    `json:"version"`
+	Steps   []Step `json:"steps"`
+}
+
+// Marshals a chain of steps to the versioned JSON format.
+func MarshalChain(chain []Step) ([]byte, error) {
+	return json.Marshal(chainJSON{
+		Version: JSONVersion,
+		Steps:   chain,
+	})
+}
+
+// Unmarshals a chain of steps from the versioned JSON format.
+func UnmarshalChain(b []byte) ([]Step, error) {
+	var cj chainJSON
+	if err := json.Unmarshal(b, &cj); err != nil {
+		return nil, err
+	}
+	if cj.Version != JSONVersion {
+		return nil, fmt.Errorf("unsupported chain version %d, expected %d", cj.Version, JSONVersion)
+	}
+	return cj.Steps, nil
+}
+
+type istepJSON struct {
+	Token  string   `json:"token"`
+	Arg    *Iid     `json:"arg,omitempty"`
+	Ivals  []Iid    `json:"ivals,omitempty"`
+	Svals  []string `json:"svals,omitempty"`
+	Subcmd []istep  `json:"subcmd,omitempty"`
+}
+
+func (s istep) MarshalJSON() ([]byte, error) {
+	sj := istepJSON{
+		Token:  Ttoa(s.Token),
+		Ivals:  s.Ivals,
+		Svals:  s.Svals,
+		Subcmd: s.Subcmd,
+	}
+	if hasIidArg(s.Token) {
+		arg := s.Arg
+		sj.Arg = &arg
+	}
+	return json.Marshal(sj)
+}
+
+func (s *istep) UnmarshalJSON(b []byte) error {
+	var sj istepJSON
+	if err := json.Unmarshal(b, &sj); err != nil {
+		return err
+	}
+	t := Atot(sj.Token)
+	if t == 0 {
+		return fmt.Errorf("unknown token %q", sj.Token)
+	}
+	*s = istep{
+		Token:  t,
+		Ivals:  sj.Ivals,
+		Svals:  sj.Svals,
+		Subcmd: sj.Subcmd,
+	}
+	if sj.Arg != nil {
+		s.Arg = *sj.Arg
+	}
+	return nil
+}
+
+// Plan is an internalized chain together with the strings its Iids stand
+// for, so that it can be loaded by a process with a different Internalizer.
+type Plan struct {
+	steps []istep
+	dict  map[Iid]string
+}
+
+// Creates a plan from internalized steps, looking up the string of every
+// Iid the steps refer to.
+func NewPlan(steps []istep, is Internalizer) (*Plan, error) {
+	p := &Plan{
+		steps: steps,
+		dict:  make(map[Iid]string),
+	}
+	for _, i := range planIids(steps, nil) {
+		s, ok := is.GetString(i)
+		if !ok {
+			return nil, fmt.Errorf("no string for iid %d", i)
+		}
+		p.dict[i] = s
+	}
+	return p, nil
+}
+
+// Returns the internalized steps of the plan.
+func (p *Plan) Steps() []istep {
+	return p.steps
+}
+
+// Returns the string an Iid of the plan stands for.
+func (p *Plan) Lookup(i Iid) (string, bool) {
+	s, ok := p.dict[i]
+	return s, ok
+}
+
+// Rewrites the plan to the Iids of another Internalizer, adding strings
+// that are not yet known to it.
+func (p *Plan) Rebind(is Internalizer) ([]istep, error) {
+	var rebind func(steps []istep) ([]istep, error)
+	rebind = func(steps []istep) ([]istep, error) {
+		out := make([]istep, len(steps))
+		for i, s := range steps {
+			out[i] = istep{
+				Token: s.Token,
+				Svals: s.Svals,
+			}
+			if hasIidArg(s.Token) {
+				str, ok := p.dict[s.Arg]
+				if !ok {
+					return nil, fmt.Errorf("no string for iid %d", s.Arg)
+				}
+				out[i].Arg = is.Put(str)
+			}
+			for _, v := range s.Ivals {
+				str, ok := p.dict[v]
+				if !ok {
+					return nil, fmt.Errorf("no string for iid %d", v)
+				}
+				out[i].Ivals = append(out[i].Ivals, is.Put(str))
+			}
+			if s.Subcmd != nil {
+				sub, err := rebind(s.Subcmd)
+				if err != nil {
+					return nil, err
+				}
+				out[i].Subcmd = sub
+			}
+		}
+		return out, nil
+	}
+	steps, err := rebind(p.steps)
+	if err != nil {
+		return nil, err
+	}
+	if err := internalizerErr(is); err != nil {
+		return nil, fmt.Errorf("cannot rebind plan: %w", err)
+	}
+	return steps, nil
+}
+
+// Returns the distinct Iids referred to by the steps.
+func planIids(steps []istep, iids []Iid) []Iid {
+	add := func(i Iid) {
+		if !slices.Contains(iids, i) {
+			iids = append(iids, i)
+		}
+	}
+	for _, s := range steps {
+		if hasIidArg(s.Token) {
+			add(s.Arg)
+		}
+		for _, v := range s.Ivals {
+			add(v)
+		}
+		iids = planIids(s.Subcmd, iids)
+	}
+	return iids
+}
+
+type planJSON struct {
+	Version    int            `json:"version"`
+	Dictionary map[Iid]string `json:"dictionary"`
+	Steps      []istep        `json:"steps"`
+}
+
+func (p *Plan) MarshalJSON() ([]byte, error) {
+	return json.Marshal(planJSON{
+		Version:    JSONVersion,
+		Dictionary: p.dict,
+		Steps:      p.steps,
+	})
+}
+
+func (p *Plan) UnmarshalJSON(b []byte) error {
+	var pj planJSON
+	if err := json.Unmarshal(b, &pj); err != nil {
+		return err
+	}
+	if pj.Version != JSONVersion {
+		return fmt.Errorf("unsupported plan version %d, expected %d", pj.Version, JSONVersion)
+	}
+	if pj.Dictionary == nil {
+		pj.Dictionary = make(map[Iid]string)
+	}
+	for _, i := range planIids(pj.Steps, nil) {
+		if _, ok := pj.Dictionary[i]; !ok {
+			return fmt.Errorf("iid %d missing from plan dictionary", i)
+		}
+	}
+	p.steps = pj.Steps
+	p.dict = pj.Dictionary
+	return nil
+}
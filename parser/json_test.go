@@ -0,0 +1,208 @@
+package parser
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestChainJSONRoundTrip(t *testing.T) {
+	chain, err := ParseCommand(`Start[iri].Or(HasType[ex:Gremlin].HasType[ex:GooGrok]).HasValue[ex:FurColor, "green", "blue"].Eval`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := MarshalChain(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"version":1,"steps":[{"token":"Start","arg":"iri"},` +
+		`{"token":"Or","subcmd":[{"token":"HasType","arg":"ex:Gremlin"},{"token":"HasType","arg":"ex:GooGrok"}]},` +
+		`{"token":"HasValue","arg":"ex:FurColor","vals":["green","blue"]},{"token":"Eval"}]}`
+	if string(b) != expected {
+		t.Errorf("Expected %s got %s", expected, b)
+	}
+
+	back, err := UnmarshalChain(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, chain) {
+		t.Errorf("Expected %+v got %+v", chain, back)
+	}
+}
+
+func TestChainJSONVersion(t *testing.T) {
+	_, err := UnmarshalChain([]byte(`{"version":2,"steps":[]}`))
+	if err == nil {
+		t.Errorf("Expected error for unsupported version")
+	}
+}
+
+func TestChainJSONUnknownToken(t *testing.T) {
+	_, err := UnmarshalChain([]byte(`{"version":1,"steps":[{"token":"HasColour"}]}`))
+	if err == nil {
+		t.Errorf("Expected error for unknown token")
+	}
+}
+
+func TestPlanJSONRoundTrip(t *testing.T) {
+	is := NewIidStore()
+	chain, err := ParseCommand(`Start[iri].Or(IsInstance[red].HasBroader[tax, node]).HasValue[color, "blue"].Eval`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps, err := InternalizeSteps(chain, is)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := NewPlan(steps, is)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"dictionary":{"2":"red","3":"tax","4":"node","5":"color"}`) {
+		t.Errorf("Unexpected dictionary in %s", b)
+	}
+
+	var back Plan
+	if err := json.Unmarshal(b, &back); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back.Steps(), steps) {
+		t.Errorf("Expected %+v got %+v", steps, back.Steps())
+	}
+	if s, ok := back.Lookup(5); !ok || s != "color" {
+		t.Errorf("Expected color got %s", s)
+	}
+}
+
+func TestPlanRebind(t *testing.T) {
+	is := NewIidStore()
+	chain, err := ParseCommand(`Start[iri].HasBroader[tax, node].Eval`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps, err := InternalizeSteps(chain, is)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan, err := NewPlan(steps, is)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := NewIidStore()
+	other.Put("something else")
+	node := other.Put("node")
+	rebound, err := plan.Rebind(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tax, _ := other.GetIid("tax")
+	x := istep{
+		Token: HasBroader,
+		Arg:   tax,
+		Ivals: []Iid{node},
+	}
+	if !reflect.DeepEqual(rebound[1], x) {
+		t.Errorf("Expected %+v got %+v", x, rebound[1])
+	}
+}
+
+func TestPlanJSONMissingDictionaryEntry(t *testing.T) {
+	var p Plan
+	err := json.Unmarshal([]byte(`{"version":1,"dictionary":{},"steps":[{"token":"HasType","arg":7}]}`), &p)
+	if err == nil {
+		t.Errorf("Expected error for iid missing from dictionary")
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	var schema struct {
+		Defs map[string]struct {
+			Pattern    string                     `json:"pattern"`
+			Examples   []string                   `json:"examples"`
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(JSONSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	token := schema.Defs["token"]
+	if token.Pattern != stepName.String() {
+		t.Errorf("Expected token pattern %s got %s", stepName, token.Pattern)
+	}
+	for t0 := NoOp; t0 <= Limit; t0++ {
+		if !slices.Contains(token.Examples, Ttoa(t0)) {
+			t.Errorf("Expected token %s in schema", Ttoa(t0))
+		}
+	}
+
+	for _, def := range []string{"chain", "plan"} {
+		var version struct {
+			Const int `json:"const"`
+		}
+		if err := json.Unmarshal(schema.Defs[def].Properties["version"], &version); err != nil {
+			t.Fatal(err)
+		}
+		if version.Const != JSONVersion {
+			t.Errorf("Expected %s version %d in schema got %d", def, JSONVersion, version.Const)
+		}
+	}
+}
+
+func TestJSONSchemaCustomStep(t *testing.T) {
+	if _, err := registerHasName(); err != nil {
+		t.Fatal(err)
+	}
+	chain, err := ParseCommand(`Start[iri].HasName[ex:Name, "GIZMO"].Eval`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := MarshalChain(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var schema struct {
+		Defs map[string]struct {
+			Pattern string `json:"pattern"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(JSONSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	var payload struct {
+		Steps []struct {
+			Token string `json:"token"`
+		} `json:"steps"`
+	}
+	if err := json.Unmarshal(b, &payload); err != nil {
+		t.Fatal(err)
+	}
+	pattern := regexp.MustCompile(schema.Defs["token"].Pattern)
+	for _, s := range payload.Steps {
+		if !pattern.MatchString(s.Token) {
+			t.Errorf("Expected token %s to match the schema", s.Token)
+		}
+	}
+
+	back, err := UnmarshalChain(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, chain) {
+		t.Errorf("Expected %+v got %+v", chain, back)
+	}
+}
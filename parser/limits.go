@@ -0,0 +1,292 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Limits bounds the work of an evaluation, so that a runaway traversal
+// fails instead of holding a server. A zero field sets no limit.
+type Limits struct {
+	// Nodes a step after Start may pass on.
+	Nodes int
+	// Follow and FollowInverse steps in a plan. An Or counts as one hop
+	// when any of its alternatives is one.
+	Hops int
+	// Calls to the Graph.
+	Calls int
+	// Time an evaluation may take, from the start of IterateContext.
+	Time time.Duration
+}
+
+// LimitKind is the limit an evaluation went over.
+type LimitKind int
+
+const (
+	LimitNodes LimitKind = iota + 1
+	LimitHops
+	LimitCalls
+	LimitTime
+)
+
+func (k LimitKind) String() string {
+	switch k {
+	case LimitNodes:
+		return "nodes"
+	case LimitHops:
+		return "hops"
+	case LimitCalls:
+		return "backend calls"
+	case LimitTime:
+		return "time"
+	default:
+		return "unknown"
+	}
+}
+
+// LimitExceeded is the error of an evaluation that went over one of its
+// Limits.
+type LimitExceeded struct {
+	Kind LimitKind
+	// Index of the step that hit the limit in the plan.
+	Index int
+	// The step that hit the limit as written in commands, e.g.
+	// Follow[ex:Eats].
+	Step string
+
+	limit string
+}
+
+func (e *LimitExceeded) Error() string {
+	return fmt.Sprintf("step %d %s exceeded the limit of %s", e.Index, e.Step, e.limit)
+}
+
+// Sets the limits of the evaluations started after the call.
+func (e *Evaluator) SetLimits(l Limits) {
+	e.limits = l
+}
+
+// The cause of the context of an evaluation that went over Limits.Time.
+var errTimeLimit = errors.New("time limit exceeded")
+
+// evaluation is the NodeIterator of a plan. It holds what the steps of the
+// plan have used so far, and stops them once the context is done or a limit
+// is exceeded.
+type evaluation struct {
+	e      *Evaluator
+	steps  []istep
+	ctx    context.Context
+	cancel context.CancelFunc
+	it     NodeIterator // the last step of the plan
+	calls  int
+	err    error
+	closed bool
+}
+
+func (e *Evaluator) newEvaluation(ctx context.Context, steps []istep) *evaluation {
+	ev := &evaluation{e: e, steps: steps}
+	if e.limits.Time > 0 {
+		ev.ctx, ev.cancel = context.WithTimeoutCause(ctx, e.limits.Time, errTimeLimit)
+	} else {
+		ev.ctx, ev.cancel = context.WithCancel(ctx)
+	}
+	return ev
+}
+
+func (ev *evaluation) Next() (Iid, bool) {
+	return ev.it.Next()
+}
+
+func (ev *evaluation) Err() error {
+	if ev.err != nil {
+		return ev.err
+	}
+	return ev.it.Err()
+}
+
+func (ev *evaluation) Close() error {
+	ev.closed = true
+	ev.cancel()
+	return ev.it.Close()
+}
+
+// Records that the step went over a limit.
+func (ev *evaluation) exceeded(kind LimitKind, step int, limit string) {
+	ev.err = &LimitExceeded{
+		Kind:  kind,
+		Index: step,
+		Step:  ev.e.describe(ev.steps[step]),
+		limit: limit,
+	}
+}
+
+// Reports whether the step may go on, recording why not otherwise.
+func (ev *evaluation) check(step int) bool {
+	if ev.err != nil || ev.closed {
+		return false
+	}
+	if err := ev.ctx.Err(); err != nil {
+		if context.Cause(ev.ctx) == errTimeLimit {
+			ev.exceeded(LimitTime, step, ev.e.limits.Time.String())
+		} else {
+			ev.err = err
+		}
+		return false
+	}
+	return true
+}
+
+// Reports whether the step may call the graph and counts the call.
+func (ev *evaluation) call(step int) bool {
+	if !ev.check(step) {
+		return false
+	}
+	ev.calls++
+	if max := ev.e.limits.Calls; max > 0 && ev.calls > max {
+		ev.exceeded(LimitCalls, step, fmt.Sprintf("%d backend calls", max))
+		return false
+	}
+	return true
+}
+
+// Returns the graph as seen by a step: every call is counted and, once the
+// evaluation is stopped, answered with nothing.
+func (ev *evaluation) graph(step int) Graph {
+	return stepGraph{Graph: ev.e.graph, ev: ev, step: step}
+}
+
+type stepGraph struct {
+	Graph
+	ev   *evaluation
+	step int
+}
+
+func (g stepGraph) Nodes() []Iid {
+	if !g.ev.call(g.step) {
+		return nil
+	}
+	return g.Graph.Nodes()
+}
+
+func (g stepGraph) Out(node, pred Iid) []Iid {
+	if !g.ev.call(g.step) {
+		return nil
+	}
+	return g.Graph.Out(node, pred)
+}
+
+func (g stepGraph) In(node, pred Iid) []Iid {
+	if !g.ev.call(g.step) {
+		return nil
+	}
+	return g.Graph.In(node, pred)
+}
+
+func (g stepGraph) Values(node, pred Iid) []string {
+	if !g.ev.call(g.step) {
+		return nil
+	}
+	return g.Graph.Values(node, pred)
+}
+
+// Passes on the nodes of a step while the evaluation may go on, counting
+// them against Limits.Nodes when count is set.
+type guardIterator struct {
+	in    NodeIterator
+	ev    *evaluation
+	step  int
+	count bool
+	nodes int
+}
+
+func (it *guardIterator) Next() (Iid, bool) {
+	if !it.ev.check(it.step) {
+		return 0, false
+	}
+	n, ok := it.in.Next()
+	if !ok || it.ev.err != nil {
+		return 0, false
+	}
+	if it.count {
+		it.nodes++
+		if max := it.ev.e.limits.Nodes; max > 0 && it.nodes > max {
+			it.ev.exceeded(LimitNodes, it.step, fmt.Sprintf("%d nodes", max))
+			return 0, false
+		}
+	}
+	return n, true
+}
+
+func (it *guardIterator) Err() error {
+	if it.ev.err != nil {
+		return it.ev.err
+	}
+	return it.in.Err()
+}
+
+func (it *guardIterator) Close() error { return it.in.Close() }
+
+// Checks the plan against Limits.Hops.
+func (ev *evaluation) checkHops() error {
+	max := ev.e.limits.Hops
+	if max == 0 {
+		return nil
+	}
+	hops := 0
+	for i, s := range ev.steps {
+		if isHop(s) {
+			hops++
+		}
+		if hops > max {
+			ev.exceeded(LimitHops, i, fmt.Sprintf("%d hops", max))
+			return ev.err
+		}
+	}
+	return nil
+}
+
+func isHop(s istep) bool {
+	if s.Token == Follow || s.Token == FollowInverse {
+		return true
+	}
+	for _, sub := range s.Subcmd {
+		if isHop(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// Describes a step as it is written in commands.
+func (e *Evaluator) describe(s istep) string {
+	d, ok := LookupToken(s.Token)
+	switch {
+	case !ok:
+		return Ttoa(s.Token)
+	case d.Token == Start:
+		return "Start[iri]"
+	case d.Token == Eval || d.Subcmd:
+		return d.Name()
+	}
+
+	args := istepArgs(s)
+	strs := make([]string, 0, len(args.Iids)+len(args.Literals))
+	for _, i := range args.Iids {
+		str, ok := e.is.GetString(i)
+		if !ok {
+			str = "?"
+		}
+		strs = append(strs, bremlinArg(str))
+	}
+	for _, l := range args.Literals {
+		if d.Token == Limit {
+			strs = append(strs, l)
+		} else {
+			strs = append(strs, bremlinString(l))
+		}
+	}
+	return d.Name() + "[" + strings.Join(strs, ", ") + "]"
+}
@@ -0,0 +1,88 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Evaluates the command against the test graph with the limits and returns
+// the error.
+func evalLimited(t *testing.T, ctx context.Context, cmd string, l Limits) error {
+	t.Helper()
+	is := NewSyncInternalizer()
+	g := loadTestGraph(t, is)
+	chain, err := ParseCommand(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps, err := InternalizeSteps(chain, is)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := NewEvaluator(g, DefaultVocabulary(), is)
+	e.SetLimits(l)
+	_, err = e.EvaluateContext(ctx, steps)
+	return err
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		cmd    string
+		limits Limits
+		kind   LimitKind
+		index  int
+		msg    string
+	}{
+		{`Start[iri].HasType[ex:Gremlin].Eval`, Limits{Nodes: 1}, LimitNodes, 1,
+			"step 1 HasType[ex:Gremlin] exceeded the limit of 1 nodes"},
+		{`Start[iri].Follow[ex:SmellOfFood].Or(Follow[ex:ComesFrom].IsActive[]).Eval`, Limits{Hops: 1}, LimitHops, 2,
+			"step 2 Or exceeded the limit of 1 hops"},
+		{`Start[iri].HasType[ex:Food].Follow[ex:ComesFrom].Eval`, Limits{Calls: 3}, LimitCalls, 1,
+			"step 1 HasType[ex:Food] exceeded the limit of 3 backend calls"},
+		{`Start[iri].HasValue[ex:FurColor, "green"].Limit[5].Eval`, Limits{Time: time.Nanosecond}, LimitTime, 0, ""},
+	}
+	for _, test := range tests {
+		err := evalLimited(t, context.Background(), test.cmd, test.limits)
+		var le *LimitExceeded
+		if !errors.As(err, &le) {
+			t.Errorf("%s: expected LimitExceeded got %v", test.cmd, err)
+			continue
+		}
+		if le.Kind != test.kind || (test.msg != "" && (le.Index != test.index || err.Error() != test.msg)) {
+			t.Errorf("%s: expected %s at step %d got %v", test.cmd, test.kind, test.index, err)
+		}
+	}
+}
+
+func TestLimitsNotReached(t *testing.T) {
+	cmds := []string{
+		// The Limit stops HasType before it passes on a second node.
+		`Start[iri].HasType[ex:Gremlin].Limit[1].Eval`,
+		`Start[iri].IsInstance[ex:gizmo].Follow[ex:Eats].Eval`,
+	}
+	for _, cmd := range cmds {
+		if err := evalLimited(t, context.Background(), cmd, Limits{Nodes: 1, Hops: 1, Calls: 100, Time: time.Minute}); err != nil {
+			t.Errorf("%s: %v", cmd, err)
+		}
+	}
+}
+
+func TestEvaluateContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := evalLimited(t, ctx, `Start[iri].Follow[ex:Eats].Eval`, Limits{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled got %v", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	err = evalLimited(t, ctx, `Start[iri].Follow[ex:Eats].Eval`, Limits{Time: time.Minute})
+	var le *LimitExceeded
+	if !errors.Is(err, context.DeadlineExceeded) || errors.As(err, &le) {
+		t.Errorf("Expected context.DeadlineExceeded got %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package parser
+
+// GraphMapping describes how steps map onto a labelled property graph for
+// the Cypher and Gremlin translations. Nodes are identified by the value of
+// IdProperty, relationship arguments to Follow and FollowInverse become edge
+// labels and HasValue fields become property keys.
+type GraphMapping struct {
+	IdProperty       string
+	TypeAsLabel      bool   // HasType matches node labels instead of TypeProperty
+	TypeProperty     string // HasType when TypeAsLabel is false
+	CategoryProperty string // HasCategory
+	SchemeEdge       string // InScheme and HasBroader
+	BroaderEdge      string // HasBroader
+	ActiveProperty   string // IsActive and IsInactive
+	ActiveValue      string
+
+	// Names renames step arguments, e.g. ex:Gremlin to Gremlin. Arguments
+	// without an entry are used as is.
+	Names map[string]string
+}
+
+func DefaultGraphMapping() GraphMapping {
+	return GraphMapping{
+		IdProperty:       "iri",
+		TypeAsLabel:      true,
+		TypeProperty:     "type",
+		CategoryProperty: "category",
+		SchemeEdge:       "inScheme",
+		BroaderEdge:      "broader",
+		ActiveProperty:   "active",
+		ActiveValue:      "true",
+		Names:            make(map[string]string),
+	}
+}
+
+// Returns the name an argument is mapped to.
+func (m GraphMapping) name(arg string) string {
+	if n, ok := m.Names[arg]; ok {
+		return n
+	}
+	return arg
+}
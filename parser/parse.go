@@ -10,12 +10,45 @@ type Step struct {
 	arg    string
 	vals   []string
 	subcmd []Step
+
+	// Comments before the step and on its line, kept for the formatter.
+	leading  []string
+	trailing []string
 }
 
+// The name of the step, such as HasType.
+func (s Step) Token() string { return s.token }
+
+// The first argument of the step.
+func (s Step) Arg() string { return s.arg }
+
+// The arguments of the step after the first.
+func (s Step) Vals() []string { return s.vals }
+
+// The alternatives of an Or step.
+func (s Step) Subcmd() []Step { return s.subcmd }
+
 // Parses the full command, including the Start and Eval clauses and
-// returns a list of steps to be executed.
+// returns a list of steps to be executed. Comments are kept with the steps
+// they belong to.
 func ParseCommand(cmd string) ([]Step, error) {
-	cmd, err := cleanCmd(cmd)
+	chain, err := parseCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := findUse(chain); ok {
+		return nil, fmt.Errorf("unknown fragment %s, fragments are defined in rule files", s.arg)
+	}
+	return chain, nil
+}
+
+// Parses a command that may use fragments.
+func parseCommand(cmd string) ([]Step, error) {
+	cmd, comments, err := extractComments(cmd)
+	if err != nil {
+		return nil, err
+	}
+	cmd, err = cleanCmd(cmd)
 	if err != nil {
 		return nil, err
 	}
@@ -39,6 +72,7 @@ func ParseCommand(cmd string) ([]Step, error) {
 	copy(chain[1:], subchain)
 	chain[len(chain)-1] = eval
 
+	attachComments(chain, comments)
 	return chain, nil
 }
 
@@ -67,15 +101,15 @@ func parseSubCommand(cmd string, inor bool) ([]Step, error) {
 
 // Parses the body of a command, breaking it into steps.
 func parseBody(cmd string, inor bool) (Step, string, bool, error) {
-	io := inor  // inside or
-	iq := false // inside quotes
+	io := inor // inside or
+	var q quoteScanner
 	is := false // inside square brackets
 	buf := make([]rune, 0)
 
 	for i, c := range cmd {
+		iq := q.next(c)
 		switch c {
 		case '"':
-			iq = !iq
 		case '[', ']':
 			is = !is
 		case '.':
@@ -87,23 +121,24 @@ func parseBody(cmd string, inor bool) (Step, string, bool, error) {
 			s, io, err := parseStep(c, io)
 			return s, cmd, io, err
 		case '(':
-			if string(buf) != "Or" {
+			d, ok := LookupStep(string(buf))
+			if !ok || !d.Subcmd {
 				continue
 			}
-			_ = cmd[:i]
+			name := string(buf)
 			i, ok := findNextStandaloneRune(cmd, ')')
 			if !ok {
-				err := fmt.Errorf("expected Or(step1, ...) got %s", cmd)
+				err := fmt.Errorf("expected %s(step1, ...) got %s", name, cmd)
 				return Step{}, cmd, io, err
 			}
-			subcmd := cmd[3:i]
+			subcmd := cmd[len(name)+1 : i]
 			s, err := parseSubCommand(subcmd, true)
 			if err != nil {
 				return Step{}, cmd, io, err
 			}
 			cmd = cmd[i+1:]
 			return Step{
-				token:  "Or",
+				token:  name,
 				subcmd: s,
 			}, cmd, io, err
 		default:
@@ -114,7 +149,8 @@ func parseBody(cmd string, inor bool) (Step, string, bool, error) {
 	return s, "", io, err
 }
 
-// Parses a single step in the command, checking the arguments and values.
+// Parses a single step in the command, checking the arguments and values
+// against the declaration of the step.
 func parseStep(cmd string, inor bool) (Step, bool, error) {
 	if cmd == "" {
 		return Step{
@@ -122,120 +158,52 @@ func parseStep(cmd string, inor bool) (Step, bool, error) {
 		}, inor, nil
 	}
 
-	if strings.HasPrefix(cmd, "IsActive") {
-		// IsActive takes no arguments
-		t, err := parseNoArgStep(cmd)
-		if err != nil {
-			return Step{}, inor, fmt.Errorf("failed to parse IsActive (%s)", err)
-		}
-		return Step{
-			token: t,
-		}, inor, nil
+	name, _ := splitOnRune(cmd, '[')
+	if name == "" {
+		name = cmd
 	}
-
-	if strings.HasPrefix(cmd, "IsInactive") {
-		// IsInactive takes no arguments
-		t, err := parseNoArgStep(cmd)
+	if name == useStep {
+		_, args, err := parseMultiArgStep(cmd, 1, -1)
 		if err != nil {
-			return Step{}, inor, fmt.Errorf("failed to parse IsInactive (%s)", err)
+			return Step{}, inor, fmt.Errorf("failed to parse %s (%s)", name, err)
 		}
-		return Step{
-			token: t,
-		}, inor, nil
-	}
-
-	if strings.HasPrefix(cmd, "HasType") {
-		// HasType takes a single argument which is a type iri
-		t, arg, err := parseSingleArgStep(cmd)
-		if err != nil {
-			return Step{}, inor, fmt.Errorf("failed to parse HasType (%s)", err)
+		step := Step{
+			token: useStep,
+			arg:   args[0],
 		}
-		return Step{
-			token: t,
-			arg:   arg,
-		}, inor, nil
-	}
-
-	if strings.HasPrefix(cmd, "HasCategory") {
-		// HasCategory takes a single argument which is a category iid
-		t, arg, err := parseSingleArgStep(cmd)
-		if err != nil {
-			return Step{}, inor, fmt.Errorf("failed to parse HasCategory (%s)", err)
+		if len(args) > 1 {
+			step.vals = args[1:]
 		}
-		return Step{
-			token: t,
-			arg:   arg,
-		}, inor, nil
+		return step, inor, nil
 	}
-
-	if strings.HasPrefix(cmd, "HasValue") {
-		// HasValue takes a field name and a list of values
-		t, args, err := parseMultiArgStep(cmd, 2, -1)
-		if err != nil {
-			return Step{}, inor, fmt.Errorf("failed to parse HasValue (%s)", err)
-		}
-		return Step{
-			token: t,
-			arg:   args[0],
-			vals:  args[1:],
-		}, inor, nil
+	d, ok := LookupStep(name)
+	if !ok || d.Token == Start || d.Token == Eval || d.Subcmd {
+		return Step{}, inor, fmt.Errorf("unknown step %s", name)
 	}
 
-	if strings.HasPrefix(cmd, "InScheme") {
-		// InScheme takes a single argument which is a taxonomy iri
-		t, arg, err := parseSingleArgStep(cmd)
+	min, max := d.Arity()
+	if max == 0 {
+		t, err := parseNoArgStep(cmd)
 		if err != nil {
-			return Step{}, inor, fmt.Errorf("failed to parse InScheme (%s)", err)
+			return Step{}, inor, fmt.Errorf("failed to parse %s (%s)", name, err)
 		}
 		return Step{
 			token: t,
-			arg:   arg,
 		}, inor, nil
 	}
 
-	if strings.HasPrefix(cmd, "HasBroader") {
-		// HasBroader takes two arguments, the first is the taxonomy iri the second
-		// is the tatget node
-		t, args, err := parseMultiArgStep(cmd, 2, 2)
-		if err != nil {
-			return Step{}, inor, fmt.Errorf("failed to parse HasBroader (%s)", err)
-		}
-		if len(args) != 2 {
-			return Step{}, inor, fmt.Errorf("expected HasBroader[taxonomy, target] got %s", cmd)
-		}
-		return Step{
-			token: t,
-			arg:   args[0],
-			vals:  []string{args[1]},
-		}, inor, nil
+	t, args, err := parseMultiArgStep(cmd, min, max)
+	if err != nil {
+		return Step{}, inor, fmt.Errorf("failed to parse %s (%s)", name, err)
 	}
-
-	if strings.HasPrefix(cmd, "IsInstance") {
-		// IsInstance takes a single argument which is the instance iri
-		t, arg, err := parseSingleArgStep(cmd)
-		if err != nil {
-			return Step{}, inor, fmt.Errorf("failed to parse IsInstance (%s)", err)
-		}
-		return Step{
-			token: t,
-			arg:   arg,
-		}, inor, nil
+	step := Step{
+		token: t,
+		arg:   args[0],
 	}
-
-	if strings.HasPrefix(cmd, "Follow") || strings.HasPrefix(cmd, "FollowInverse") {
-		// Follow and FollowInverse both take a single argument which is the
-		// relationship iri
-		t, arg, err := parseSingleArgStep(cmd)
-		if err != nil {
-			return Step{}, inor, fmt.Errorf("failed to parse Follow (%s)", err)
-		}
-		return Step{
-			token: t,
-			arg:   arg,
-		}, inor, nil
+	if len(args) > 1 {
+		step.vals = args[1:]
 	}
-
-	return Step{}, inor, nil
+	return step, inor, nil
 }
 
 // Parse a step with no arguments.
@@ -249,15 +217,6 @@ func parseNoArgStep(cmd string) (string, error) {
 	return head, nil
 }
 
-// Parse a step with a single argument.
-func parseSingleArgStep(cmd string) (string, string, error) {
-	head, tail, err := parseMultiArgStep(cmd, 1, 1)
-	if err != nil {
-		return "", "", err
-	}
-	return head, tail[0], nil
-}
-
 // Parse a step that can take multiple argument.
 func parseMultiArgStep(cmd string, min, max int) (string, []string, error) {
 	head, tail := splitOnRune(cmd, '[')
@@ -345,16 +304,26 @@ func cleanCmd(cmd string) (string, error) {
 	return cmd, nil
 }
 
+// Namespaces that are converted to qnames, in the order they are applied.
+var defaultPrefixes = []struct {
+	prefix string
+	ns     string
+}{
+	{"bsm", "https://bsm.bloomberg.com/ontology/"},
+	{"bsi", "https://bsm.bloomberg.com/instance/"},
+	{"owl", "http://www.w3.org/2002/07/owl#"},
+	{"rdfs", "http://www.w3.org/2000/01/rdf-schema#"},
+	{"rdf", "http://www.w3.org/1999/02/22-rdf-syntax-ns#"},
+	{"ex", "http://example.org/"},
+}
+
 // Converts the iris to qnames.
 func convertIris(cmd string) string {
 	cmd = strings.ReplaceAll(cmd, "<", "")
 	cmd = strings.ReplaceAll(cmd, ">", "")
-	cmd = strings.ReplaceAll(cmd, "https://bsm.bloomberg.com/ontology/", "bsm:")
-	cmd = strings.ReplaceAll(cmd, "https://bsm.bloomberg.com/instance/", "bsi:")
-	cmd = strings.ReplaceAll(cmd, "http://www.w3.org/2002/07/owl#", "owl:")
-	cmd = strings.ReplaceAll(cmd, "http://www.w3.org/2000/01/rdf-schema#", "rdfs:")
-	cmd = strings.ReplaceAll(cmd, "http://www.w3.org/1999/02/22-rdf-syntax-ns#", "rdf:")
-	cmd = strings.ReplaceAll(cmd, "http://example.org/", "ex:")
+	for _, p := range defaultPrefixes {
+		cmd = strings.ReplaceAll(cmd, p.ns, p.prefix+":")
+	}
 	return cmd
 }
 
@@ -364,14 +333,14 @@ func removeAnd(cmd string) (string, bool) {
 		return cmd, false
 	}
 
-	iq := false
+	var q quoteScanner
 	buf := make([]rune, 0, len(cmd))
 	var start, end int
 
 	for i, c := range cmd {
+		iq := q.next(c)
 		switch c {
 		case '"':
-			iq = !iq
 		case '(':
 			buf3 := string(buf[len(buf)-3:])
 			if !iq && buf3 == "And" {
@@ -394,32 +363,75 @@ func removeAnd(cmd string) (string, bool) {
 	return cmd, false
 }
 
+// Removes the quotes around a string, unescaping the quotes and
+// backslashes in it.
 func removeOuterQuotes(cmd string) string {
-	if len(cmd) < 2 {
+	if len(cmd) < 2 || cmd[0] != '"' || cmd[len(cmd)-1] != '"' {
 		return cmd
 	}
-	if cmd[0] == '"' && cmd[len(cmd)-1] == '"' {
-		return cmd[1 : len(cmd)-1]
+	var b strings.Builder
+	inner := cmd[1 : len(cmd)-1]
+	for i := 0; i < len(inner); i++ {
+		if inner[i] == '\\' && i+1 < len(inner) && (inner[i+1] == '"' || inner[i+1] == '\\') {
+			i++
+		}
+		b.WriteByte(inner[i])
 	}
-	return cmd
+	return b.String()
+}
+
+// Reports whether every quote, bracket and parenthesis of the source is
+// closed, which a command or the steps of a fragment need to be complete.
+func Balanced(src string) bool {
+	depth := 0
+	var q quoteScanner
+	for _, c := range src {
+		if q.next(c) {
+			continue
+		}
+		switch c {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			depth--
+		}
+	}
+	return depth <= 0 && !q.in
+}
+
+// quoteScanner tracks whether a scan of Bremlin source is inside a quoted
+// string, where a backslash escapes the quote or backslash after it.
+type quoteScanner struct {
+	in, escaped bool
+}
+
+// Moves past c and reports whether it belongs to a quoted string, quotes
+// included.
+func (q *quoteScanner) next(c rune) bool {
+	switch {
+	case q.escaped:
+		q.escaped = false
+	case q.in && c == '\\':
+		q.escaped = true
+	case c == '"':
+		q.in = !q.in
+	default:
+		return q.in
+	}
+	return true
 }
 
 func removeWhiteSpace(cmd string, term *rune) string {
 	buf := make([]rune, 0)
-	iq := false
-	var prev rune
+	var q quoteScanner
 
 	for _, r := range cmd {
-		if term != nil && *term == r && prev != '\\' {
+		iq := q.next(r)
+		if term != nil && *term == r && !iq {
 			break
 		}
 
 		switch r {
-		case '"':
-			if prev != '\\' {
-				iq = !iq
-			}
-			buf = append(buf, r)
 		case ' ':
 			if iq {
 				buf = append(buf, r)
@@ -429,7 +441,6 @@ func removeWhiteSpace(cmd string, term *rune) string {
 		default:
 			buf = append(buf, r)
 		}
-		prev = r
 	}
 
 	return string(buf)
@@ -437,12 +448,12 @@ func removeWhiteSpace(cmd string, term *rune) string {
 
 // Splits the cmd by the first occurences of the term, accounting for quotes.
 func splitOnRune(cmd string, term rune) (string, string) {
-	iq := false
+	var q quoteScanner
 	for i, c := range cmd {
-		if c == '"' {
-			iq = !iq
+		if q.next(c) {
+			continue
 		}
-		if term == c && !iq {
+		if term == c {
 			return cmd[:i], cmd[i+1:]
 		}
 	}
@@ -452,16 +463,16 @@ func splitOnRune(cmd string, term rune) (string, string) {
 // Finds the next occurance of rune in the command that is outside
 // of quotes or square brackers.
 func findNextStandaloneRune(cmd string, r rune) (int, bool) {
-	iq := false
+	var q quoteScanner
 	ib := false
 	for i, c := range cmd {
-		if c == '"' {
-			iq = !iq
+		if q.next(c) {
+			continue
 		}
 		if c == '[' || c == ']' {
 			ib = !ib
 		}
-		if c == r && !iq && !ib {
+		if c == r && !ib {
 			return i, true
 		}
 	}
@@ -470,20 +481,10 @@ func findNextStandaloneRune(cmd string, r rune) (int, bool) {
 
 // Finds the next occurance of term in the command, accounting for quotes.
 func findNext(cmd string, term rune) (int, bool) {
-	iq := false
-	var prev rune
+	var q quoteScanner
 	for i, r := range cmd {
-		switch r {
-		case '"':
-			if prev != '\\' {
-				iq = !iq
-			}
-		case term:
-			if !iq {
-				return i, true
-			}
-		default:
-			prev = r
+		if !q.next(r) && r == term {
+			return i, true
 		}
 	}
 	return 0, false
@@ -646,3 +646,38 @@ func TestHasTypeNoArg(t *testing.T) {
 		t.Errorf("Expected error when parsing %s", cmd)
 	}
 }
+
+func TestStepAccessors(t *testing.T) {
+	chain, err := ParseCommand(`Start[iri].Or(HasType[Gremlin].HasValue[FurColor, "green", "blue"]).Eval`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	or := chain[1]
+	if or.Token() != "Or" || len(or.Subcmd()) != 2 {
+		t.Errorf("Expected Or with 2 alternatives got %s with %d", or.Token(), len(or.Subcmd()))
+	}
+	hv := or.Subcmd()[1]
+	if hv.Arg() != "FurColor" || len(hv.Vals()) != 2 || hv.Vals()[1] != "blue" {
+		t.Errorf("Expected FurColor green blue got %s %v", hv.Arg(), hv.Vals())
+	}
+}
+
+func TestBalanced(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected bool
+	}{
+		{`HasType[a].Or(HasType[b])`, true},
+		{`Or(HasType[b]`, false},
+		{`HasValue[f, "(x"]`, true},
+		{`HasValue[f, "x`, false},
+		{`HasValue[f, "a\"]"]`, true},
+		{`HasValue[f, "a\\"]`, true},
+		{`HasValue[f, "a\\\"]`, false},
+	}
+	for _, test := range tests {
+		if got := Balanced(test.src); got != test.expected {
+			t.Errorf("%q: expected %v got %v", test.src, test.expected, got)
+		}
+	}
+}
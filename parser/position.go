@@ -0,0 +1,217 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+)
+
+// StepPos is where a step of a chain is written in its source.
+type StepPos struct {
+	Path  []int  // index of the step, followed by the index inside each enclosing Or
+	Token string // name of the step
+	Start int    // byte offset of the name of the step
+	End   int    // byte offset just past the arguments or alternatives of the step
+}
+
+// Returns where the steps of a chain parsed from src are written, in the
+// order they are written. Steps that are not written, such as the Start of a
+// command without one, are left out.
+func StepPositions(src string, chain []Step) []StepPos {
+	comments, _ := findComments(src)
+	src = blankComments(src, comments)
+	starts := stepStarts(src)
+
+	positions := make([]StepPos, 0)
+	var walk func(chain []Step, parent []int)
+	walk = func(chain []Step, parent []int) {
+		for i, s := range chain {
+			for len(starts) > 0 && nameAt(src, starts[0]) != s.token {
+				starts = starts[1:]
+			}
+			if len(starts) == 0 {
+				return
+			}
+			path := append(slices.Clone(parent), i)
+			positions = append(positions, StepPos{
+				Path:  path,
+				Token: s.token,
+				Start: starts[0],
+				End:   stepEnd(src, starts[0]+len(s.token)),
+			})
+			starts = starts[1:]
+			walk(s.subcmd, path)
+		}
+	}
+	walk(chain, nil)
+	return positions
+}
+
+// Returns the offsets of the first step named name in src, leaving out
+// comments and arguments, or of the fragment name of the first Use[name].
+// In a rule file, line is the line of the rule or fragment to search, so
+// that steps of other declarations are not found; 0 searches src as a
+// single command.
+func FindStep(src, name string, line int) (int, int, bool) {
+	src = stripComments(src)
+	start, end := 0, len(src)
+	if line > 0 {
+		start, end = declarationBody(src, line)
+	}
+	for _, i := range stepStarts(src[start:end]) {
+		i += start
+		switch step := nameAt(src, i); step {
+		case name:
+			return i, i + len(name), true
+		case useStep:
+			j := i + len(step)
+			for j < end && isSpace(src[j]) {
+				j++
+			}
+			if j == end || src[j] != '[' {
+				continue
+			}
+			for j++; j < end && isSpace(src[j]); j++ {
+			}
+			if nameAt(src, j) == name {
+				return j, j + len(name), true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// Returns the offsets of the steps of the rule or fragment declared on the
+// line of a rule file whose comments have been blanked: from past its { or
+// = to the next declaration.
+func declarationBody(src string, line int) (int, int) {
+	start := 0
+	for n := 1; n < line; n++ {
+		i := strings.IndexByte(src[start:], '\n')
+		if i < 0 {
+			return len(src), len(src)
+		}
+		start += i + 1
+	}
+	if i := strings.IndexAny(src[start:], "{="); i >= 0 {
+		start += i + 1
+	}
+
+	end := start
+	for end < len(src) {
+		next := strings.IndexByte(src[end:], '\n')
+		if next < 0 {
+			return start, len(src)
+		}
+		end += next + 1
+		l := strings.TrimLeft(src[end:], " \t")
+		for _, decl := range []string{"rule ", "def ", "@", "PREFIX "} {
+			if strings.HasPrefix(l, decl) {
+				return start, end
+			}
+		}
+	}
+	return start, end
+}
+
+// Returns the offsets of the names of the steps of a command whose comments
+// have been blanked, in the order they are written. A step name starts a
+// command or follows a dot or parenthesis outside of arguments.
+func stepStarts(src string) []int {
+	starts := make([]int, 0)
+	iq, ia, depth := false, false, 0
+	var prev byte // last byte that is not whitespace
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case iq:
+			if c == '\\' && i+1 < len(src) {
+				i++
+			} else if c == '"' {
+				iq = false
+			}
+		case ia:
+			if c == '>' {
+				ia = false
+			}
+		case c == '"':
+			iq = true
+		case c == '<':
+			ia = true
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case depth == 0 && isLetter(c) && (prev == 0 || prev == '.' || prev == '('):
+			starts = append(starts, i)
+			i += len(nameAt(src, i)) - 1
+			c = src[i]
+		}
+		if !isSpace(c) {
+			prev = c
+		}
+	}
+	return starts
+}
+
+// Returns the step name at offset i.
+func nameAt(src string, i int) string {
+	j := i
+	for j < len(src) && (isLetter(src[j]) || src[j] >= '0' && src[j] <= '9' || src[j] == '_') {
+		j++
+	}
+	return src[i:j]
+}
+
+// Returns the offset past the [arguments] or (alternatives) that follow the
+// name of a step ending at i.
+func stepEnd(src string, i int) int {
+	j := i
+	for j < len(src) && isSpace(src[j]) {
+		j++
+	}
+	if j == len(src) || (src[j] != '[' && src[j] != '(') {
+		return i
+	}
+	open, close := src[j], byte(']')
+	if open == '(' {
+		close = ')'
+	}
+	depth := 0
+	iq := false
+	for ; j < len(src); j++ {
+		switch c := src[j]; {
+		case iq && c == '\\':
+			j++
+		case c == '"':
+			iq = !iq
+		case iq:
+		case c == open:
+			depth++
+		case c == close:
+			depth--
+			if depth == 0 {
+				return j + 1
+			}
+		}
+	}
+	return len(src)
+}
+
+// Returns the positions shifted by offset, with the first index of their
+// paths shifted by index.
+func shiftPositions(positions []StepPos, offset, index int) []StepPos {
+	out := make([]StepPos, len(positions))
+	for i, p := range positions {
+		p.Path = slices.Clone(p.Path)
+		p.Path[0] += index
+		p.Start += offset
+		p.End += offset
+		out[i] = p
+	}
+	return out
+}
+
+// Reports whether the source has a line break between the offsets.
+func hasLineBreak(src string, from, to int) bool {
+	return strings.Contains(src[from:to], "\n")
+}
@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStepPositions(t *testing.T) {
+	src := "Start[iri] // go\n.Or(HasType[ex:A].HasType[\"a.b\"])\n.And(IsActive[])\n.Eval"
+	chain, err := ParseCommand(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	positions := StepPositions(src, chain)
+
+	expected := []struct {
+		path []int
+		text string
+	}{
+		{[]int{0}, "Start[iri]"},
+		{[]int{1}, "Or(HasType[ex:A].HasType[\"a.b\"])"},
+		{[]int{1, 0}, "HasType[ex:A]"},
+		{[]int{1, 1}, "HasType[\"a.b\"]"},
+		{[]int{2}, "IsActive[]"},
+		{[]int{3}, "Eval"},
+	}
+	if len(positions) != len(expected) {
+		t.Fatalf("Expected %d positions got %+v", len(expected), positions)
+	}
+	for i, e := range expected {
+		p := positions[i]
+		if !reflect.DeepEqual(p.Path, e.path) || src[p.Start:p.End] != e.text {
+			t.Errorf("Expected %v %q got %v %q", e.path, e.text, p.Path, src[p.Start:p.End])
+		}
+	}
+}
+
+func TestRuleSetPositions(t *testing.T) {
+	src := "def Monster = Or(HasType[a]\n\t.HasType[b])\nrule R {\n Start[iri].Use[Monster].Eval\n}"
+	rs, err := ParseRuleSet(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, _ := rs.Rule("R")
+	if len(r.Positions) != 3 || src[r.Positions[1].Start:r.Positions[1].End] != "Use[Monster]" {
+		t.Errorf("Unexpected rule positions %+v", r.Positions)
+	}
+	f, _ := rs.Fragment("Monster")
+	if len(f.Positions) != 3 || !reflect.DeepEqual(f.Positions[2].Path, []int{0, 1}) || src[f.Positions[2].Start:f.Positions[2].End] != "HasType[b]" {
+		t.Errorf("Unexpected fragment positions %+v", f.Positions)
+	}
+}
+
+func TestFindStep(t *testing.T) {
+	rules := "def M = HasType[ex:A] # HasValue\n" +
+		"rule A { Start[iri].HasValue[ex:v, \"HasValue\"].Use[M].Eval }\n" +
+		"rule B { Start[iri].HasValue[ex:v, \"x\"].Eval }\n"
+	tests := []struct {
+		name  string
+		line  int
+		start int
+		ok    bool
+	}{
+		{"HasType", 1, 8, true},
+		{"HasValue", 1, 0, false},
+		{"HasValue", 2, 53, true},
+		{"HasValue", 3, 114, true},
+		{"M", 2, 84, true},
+		{"Use", 3, 0, false},
+	}
+	for _, test := range tests {
+		start, end, ok := FindStep(rules, test.name, test.line)
+		if ok != test.ok || ok && (start != test.start || end != start+len(test.name)) {
+			t.Errorf("%s on line %d: expected %d %v got %d %d %v", test.name, test.line, test.start, test.ok, start, end, ok)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+)
+
+// PrefixMap maps prefixes to namespace IRIs. The empty prefix, if present,
+// is used for names without a prefix.
+type PrefixMap map[string]string
+
+// Returns the prefixes that ParseCommand converts IRIs to.
+func DefaultPrefixes() PrefixMap {
+	pm := make(PrefixMap)
+	for _, p := range defaultPrefixes {
+		pm[p.prefix] = p.ns
+	}
+	return pm
+}
+
+// Splits a name into its prefix and local part. Full IRIs and names
+// without a prefix return ok false.
+func splitQname(name string) (string, string, bool) {
+	if strings.Contains(name, "://") {
+		return "", "", false
+	}
+	i := strings.IndexByte(name, ':')
+	if i < 0 {
+		return "", "", false
+	}
+	return name[:i], name[i+1:], true
+}
+
+// Expands a qname, or a name without a prefix, to a full IRI.
+func (pm PrefixMap) Expand(name string) (string, bool) {
+	if strings.Contains(name, "://") {
+		return name, true
+	}
+	prefix, local, ok := splitQname(name)
+	if !ok {
+		prefix, local = "", name
+	}
+	ns, ok := pm[prefix]
+	if !ok {
+		return "", false
+	}
+	return ns + local, true
+}
+
+// Compacts a full IRI using the longest matching namespace.
+func (pm PrefixMap) Compact(iri string) string {
+	best := ""
+	for prefix, ns := range pm {
+		if prefix == "" || !strings.HasPrefix(iri, ns) {
+			continue
+		}
+		if best == "" || len(ns) > len(pm[best]) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return iri
+	}
+	return best + ":" + strings.TrimPrefix(iri, pm[best])
+}
+
+// Returns the prefixes in sorted order.
+func (pm PrefixMap) prefixes() []string {
+	keys := make([]string, 0, len(pm))
+	for k := range pm {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Rewrites the identifiers of the chain that use a prefix of pm to the form
+// that ParseCommand and ReadTriples produce, so that commands can be written
+// with prefixes other than the default ones.
+func ExpandPrefixes(chain []Step, pm PrefixMap) []Step {
+	expand := func(name string) string {
+		prefix, _, _ := splitQname(name)
+		if _, known := pm[prefix]; !known {
+			return name
+		}
+		iri, ok := pm.Expand(name)
+		if !ok {
+			return name
+		}
+		return compactIri(iri)
+	}
+
+	out := make([]Step, len(chain))
+	for i, s := range chain {
+		out[i] = s
+		d, ok := LookupStep(s.token)
+		if !ok || d.Token == Start {
+			continue
+		}
+		args := stepArgs(s)
+		for j, a := range args {
+			if d.ArgKind(j) == IdentArg {
+				args[j] = expand(a)
+			}
+		}
+		if s.arg != "" {
+			out[i].arg, args = args[0], args[1:]
+		}
+		if len(args) > 0 {
+			out[i].vals = args
+		}
+		if s.subcmd != nil {
+			out[i].subcmd = ExpandPrefixes(s.subcmd, pm)
+		}
+	}
+	return out
+}
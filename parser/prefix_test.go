@@ -0,0 +1,26 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpandPrefixes(t *testing.T) {
+	chain, err := ParseCommand(`Start[iri].Or(HasType[zoo:Gremlin].HasType[other:Thing]).HasValue[zoo:FurColor, "zoo:green"].Follow[skos:broader].Eval`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pm := PrefixMap{
+		"zoo":  "http://example.org/",
+		"skos": skosNs,
+	}
+	expected, err := ParseCommand(`Start[iri].Or(HasType[ex:Gremlin].HasType[other:Thing]).HasValue[ex:FurColor, "zoo:green"].Follow["http://www.w3.org/2004/02/skos/core#broader"].Eval`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := ExpandPrefixes(chain, pm)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %s got %s", chainString(expected), chainString(got))
+	}
+}
@@ -0,0 +1,513 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	rdfType  = "rdf:type"
+	rdfFirst = "rdf:first"
+	rdfRest  = "rdf:rest"
+	rdfNil   = "rdf:nil"
+)
+
+// Triple is a single RDF statement. IRIs are held in the same compact form
+// that ParseCommand produces for step arguments (e.g. ex:Animals), blank
+// nodes as _:label and literals without their quotes.
+type Triple struct {
+	S       string
+	P       string
+	O       string
+	Literal bool
+}
+
+// Reads all triples from a Turtle or N-Triples document.
+func ReadTriples(r io.Reader) ([]Triple, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTurtle(string(b))
+}
+
+// Parses a Turtle document. N-Triples is a subset of Turtle and is handled
+// as well. Only the parts of Turtle needed for schemas and data files are
+// supported: prefixes, predicate and object lists, blank node property
+// lists, collections and literals with optional language or datatype.
+func ParseTurtle(src string) ([]Triple, error) {
+	p := turtleParser{
+		src:      src,
+		line:     1,
+		prefixes: make(map[string]string),
+	}
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return p.triples, nil
+		}
+		if err := p.statement(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+type turtleParser struct {
+	src      string
+	pos      int
+	line     int
+	prefixes map[string]string
+	base     string
+	bnodes   int
+	triples  []Triple
+}
+
+type term struct {
+	val     string
+	literal bool
+}
+
+func (p *turtleParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *turtleParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+func (p *turtleParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+// Skips whitespace and comments.
+func (p *turtleParser) skipSpace() {
+	for !p.eof() {
+		switch c := p.peek(); c {
+		case '\n':
+			p.line++
+			p.pos++
+		case ' ', '\t', '\r':
+			p.pos++
+		case '#':
+			for !p.eof() && p.peek() != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (p *turtleParser) expect(c byte) error {
+	p.skipSpace()
+	if p.peek() != c {
+		return p.errorf("expected '%c' got %q", c, p.rest())
+	}
+	p.pos++
+	return nil
+}
+
+// Returns a short excerpt of the remaining input for error messages.
+func (p *turtleParser) rest() string {
+	s := p.src[p.pos:]
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	if len(s) > 20 {
+		s = s[:20]
+	}
+	return s
+}
+
+func (p *turtleParser) hasKeyword(kw string) bool {
+	if len(p.src)-p.pos < len(kw) {
+		return false
+	}
+	if !strings.EqualFold(p.src[p.pos:p.pos+len(kw)], kw) {
+		return false
+	}
+	end := p.pos + len(kw)
+	return end == len(p.src) || !(isNameChar(rune(p.src[end])) || p.src[end] == ':')
+}
+
+func (p *turtleParser) statement() error {
+	switch {
+	case p.peek() == '@' || p.hasKeyword("PREFIX") || p.hasKeyword("BASE"):
+		return p.directive()
+	}
+
+	subj, err := p.subject()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	// A blank node property list may stand on its own.
+	if !(p.peek() == '.' && strings.HasPrefix(subj.val, "_:")) {
+		if err := p.predicateObjectList(subj.val); err != nil {
+			return err
+		}
+	}
+	return p.expect('.')
+}
+
+func (p *turtleParser) directive() error {
+	sparql := p.peek() != '@'
+	if !sparql {
+		p.pos++
+	}
+	switch {
+	case p.hasKeyword("prefix"):
+		p.pos += len("prefix")
+		p.skipSpace()
+		name, ok := p.scanName()
+		if !ok || p.peek() != ':' {
+			return p.errorf("expected prefix name got %q", p.rest())
+		}
+		p.pos++
+		p.skipSpace()
+		iri, err := p.iriRef()
+		if err != nil {
+			return err
+		}
+		p.prefixes[name] = iri
+	case p.hasKeyword("base"):
+		p.pos += len("base")
+		p.skipSpace()
+		iri, err := p.iriRef()
+		if err != nil {
+			return err
+		}
+		p.base = iri
+	default:
+		return p.errorf("unknown directive %q", p.rest())
+	}
+	if sparql {
+		return nil
+	}
+	return p.expect('.')
+}
+
+func (p *turtleParser) subject() (term, error) {
+	p.skipSpace()
+	switch p.peek() {
+	case '[':
+		return p.blankNodePropertyList()
+	case '(':
+		return p.collection()
+	}
+	t, err := p.resource()
+	return t, err
+}
+
+func (p *turtleParser) predicateObjectList(subj string) error {
+	for {
+		p.skipSpace()
+		var pred string
+		if p.peek() == 'a' && p.hasKeyword("a") {
+			p.pos++
+			pred = rdfType
+		} else {
+			t, err := p.resource()
+			if err != nil {
+				return err
+			}
+			pred = t.val
+		}
+
+		for {
+			obj, err := p.object()
+			if err != nil {
+				return err
+			}
+			p.emit(subj, pred, obj)
+			p.skipSpace()
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+
+		p.skipSpace()
+		if p.peek() != ';' {
+			return nil
+		}
+		// Repeated or trailing semicolons are allowed.
+		for p.peek() == ';' {
+			p.pos++
+			p.skipSpace()
+		}
+		if c := p.peek(); c == '.' || c == ']' || c == 0 {
+			return nil
+		}
+	}
+}
+
+func (p *turtleParser) object() (term, error) {
+	p.skipSpace()
+	switch c := p.peek(); {
+	case c == '[':
+		return p.blankNodePropertyList()
+	case c == '(':
+		return p.collection()
+	case c == '"' || c == '\'':
+		return p.literal()
+	case c == '+' || c == '-' || (c >= '0' && c <= '9'):
+		return p.number()
+	case p.hasKeyword("true") || p.hasKeyword("false"):
+		v, _ := p.scanName()
+		return term{val: v, literal: true}, nil
+	}
+	return p.resource()
+}
+
+func (p *turtleParser) emit(s, pred string, o term) {
+	p.triples = append(p.triples, Triple{S: s, P: pred, O: o.val, Literal: o.literal})
+}
+
+func (p *turtleParser) newBlankNode() string {
+	p.bnodes++
+	return fmt.Sprintf("_:b%d", p.bnodes)
+}
+
+func (p *turtleParser) blankNodePropertyList() (term, error) {
+	p.pos++ // [
+	node := p.newBlankNode()
+	p.skipSpace()
+	if p.peek() != ']' {
+		if err := p.predicateObjectList(node); err != nil {
+			return term{}, err
+		}
+	}
+	if err := p.expect(']'); err != nil {
+		return term{}, err
+	}
+	return term{val: node}, nil
+}
+
+// Parses a collection into an rdf:first/rdf:rest list.
+func (p *turtleParser) collection() (term, error) {
+	p.pos++ // (
+	head := term{val: rdfNil}
+	prev := ""
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return term{}, p.errorf("unterminated collection")
+		}
+		if p.peek() == ')' {
+			p.pos++
+			break
+		}
+		item, err := p.object()
+		if err != nil {
+			return term{}, err
+		}
+		node := p.newBlankNode()
+		if prev == "" {
+			head = term{val: node}
+		} else {
+			p.emit(prev, rdfRest, term{val: node})
+		}
+		p.emit(node, rdfFirst, item)
+		prev = node
+	}
+	if prev != "" {
+		p.emit(prev, rdfRest, term{val: rdfNil})
+	}
+	return head, nil
+}
+
+// Parses an IRI, prefixed name or labelled blank node.
+func (p *turtleParser) resource() (term, error) {
+	p.skipSpace()
+	if p.peek() == '<' {
+		iri, err := p.iriRef()
+		if err != nil {
+			return term{}, err
+		}
+		return term{val: compactIri(iri)}, nil
+	}
+
+	start := p.pos
+	prefix, _ := p.scanName()
+	if p.peek() != ':' {
+		p.pos = start
+		return term{}, p.errorf("expected IRI got %q", p.rest())
+	}
+	p.pos++
+	local := p.scanLocalName()
+
+	if prefix == "_" {
+		return term{val: "_:" + local}, nil
+	}
+	ns, ok := p.prefixes[prefix]
+	if !ok {
+		return term{}, p.errorf("undefined prefix %s:", prefix)
+	}
+	return term{val: compactIri(ns + local)}, nil
+}
+
+func (p *turtleParser) iriRef() (string, error) {
+	if p.peek() != '<' {
+		return "", p.errorf("expected <iri> got %q", p.rest())
+	}
+	end := strings.IndexByte(p.src[p.pos:], '>')
+	if end < 0 {
+		return "", p.errorf("unterminated IRI")
+	}
+	iri := p.src[p.pos+1 : p.pos+end]
+	p.pos += end + 1
+	if p.base != "" && !strings.Contains(iri, ":") {
+		iri = p.base + iri
+	}
+	return iri, nil
+}
+
+func (p *turtleParser) scanName() (string, bool) {
+	start := p.pos
+	for !p.eof() && isNameChar(rune(p.peek())) {
+		p.pos++
+	}
+	return p.src[start:p.pos], p.pos > start
+}
+
+// Scans the local part of a prefixed name. A trailing period terminates the
+// statement and is not part of the name.
+func (p *turtleParser) scanLocalName() string {
+	start := p.pos
+	for !p.eof() {
+		c := rune(p.peek())
+		if !isNameChar(c) && c != '.' && c != ':' && c != '%' {
+			break
+		}
+		p.pos++
+	}
+	for p.pos > start && p.src[p.pos-1] == '.' {
+		p.pos--
+	}
+	return p.src[start:p.pos]
+}
+
+func isNameChar(c rune) bool {
+	return c == '_' || c == '-' || unicode.IsLetter(c) || unicode.IsDigit(c) || c > unicode.MaxASCII
+}
+
+func (p *turtleParser) literal() (term, error) {
+	q := p.peek()
+	long := strings.HasPrefix(p.src[p.pos:], strings.Repeat(string(q), 3))
+	if long {
+		p.pos += 3
+	} else {
+		p.pos++
+	}
+
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return term{}, p.errorf("unterminated literal")
+		}
+		c := p.peek()
+		if c == '\\' && p.pos+1 < len(p.src) {
+			r, n := utf8.DecodeRuneInString(p.src[p.pos+1:])
+			b.WriteRune(unescape(r))
+			p.pos += 1 + n
+			continue
+		}
+		if long && strings.HasPrefix(p.src[p.pos:], strings.Repeat(string(q), 3)) {
+			p.pos += 3
+			break
+		}
+		if !long && c == q {
+			p.pos++
+			break
+		}
+		if c == '\n' {
+			if !long {
+				return term{}, p.errorf("newline in literal")
+			}
+			p.line++
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+
+	// Language tags and datatypes are accepted but not retained.
+	switch {
+	case p.peek() == '@':
+		p.pos++
+		for !p.eof() && (isNameChar(rune(p.peek()))) {
+			p.pos++
+		}
+	case strings.HasPrefix(p.src[p.pos:], "^^"):
+		p.pos += 2
+		if _, err := p.resource(); err != nil {
+			return term{}, err
+		}
+	}
+	return term{val: b.String(), literal: true}, nil
+}
+
+func unescape(c rune) rune {
+	switch c {
+	case 'n':
+		return '\n'
+	case 't':
+		return '\t'
+	case 'r':
+		return '\r'
+	default:
+		return c
+	}
+}
+
+func (p *turtleParser) number() (term, error) {
+	start := p.pos
+	if c := p.peek(); c == '+' || c == '-' {
+		p.pos++
+	}
+	for !p.eof() {
+		c := p.peek()
+		if c == '.' && (p.pos+1 >= len(p.src) || p.src[p.pos+1] < '0' || p.src[p.pos+1] > '9') {
+			break
+		}
+		if !(c >= '0' && c <= '9') && c != '.' && c != 'e' && c != 'E' {
+			break
+		}
+		p.pos++
+	}
+	if p.pos == start {
+		return term{}, p.errorf("expected number got %q", p.rest())
+	}
+	return term{val: p.src[start:p.pos], literal: true}, nil
+}
+
+// Converts a full IRI to the compact form used for step arguments.
+func compactIri(iri string) string {
+	return convertIris("<" + iri + ">")
+}
+
+// Returns the items of the rdf list starting at head.
+func listItems(triples map[string][]Triple, head string) []string {
+	items := make([]string, 0)
+	seen := make(map[string]bool)
+	for head != rdfNil && head != "" && !seen[head] {
+		seen[head] = true
+		next := ""
+		for _, t := range triples[head] {
+			switch t.P {
+			case rdfFirst:
+				items = append(items, t.O)
+			case rdfRest:
+				next = t.O
+			}
+		}
+		head = next
+	}
+	return items
+}
@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseNTriples(t *testing.T) {
+	src := `<http://example.org/Gizmo> <http://www.w3.org/1999/02/22-rdf-syntax-ns#type> <http://example.org/Gremlin> .
+<http://example.org/Gizmo> <http://example.org/FurColor> "green" .
+`
+	triples, err := ParseTurtle(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Triple{
+		{S: "ex:Gizmo", P: "rdf:type", O: "ex:Gremlin"},
+		{S: "ex:Gizmo", P: "ex:FurColor", O: "green", Literal: true},
+	}
+	if len(triples) != len(expected) {
+		t.Fatalf("Expected %d triples, got %d", len(expected), len(triples))
+	}
+	for i, e := range expected {
+		if triples[i] != e {
+			t.Errorf("expected %+v got %+v in triple %d", e, triples[i], i)
+		}
+	}
+}
+
+func TestParseTurtleLists(t *testing.T) {
+	src := `@prefix ex: <http://example.org/> .
+ex:Gizmo a ex:Gremlin, ex:Creature ;
+	ex:Name "Gizmo"@en ;
+	ex:Age 3 ;
+	ex:Likes ( "water" "light" ) .
+`
+	triples, err := ParseTurtle(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bySubject := make(map[string][]Triple)
+	types := 0
+	var head string
+	for _, tr := range triples {
+		bySubject[tr.S] = append(bySubject[tr.S], tr)
+		if tr.S == "ex:Gizmo" && tr.P == "rdf:type" {
+			types++
+		}
+		if tr.P == "ex:Likes" {
+			head = tr.O
+		}
+	}
+
+	if types != 2 {
+		t.Errorf("Expected 2 types, got %d", types)
+	}
+
+	items := listItems(bySubject, head)
+	if strings.Join(items, ",") != "water,light" {
+		t.Errorf("Expected [water light] got %v", items)
+	}
+}
+
+func TestParseTurtleBlankNode(t *testing.T) {
+	src := `PREFIX ex: <http://example.org/>
+ex:Gizmo ex:LivesIn [ ex:Name "Kingston Falls" ] .`
+	triples, err := ParseTurtle(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(triples) != 2 {
+		t.Fatalf("Expected 2 triples, got %d", len(triples))
+	}
+	// The nested triple is emitted before the triple that refers to it.
+	if triples[0].S != triples[1].O {
+		t.Errorf("Expected blank node %s to be linked, got %s", triples[1].O, triples[0].S)
+	}
+}
+
+func TestParseTurtleEscapedQuote(t *testing.T) {
+	src := `<http://example.org/Gizmo> <http://example.org/Name> "say \"hi\"" .`
+	triples, err := ParseTurtle(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if triples[0].O != `say "hi"` {
+		t.Errorf("Expected say \"hi\" got %s", triples[0].O)
+	}
+}
+
+func TestParseTurtleUndefinedPrefix(t *testing.T) {
+	src := `foo:Gizmo a foo:Gremlin .`
+	_, err := ParseTurtle(src)
+	if err == nil {
+		t.Errorf("Expected error when parsing %s", src)
+	}
+}
+
+func TestParseTurtleMissingPeriod(t *testing.T) {
+	src := `<http://example.org/Gizmo> <http://example.org/Name> "Gizmo"`
+	_, err := ParseTurtle(src)
+	if err == nil {
+		t.Errorf("Expected error when parsing %s", src)
+	}
+}
@@ -0,0 +1,274 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sync"
+)
+
+// ArgKind is the kind of an argument of a step.
+type ArgKind int
+
+const (
+	// An identifier, such as a class, property or instance, that is
+	// internalized.
+	IdentArg ArgKind = iota + 1
+	// A literal value that is kept as a string.
+	LiteralArg
+)
+
+// StepDef declares the shape of a step. The parser, validator, internalizer
+// and printer all work from these declarations.
+type StepDef struct {
+	Token Token
+	// Kinds of the arguments the step requires, in order.
+	Args []ArgKind
+	// Kind of the further arguments the step accepts, at least one of which
+	// is required, or 0 if it accepts no further arguments.
+	Rest ArgKind
+	// Whether the step takes a subcommand of steps, as in Or(...).
+	Subcmd bool
+
+	// Set for steps added with RegisterStep.
+	name        string
+	internalize func([]string) ([]string, error)
+	eval        StepFunc
+}
+
+// Guards stepDefs and customTokens against RegisterStep.
+var registryMu sync.RWMutex
+
+// The steps of the language, indexed by token. Start and Eval delimit every
+// command and are written as Start[iri] and Eval.
+var stepDefs = []StepDef{
+	NoOp:          {Token: NoOp},
+	Start:         {Token: Start},
+	Eval:          {Token: Eval},
+	HasType:       {Token: HasType, Args: []ArgKind{IdentArg}},
+	HasCategory:   {Token: HasCategory, Args: []ArgKind{IdentArg}},
+	HasValue:      {Token: HasValue, Args: []ArgKind{IdentArg}, Rest: LiteralArg},
+	InScheme:      {Token: InScheme, Args: []ArgKind{IdentArg}},
+	HasBroader:    {Token: HasBroader, Args: []ArgKind{IdentArg, IdentArg}},
+	IsInstance:    {Token: IsInstance, Args: []ArgKind{IdentArg}},
+	Follow:        {Token: Follow, Args: []ArgKind{IdentArg}},
+	FollowInverse: {Token: FollowInverse, Args: []ArgKind{IdentArg}},
+	IsActive:      {Token: IsActive},
+	IsInactive:    {Token: IsInactive},
+	Or:            {Token: Or, Subcmd: true},
+	Limit:         {Token: Limit, Args: []ArgKind{LiteralArg}},
+}
+
+// Tokens of the steps added with RegisterStep, by name.
+var customTokens = make(map[string]Token)
+
+// Returns the declaration of the step with the given token.
+func LookupToken(t Token) (StepDef, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	if t <= 0 || int(t) >= len(stepDefs) || stepDefs[t].Token == 0 {
+		return StepDef{}, false
+	}
+	return stepDefs[t], true
+}
+
+// Returns the declaration of the step with the given name.
+func LookupStep(name string) (StepDef, bool) {
+	return LookupToken(Atot(name))
+}
+
+// Returns the names of the steps that can be written in a command, in
+// sorted order.
+func StepNames() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	names := make([]string, 0, len(stepDefs))
+	for _, d := range stepDefs {
+		if d.Token != 0 && d.Token != NoOp {
+			names = append(names, d.Name())
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (d StepDef) Name() string {
+	if d.name != "" {
+		return d.name
+	}
+	return Ttoa(d.Token)
+}
+
+// Returns the minimum and maximum number of arguments of the step. The
+// maximum is -1 when the number of arguments is unbounded.
+func (d StepDef) Arity() (int, int) {
+	if d.Rest != 0 {
+		return len(d.Args) + 1, -1
+	}
+	return len(d.Args), len(d.Args)
+}
+
+// Returns the kind of the i-th argument of the step.
+func (d StepDef) ArgKind(i int) ArgKind {
+	if i < len(d.Args) {
+		return d.Args[i]
+	}
+	return d.Rest
+}
+
+// Returns the arguments of a step in order.
+func stepArgs(s Step) []string {
+	args := make([]string, 0, 1+len(s.vals))
+	if s.arg != "" {
+		args = append(args, s.arg)
+	}
+	return append(args, s.vals...)
+}
+
+// Returns the arguments of a step that are to be internalized, after the
+// internalization hook of a custom step has rewritten them.
+func resolveArgs(d StepDef, s Step) ([]string, error) {
+	args := stepArgs(s)
+	if d.internalize == nil || d.Token == Start {
+		return args, nil
+	}
+	args, err := d.internalize(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", d.Name(), err)
+	}
+	min, max := d.Arity()
+	if len(args) < min || (max != -1 && len(args) > max) {
+		return nil, fmt.Errorf("%s: internalization hook returned %d arguments", d.Name(), len(args))
+	}
+	return args, nil
+}
+
+// Checks that a step has the shape its declaration requires.
+func checkStep(s Step) (StepDef, error) {
+	d, ok := LookupStep(s.token)
+	if !ok {
+		return d, fmt.Errorf("unknown step %q", s.token)
+	}
+	if d.Token == Start {
+		if s.arg != "iri" || len(s.vals) > 0 {
+			return d, fmt.Errorf("expected Start[iri]")
+		}
+		return d, nil
+	}
+
+	n := len(stepArgs(s))
+	min, max := d.Arity()
+	if n < min {
+		return d, fmt.Errorf("expected at least %d arguments to %s got %d", min, s.token, n)
+	}
+	if max != -1 && n > max {
+		return d, fmt.Errorf("expected at most %d arguments to %s got %d", max, s.token, n)
+	}
+	if !d.Subcmd && len(s.subcmd) > 0 {
+		return d, fmt.Errorf("%s does not take a subcommand", s.token)
+	}
+	if d.Token == Limit {
+		if _, err := limitCount(s.arg); err != nil {
+			return d, err
+		}
+	}
+	return d, nil
+}
+
+// Reports whether the token takes an internalized argument.
+func hasIidArg(t Token) bool {
+	d, ok := LookupToken(t)
+	return ok && len(d.Args) > 0 && d.Args[0] == IdentArg
+}
+
+// StepArgs are the internalized arguments of a step: the Iids of its
+// identifiers and its literals, each in the order they were written.
+type StepArgs struct {
+	Iids     []Iid
+	Literals []string
+}
+
+func istepArgs(s istep) StepArgs {
+	args := StepArgs{
+		Literals: s.Svals,
+	}
+	if hasIidArg(s.Token) {
+		args.Iids = append(args.Iids, s.Arg)
+	}
+	args.Iids = append(args.Iids, s.Ivals...)
+	return args
+}
+
+// StepFunc evaluates a custom step on a set of nodes and returns the nodes
+// it leads to. A step that filters returns a subset of nodes.
+type StepFunc func(g Graph, nodes []Iid, args StepArgs) ([]Iid, error)
+
+// CustomStep declares a step added to the language by RegisterStep.
+type CustomStep struct {
+	// Name of the step as written in commands, e.g. HasIdentifier.
+	Name string
+	// Kinds of the arguments the step requires, identifiers first.
+	Args []ArgKind
+	// Kind of the further arguments the step accepts, at least one of which
+	// is required, or 0 if it accepts no further arguments.
+	Rest ArgKind
+	// Optional hook that rewrites the arguments before they are
+	// internalized, e.g. to normalize identifiers or to reject malformed
+	// ones. It must return as many arguments as the step accepts.
+	Internalize func(args []string) ([]string, error)
+	// Evaluates the step.
+	Eval StepFunc
+}
+
+var stepName = regexp.MustCompile(`^[A-Z][A-Za-z0-9]*$`)
+
+// Adds a step to the language, so that ParseCommand, InternalizeSteps and
+// Evaluate accept it, and returns its token. Steps are usually registered
+// from an init function. Tokens are assigned in the order steps are
+// registered, so a binary encoded plan using custom steps can only be
+// decoded by a process that registers the same steps in the same order.
+func RegisterStep(c CustomStep) (Token, error) {
+	if !stepName.MatchString(c.Name) || c.Name == "And" || c.Name == useStep {
+		return 0, fmt.Errorf("invalid step name %q", c.Name)
+	}
+	if c.Eval == nil {
+		return 0, fmt.Errorf("step %s has no evaluator", c.Name)
+	}
+	kinds := append(slices.Clone(c.Args), c.Rest)
+	for i, k := range kinds {
+		if k == 0 && i == len(kinds)-1 {
+			break
+		}
+		if k != IdentArg && k != LiteralArg {
+			return 0, fmt.Errorf("step %s has an argument of unknown kind %d", c.Name, k)
+		}
+		if i > 0 && kinds[i-1] == LiteralArg && k == IdentArg {
+			return 0, fmt.Errorf("step %s has an identifier after a literal", c.Name)
+		}
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := tokensByName[c.Name]; ok {
+		return 0, fmt.Errorf("step %s is already defined", c.Name)
+	}
+	if _, ok := customTokens[c.Name]; ok {
+		return 0, fmt.Errorf("step %s is already defined", c.Name)
+	}
+	// The binary encoding stores tokens in a byte.
+	if len(stepDefs) > 255 {
+		return 0, fmt.Errorf("too many steps")
+	}
+
+	t := Token(len(stepDefs))
+	stepDefs = append(stepDefs, StepDef{
+		Token:       t,
+		Args:        slices.Clone(c.Args),
+		Rest:        c.Rest,
+		name:        c.Name,
+		internalize: c.Internalize,
+		eval:        c.Eval,
+	})
+	customTokens[c.Name] = t
+	return t, nil
+}
@@ -0,0 +1,177 @@
+package parser
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestStepDefsCoverTokens(t *testing.T) {
+	for tok := Token(1); int(tok) < len(tokenNames); tok++ {
+		d, ok := LookupToken(tok)
+		if !ok {
+			t.Errorf("No declaration for %s", tok)
+			continue
+		}
+		if d.Name() != tok.String() {
+			t.Errorf("Expected %s got %s", tok, d.Name())
+		}
+		if d.Subcmd && len(d.Args) > 0 {
+			t.Errorf("%s takes both arguments and a subcommand", tok)
+		}
+		// Externalizing relies on identifiers preceding literals.
+		for i := 1; i < len(d.Args); i++ {
+			if d.Args[i-1] == LiteralArg && d.Args[i] == IdentArg {
+				t.Errorf("%s has an identifier after a literal", tok)
+			}
+		}
+	}
+}
+
+func TestTokenString(t *testing.T) {
+	if HasBroader.String() != "HasBroader" {
+		t.Errorf("Expected HasBroader got %s", HasBroader)
+	}
+	if Token(99).String() != "Token(99)" {
+		t.Errorf("Expected Token(99) got %s", Token(99))
+	}
+}
+
+func TestParseUnknownStep(t *testing.T) {
+	for _, cmd := range []string{
+		`Start[iri].HasKind[Gremlin].Eval`,
+		`Start[iri].HasTypes[Gremlin].Eval`,
+		`Start[iri].Or[].Eval`,
+	} {
+		if _, err := ParseCommand(cmd); err == nil {
+			t.Errorf("Expected error when parsing %s", cmd)
+		}
+	}
+}
+
+func TestCheckStep(t *testing.T) {
+	good := []Step{
+		{token: "Start", arg: "iri"},
+		{token: "HasValue", arg: "field", vals: []string{"a", "b"}},
+		{token: "IsActive"},
+		{token: "Or", subcmd: []Step{{token: "IsActive"}}},
+	}
+	for _, s := range good {
+		if _, err := checkStep(s); err != nil {
+			t.Errorf("%+v: %v", s, err)
+		}
+	}
+
+	bad := []Step{
+		{token: "Start"},
+		{token: "HasValue", arg: "field"},
+		{token: "HasType", arg: "a", vals: []string{"b"}},
+		{token: "IsActive", arg: "a"},
+		{token: "Follow", arg: "a", subcmd: []Step{{token: "IsActive"}}},
+		{token: "Bogus"},
+	}
+	for _, s := range bad {
+		if _, err := checkStep(s); err == nil {
+			t.Errorf("Expected error for %+v", s)
+		}
+	}
+}
+
+// Registers HasName[property, name, ...], which keeps the nodes with one of
+// the names, ignoring case. Steps can only be registered once per process.
+var registerHasName = sync.OnceValues(func() (Token, error) {
+	return RegisterStep(CustomStep{
+		Name: "HasName",
+		Args: []ArgKind{IdentArg},
+		Rest: LiteralArg,
+		Internalize: func(args []string) ([]string, error) {
+			out := []string{args[0]}
+			for _, a := range args[1:] {
+				out = append(out, strings.ToLower(a))
+			}
+			return out, nil
+		},
+		Eval: func(g Graph, nodes []Iid, args StepArgs) ([]Iid, error) {
+			return filter(nodes, func(n Iid) bool {
+				for _, v := range g.Values(n, args.Iids[0]) {
+					if slices.Contains(args.Literals, strings.ToLower(v)) {
+						return true
+					}
+				}
+				return false
+			}), nil
+		},
+	})
+})
+
+func TestRegisterStep(t *testing.T) {
+	tok, err := registerHasName()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if Atot("HasName") != tok || Ttoa(tok) != "HasName" {
+		t.Errorf("Expected HasName to be token %d", tok)
+	}
+
+	cmd := `Start[iri].HasType[ex:Gremlin].HasName[ex:Name, "GIZMO", Blob].Eval`
+	names := evalCmd(t, cmd)
+	if !reflect.DeepEqual(names, []string{"ex:gizmo"}) {
+		t.Errorf("Expected [ex:gizmo] got %v", names)
+	}
+
+	chain, err := ParseCommand(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	is := NewIidStore()
+	steps, err := InternalizeSteps(chain, is)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := istep{Token: tok, Arg: is.Put("ex:Name"), Svals: []string{"gizmo", "blob"}}
+	if !reflect.DeepEqual(steps[2], x) {
+		t.Errorf("Expected %+v got %+v", x, steps[2])
+	}
+
+	if _, err := ParseCommand(`Start[iri].HasName[ex:Name].Eval`); err == nil {
+		t.Errorf("Expected error for HasName without a name")
+	}
+}
+
+func TestRegisterStepInvalid(t *testing.T) {
+	eval := func(g Graph, nodes []Iid, args StepArgs) ([]Iid, error) {
+		return nodes, nil
+	}
+	steps := []CustomStep{
+		{Name: "HasType", Eval: eval},
+		{Name: "And", Eval: eval},
+		{Name: "Use", Eval: eval},
+		{Name: "lower", Eval: eval},
+		{Name: "Has.Dot", Eval: eval},
+		{Name: "NoEval"},
+		{Name: "LiteralFirst", Args: []ArgKind{LiteralArg, IdentArg}, Eval: eval},
+		{Name: "BadKind", Args: []ArgKind{0}, Eval: eval},
+	}
+	for _, c := range steps {
+		if _, err := RegisterStep(c); err == nil {
+			t.Errorf("Expected error registering %s", c.Name)
+		}
+	}
+}
+
+func TestStepNames(t *testing.T) {
+	names := StepNames()
+	if !slices.IsSorted(names) {
+		t.Errorf("Expected sorted names got %v", names)
+	}
+	for _, n := range []string{"Start", "Eval", "HasType", "Or"} {
+		if !slices.Contains(names, n) {
+			t.Errorf("Expected %s in %v", n, names)
+		}
+	}
+	if slices.Contains(names, "NoOp") {
+		t.Errorf("Expected NoOp not to be listed")
+	}
+}
@@ -0,0 +1,424 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Rule is a named command of a rule file.
+type Rule struct {
+	Name        string
+	Description string
+	Owner       string
+	Tags        []string
+	Comments    []string // comments before the rule and its metadata
+	Chain       []Step
+	Line        int       // line of the rule keyword
+	Positions   []StepPos // where the steps of the rule are written in the file, before fragments are expanded
+
+	source []Step // the chain as written, before fragments and prefixes are expanded
+	uses   []int  // lines of the Use steps of source, in order
+}
+
+// RuleSet is the rules of a rule file and the prefixes they share.
+//
+// A rule file holds PREFIX declarations, fragment definitions and rules,
+// each rule optionally preceded by metadata. Comments start with # or // at
+// the start of a line or after whitespace and run to the end of the line, or
+// are enclosed in /* and */.
+//
+//	PREFIX zoo: <http://example.org/>
+//
+//	@description "Monsters that eat tasty meals"
+//	@owner zoo-team
+//	@tags monsters, food
+//	rule HungryMonsters {
+//	    Start[iri]
+//	    .Or(HasType[zoo:Gremlin].HasType[zoo:GooGrok])
+//	    .Follow[zoo:Eats]
+//	    .HasType[zoo:TastyMeal]
+//	    .Eval
+//	}
+type RuleSet struct {
+	Prefixes  PrefixMap
+	Fragments []Fragment
+	Rules     []Rule
+	Comments  []string // comments of the prefix declarations
+	Trailing  []string // comments after the last rule
+}
+
+// Loads a rule file.
+func LoadRuleSetFile(path string) (*RuleSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rs, err := LoadRuleSet(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rs, nil
+}
+
+func LoadRuleSet(r io.Reader) (*RuleSet, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRuleSet(string(b))
+}
+
+var ruleName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// Parses the source of a rule file. The chains of the rules have their
+// fragments inserted and their arguments written with the prefixes of the
+// file expanded.
+func ParseRuleSet(src string) (*RuleSet, error) {
+	comments, err := findComments(src)
+	if err != nil {
+		c := comments[len(comments)-1]
+		return nil, fmt.Errorf("line %d: %w", strings.Count(src[:c.start], "\n")+1, err)
+	}
+	p := ruleParser{src: blankComments(src, comments), orig: src}
+	rs := &RuleSet{
+		Prefixes: make(PrefixMap),
+		Rules:    make([]Rule, 0),
+	}
+	var meta Rule
+	hasMeta := false
+
+	// Takes the comments before the current position. The comments of the
+	// rule bodies are left to the command parser.
+	take := func() []string {
+		var taken []string
+		for len(comments) > 0 && comments[0].start < p.pos {
+			taken = append(taken, comments[0].text)
+			comments = comments[1:]
+		}
+		return taken
+	}
+
+	for {
+		p.skipSpace()
+		if p.eof() {
+			break
+		}
+		line := p.line()
+		word := p.word()
+		switch {
+		case strings.EqualFold(word, "PREFIX"):
+			if hasMeta {
+				return nil, fmt.Errorf("line %d: expected rule after metadata", line)
+			}
+			name, iri, err := p.prefix()
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			if rest := strings.TrimSpace(p.restOfLine()); rest != "" {
+				return nil, fmt.Errorf("line %d: unexpected %q after PREFIX", line, rest)
+			}
+			rs.Prefixes[name] = iri
+			rs.Comments = append(rs.Comments, take()...)
+		case strings.HasPrefix(word, "@"):
+			val := strings.TrimSpace(p.restOfLine())
+			switch word {
+			case "@description":
+				meta.Description = removeOuterQuotes(val)
+			case "@owner":
+				meta.Owner = removeOuterQuotes(val)
+			case "@tags":
+				for _, t := range strings.Split(val, ",") {
+					if t = removeOuterQuotes(strings.TrimSpace(t)); t != "" {
+						meta.Tags = append(meta.Tags, t)
+					}
+				}
+			default:
+				return nil, fmt.Errorf("line %d: unknown metadata %s", line, word)
+			}
+			hasMeta = true
+		case word == "def":
+			if hasMeta {
+				return nil, fmt.Errorf("line %d: expected rule after metadata", line)
+			}
+			leading := take()
+			f, err := p.def()
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			for len(comments) > 0 && comments[0].start < p.pos {
+				comments = comments[1:]
+			}
+			if _, ok := rs.Fragment(f.Name); ok {
+				return nil, fmt.Errorf("line %d: fragment %s is defined more than once", line, f.Name)
+			}
+			f.Comments, f.Line = leading, line
+			rs.Fragments = append(rs.Fragments, f)
+		case word == "rule":
+			leading := take()
+			r, err := p.rule()
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			for len(comments) > 0 && comments[0].start < p.pos {
+				comments = comments[1:]
+			}
+			r.Comments = leading
+			if _, ok := rs.Rule(r.Name); ok {
+				return nil, fmt.Errorf("line %d: rule %s is defined more than once", line, r.Name)
+			}
+			r.Description, r.Owner, r.Tags = meta.Description, meta.Owner, meta.Tags
+			r.Line = line
+			rs.Rules = append(rs.Rules, r)
+			meta, hasMeta = Rule{}, false
+		default:
+			return nil, fmt.Errorf("line %d: expected PREFIX, def, metadata or rule got %q", line, word)
+		}
+	}
+	if hasMeta {
+		return nil, fmt.Errorf("expected rule after metadata")
+	}
+	p.pos = len(p.src)
+	rs.Trailing = take()
+
+	// Fragments are checked on their own, so that unused ones are too.
+	for _, f := range rs.Fragments {
+		if _, err := rs.expand(f.Chain, f.uses, []string{f.Name}); err != nil {
+			return nil, fmt.Errorf("line %d: fragment %s: %w", f.Line, f.Name, err)
+		}
+	}
+	for i, r := range rs.Rules {
+		chain, err := rs.expand(r.source, r.uses, nil)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: rule %s: %w", r.Line, r.Name, err)
+		}
+		rs.Rules[i].Chain = ExpandPrefixes(chain, rs.Prefixes)
+	}
+	return rs, nil
+}
+
+// Returns the rule with the given name.
+func (rs *RuleSet) Rule(name string) (*Rule, bool) {
+	for i := range rs.Rules {
+		if rs.Rules[i].Name == name {
+			return &rs.Rules[i], true
+		}
+	}
+	return nil, false
+}
+
+// RuleDiagnostic is a problem found in a rule of a rule set.
+type RuleDiagnostic struct {
+	Rule string
+	Line int
+	Diagnostic
+}
+
+func (d RuleDiagnostic) String() string {
+	return fmt.Sprintf("rule %s (line %d): %s", d.Rule, d.Line, d.Diagnostic)
+}
+
+// Validates every rule of the set.
+func (rs *RuleSet) Validate(v *Validator) []RuleDiagnostic {
+	diags := make([]RuleDiagnostic, 0)
+	for _, r := range rs.Rules {
+		for _, d := range v.Validate(r.Chain) {
+			diags = append(diags, RuleDiagnostic{Rule: r.Name, Line: r.Line, Diagnostic: d})
+		}
+	}
+	return diags
+}
+
+// Internalizes every rule of the set and returns the steps by rule name.
+func (rs *RuleSet) Compile(is Internalizer) (map[string][]istep, error) {
+	plans := make(map[string][]istep, len(rs.Rules))
+	for _, r := range rs.Rules {
+		steps, err := InternalizeSteps(r.Chain, is)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s (line %d): %w", r.Name, r.Line, err)
+		}
+		plans[r.Name] = steps
+	}
+	return plans, nil
+}
+
+// Reports whether the source is a rule file rather than a single command.
+func IsRuleSet(src string) bool {
+	p := ruleParser{src: stripComments(src)}
+	p.skipSpace()
+	word := p.word()
+	return strings.EqualFold(word, "PREFIX") || strings.HasPrefix(word, "@") || word == "rule" || word == "def"
+}
+
+type ruleParser struct {
+	src  string // the source with its comments blanked
+	orig string // the source with its comments, for the rule bodies
+	pos  int
+}
+
+func (p *ruleParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *ruleParser) line() int {
+	return strings.Count(p.src[:p.pos], "\n") + 1
+}
+
+func (p *ruleParser) skipSpace() {
+	for !p.eof() && strings.IndexByte(" \t\r\n", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+// Reads up to the next whitespace or brace.
+func (p *ruleParser) word() string {
+	start := p.pos
+	for !p.eof() && strings.IndexByte(" \t\r\n{}", p.src[p.pos]) < 0 {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *ruleParser) restOfLine() string {
+	start := p.pos
+	for !p.eof() && p.src[p.pos] != '\n' {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+// Parses the rest of a PREFIX declaration: name: <iri>
+func (p *ruleParser) prefix() (string, string, error) {
+	p.skipSpace()
+	name := p.word()
+	if !strings.HasSuffix(name, ":") {
+		return "", "", fmt.Errorf("expected PREFIX name: <iri> got %q", name)
+	}
+	p.skipSpace()
+	iri := p.word()
+	if !strings.HasPrefix(iri, "<") || !strings.HasSuffix(iri, ">") {
+		return "", "", fmt.Errorf("expected PREFIX name: <iri> got %q", iri)
+	}
+	return strings.TrimSuffix(name, ":"), iri[1 : len(iri)-1], nil
+}
+
+// Parses the rest of a rule: Name { command }
+func (p *ruleParser) rule() (Rule, error) {
+	p.skipSpace()
+	name := p.word()
+	if !ruleName.MatchString(name) {
+		return Rule{}, fmt.Errorf("invalid rule name %q", name)
+	}
+	p.skipSpace()
+	if p.eof() || p.src[p.pos] != '{' {
+		return Rule{}, fmt.Errorf("expected { after rule %s", name)
+	}
+	p.pos++
+
+	start := p.pos
+	iq := false
+	for ; !p.eof(); p.pos++ {
+		c := p.src[p.pos]
+		if iq && c == '\\' {
+			p.pos++
+			continue
+		}
+		if c == '"' {
+			iq = !iq
+		}
+		if c == '}' && !iq {
+			break
+		}
+	}
+	if p.eof() {
+		return Rule{}, fmt.Errorf("rule %s is not closed with }", name)
+	}
+	body := p.orig[start:p.pos]
+	p.pos++
+
+	chain, err := parseCommand(body)
+	if err != nil {
+		return Rule{}, fmt.Errorf("rule %s: %w", name, err)
+	}
+	return Rule{
+		Name:      name,
+		Positions: shiftPositions(StepPositions(body, chain), start, 0),
+		source:    chain,
+		uses:      useLines(p.src[start:p.pos-1], strings.Count(p.src[:start], "\n")+1),
+	}, nil
+}
+
+// Formats a rule set as a rule file that parses back to the same rules, with
+// the prefixes in name order and the fragments and rules in the order they
+// were defined.
+func FormatRuleSet(rs *RuleSet) string {
+	var sb strings.Builder
+	for _, c := range rs.Comments {
+		sb.WriteString(c + "\n")
+	}
+	names := make([]string, 0, len(rs.Prefixes))
+	for name := range rs.Prefixes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&sb, "PREFIX %s: <%s>\n", name, rs.Prefixes[name])
+	}
+
+	for _, f := range rs.Fragments {
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		for _, c := range f.Comments {
+			sb.WriteString(c + "\n")
+		}
+		sb.WriteString("def " + f.Name)
+		if len(f.Params) > 0 {
+			sb.WriteString("(" + strings.Join(f.Params, ", ") + ")")
+		}
+		sb.WriteString(" = ")
+		formatChain(&sb, f.Chain, "")
+		sb.WriteString("\n")
+	}
+
+	for i, r := range rs.Rules {
+		if i > 0 || sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		for _, c := range r.Comments {
+			sb.WriteString(c + "\n")
+		}
+		if r.Description != "" {
+			sb.WriteString("@description " + bremlinString(r.Description) + "\n")
+		}
+		if r.Owner != "" {
+			sb.WriteString("@owner " + bremlinArg(r.Owner) + "\n")
+		}
+		if len(r.Tags) > 0 {
+			tags := make([]string, len(r.Tags))
+			for j, t := range r.Tags {
+				tags[j] = bremlinArg(t)
+			}
+			sb.WriteString("@tags " + strings.Join(tags, ", ") + "\n")
+		}
+		chain := r.source
+		if chain == nil {
+			chain = r.Chain
+		}
+		sb.WriteString("rule " + r.Name + " {\n")
+		formatChain(&sb, chain, "\t")
+		sb.WriteString("\n}\n")
+	}
+
+	if len(rs.Trailing) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, c := range rs.Trailing {
+		sb.WriteString(c + "\n")
+	}
+	return sb.String()
+}
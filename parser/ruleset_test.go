@@ -0,0 +1,184 @@
+package parser
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadRuleSet(t *testing.T) {
+	rs, err := LoadRuleSetFile("testdata/rules/monsters.brm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs.Rules) != 3 {
+		t.Fatalf("Expected 3 rules got %d", len(rs.Rules))
+	}
+	if rs.Prefixes["zoo"] != "http://example.org/" {
+		t.Errorf("Expected zoo prefix got %v", rs.Prefixes)
+	}
+
+	r, ok := rs.Rule("HungryMonsters")
+	if !ok {
+		t.Fatal("Expected HungryMonsters rule")
+	}
+	if r.Description != "Monsters that eat tasty meals" || r.Owner != "zoo-team" || r.Line != 7 {
+		t.Errorf("Unexpected metadata %+v", r)
+	}
+	if !reflect.DeepEqual(r.Tags, []string{"monsters", "food"}) {
+		t.Errorf("Expected tags [monsters food] got %v", r.Tags)
+	}
+	expected := "Start[iri].Or[](HasType[ex:Gremlin].HasType[ex:GooGrok]).Follow[ex:Eats].HasType[ex:TastyMeal].Eval[]"
+	if chainString(r.Chain) != expected {
+		t.Errorf("Expected %s got %s", expected, chainString(r.Chain))
+	}
+
+	r, _ = rs.Rule("ActivePredators")
+	if r.Description != "" || !reflect.DeepEqual(r.Tags, []string{"monsters"}) {
+		t.Errorf("Expected metadata not to carry over got %+v", r)
+	}
+	expected = "Start[iri].HasBroader[ex:Animals,ex:Preditor].IsActive[].Eval[]"
+	if chainString(r.Chain) != expected {
+		t.Errorf("Expected %s got %s", expected, chainString(r.Chain))
+	}
+}
+
+func TestParseRuleSetErrors(t *testing.T) {
+	tests := []struct {
+		src string
+		err string
+	}{
+		{"rule A { Start[iri].Eval }\nrule A { Start[iri].Eval }", "line 2: rule A is defined more than once"},
+		{"rule A {\n Start[iri].HasType[].Eval\n}", "line 1: rule A:"},
+		{"rule A Start[iri].Eval", "expected { after rule A"},
+		{"rule A { Start[iri].Eval", "rule A is not closed"},
+		{"rule 1A { Start[iri].Eval }", "invalid rule name"},
+		{"PREFIX zoo <http://example.org/>", "expected PREFIX name: <iri>"},
+		{"@color red\nrule A { Start[iri].Eval }", "line 1: unknown metadata @color"},
+		{"@owner me", "expected rule after metadata"},
+		{"Start[iri].Eval", "expected PREFIX, def, metadata or rule"},
+		{"PREFIX zoo: <http://example.org/> rule", "line 1: unexpected \"rule\" after PREFIX"},
+		{"rule A { Start[iri].Eval }\n/* open", "line 2: unterminated comment"},
+	}
+	for _, test := range tests {
+		_, err := ParseRuleSet(test.src)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: expected error containing %q got %v", test.src, test.err, err)
+		}
+	}
+}
+
+func TestRuleSetValidateAndCompile(t *testing.T) {
+	rs, err := ParseRuleSet(`
+rule Good { Start[iri].HasType[ex:Gremlin].Eval }
+rule Bad {
+    Start[iri].HasType[ex:Hobbit].Eval
+}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diags := rs.Validate(NewValidator(loadTestSchema(t)))
+	if len(diags) != 1 || diags[0].Rule != "Bad" || diags[0].Line != 3 {
+		t.Fatalf("Expected 1 diagnostic for Bad got %v", diags)
+	}
+	if !strings.HasPrefix(diags[0].String(), "rule Bad (line 3): error: step 1 (HasType)") {
+		t.Errorf("Unexpected diagnostic %s", diags[0])
+	}
+
+	is := NewIidStore()
+	plans, err := rs.Compile(is)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plans) != 2 || len(plans["Bad"]) != 3 {
+		t.Errorf("Unexpected plans %+v", plans)
+	}
+	if i, _ := is.GetIid("ex:Hobbit"); plans["Bad"][1].Arg != i {
+		t.Errorf("Expected Bad to refer to ex:Hobbit")
+	}
+}
+
+func TestIsRuleSet(t *testing.T) {
+	for src, expected := range map[string]bool{
+		"// rules\nrule A { Start[iri].Eval }": true,
+		"prefix a: <http://a/>":                true,
+		"@owner me":                            true,
+		"Start[iri].Eval":                      false,
+		"# just a comment\nStart[iri].Eval":    false,
+	} {
+		if IsRuleSet(src) != expected {
+			t.Errorf("%q: expected %v", src, expected)
+		}
+	}
+}
+
+func TestRuleSetComments(t *testing.T) {
+	rs, err := LoadRuleSetFile("testdata/rules/monsters.brm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rs.Comments, []string{"# Rules about the monsters of the zoo."}) {
+		t.Errorf("Unexpected header comments %q", rs.Comments)
+	}
+	r, _ := rs.Rule("ActivePredators")
+	if !reflect.DeepEqual(r.Comments, []string{"// Active predators in the animal taxonomy."}) {
+		t.Errorf("Unexpected rule comments %q", r.Comments)
+	}
+	r, _ = rs.Rule("HungryMonsters")
+	if r.Comments != nil || !reflect.DeepEqual(r.Chain[1].subcmd[1].trailing, []string{"// either kind"}) {
+		t.Errorf("Expected the comment in the body to stay with its step got %q %+v", r.Comments, r.Chain[1])
+	}
+}
+
+func TestFormatRuleSet(t *testing.T) {
+	b, err := os.ReadFile("testdata/rules/monsters.brm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := ParseRuleSet(string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := FormatRuleSet(rs)
+	for _, s := range []string{
+		"# Rules about the monsters of the zoo.\nPREFIX zoo: <http://example.org/>\n",
+		"\t\t.HasType[zoo:GooGrok]  // either kind\n",
+		"// Active predators in the animal taxonomy.\n@tags monsters\nrule ActivePredators {\n",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Expected %q in\n%s", s, out)
+		}
+	}
+
+	again, err := ParseRuleSet(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if FormatRuleSet(again) != out {
+		t.Errorf("Expected formatting to be stable got\n%s", FormatRuleSet(again))
+	}
+}
+
+func TestFormatRuleSetEscapes(t *testing.T) {
+	src := "@description \"Files under \\\"C:\\\\\\\"\"\nrule Files { Start[iri].HasValue[ex:Path, \"C:\\\\\", \"a}b\"].Eval }\n"
+	rs, err := ParseRuleSet(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := rs.Rules[0].Description; d != `Files under "C:\"` {
+		t.Errorf("Expected Files under \"C:\\\" got %s", d)
+	}
+
+	again, err := ParseRuleSet(FormatRuleSet(rs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Rules[0].Description != rs.Rules[0].Description {
+		t.Errorf("Expected %s got %s", rs.Rules[0].Description, again.Rules[0].Description)
+	}
+	if vals := again.Rules[0].Chain[1].vals; !reflect.DeepEqual(vals, []string{`C:\`, "a}b"}) {
+		t.Errorf("Unexpected values %q", vals)
+	}
+}
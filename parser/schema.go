@@ -0,0 +1,232 @@
+package parser
+
+import (
+	"io"
+	"os"
+	"slices"
+)
+
+const (
+	owlNs  = "http://www.w3.org/2002/07/owl#"
+	rdfsNs = "http://www.w3.org/2000/01/rdf-schema#"
+	rdfNs  = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+	skosNs = "http://www.w3.org/2004/02/skos/core#"
+)
+
+// Schema vocabulary in the compact form used for step arguments.
+var (
+	owlClass            = compactIri(owlNs + "Class")
+	owlObjectProperty   = compactIri(owlNs + "ObjectProperty")
+	owlDatatypeProperty = compactIri(owlNs + "DatatypeProperty")
+	owlOneOf            = compactIri(owlNs + "oneOf")
+	owlDisjointWith     = compactIri(owlNs + "disjointWith")
+	rdfsClass           = compactIri(rdfsNs + "Class")
+	rdfsDatatype        = compactIri(rdfsNs + "Datatype")
+	rdfsSubClassOf      = compactIri(rdfsNs + "subClassOf")
+	rdfsDomain          = compactIri(rdfsNs + "domain")
+	rdfsRange           = compactIri(rdfsNs + "range")
+	rdfProperty         = compactIri(rdfNs + "Property")
+	skosConceptScheme   = compactIri(skosNs + "ConceptScheme")
+)
+
+type PropertyKind int
+
+const (
+	AnyProperty PropertyKind = iota
+	ObjectProperty
+	DatatypeProperty
+)
+
+// Property describes a property declared in a schema.
+type Property struct {
+	Name   string
+	Kind   PropertyKind
+	Domain []string
+	Range  []string
+}
+
+// Schema holds the classes, properties and concept schemes declared in an
+// OWL, RDFS or SKOS document.
+type Schema struct {
+	classes    map[string]bool
+	superclass map[string][]string
+	properties map[string]*Property
+	schemes    map[string]bool
+	enums      map[string][]string
+	disjoint   map[string][]string
+}
+
+// Loads a schema from a Turtle or N-Triples file.
+func LoadSchemaFile(path string) (*Schema, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadSchema(f)
+}
+
+// Loads a schema from a Turtle or N-Triples document.
+func LoadSchema(r io.Reader) (*Schema, error) {
+	triples, err := ReadTriples(r)
+	if err != nil {
+		return nil, err
+	}
+	return NewSchema(triples), nil
+}
+
+// Builds a schema from a set of triples.
+func NewSchema(triples []Triple) *Schema {
+	s := &Schema{
+		classes:    make(map[string]bool),
+		superclass: make(map[string][]string),
+		properties: make(map[string]*Property),
+		schemes:    make(map[string]bool),
+		enums:      make(map[string][]string),
+		disjoint:   make(map[string][]string),
+	}
+
+	bySubject := make(map[string][]Triple)
+	for _, t := range triples {
+		bySubject[t.S] = append(bySubject[t.S], t)
+	}
+
+	prop := func(name string) *Property {
+		p, ok := s.properties[name]
+		if !ok {
+			p = &Property{Name: name}
+			s.properties[name] = p
+		}
+		return p
+	}
+
+	for _, t := range triples {
+		switch t.P {
+		case rdfType:
+			switch t.O {
+			case owlClass, rdfsClass, rdfsDatatype:
+				s.classes[t.S] = true
+			case owlObjectProperty:
+				prop(t.S).Kind = ObjectProperty
+			case owlDatatypeProperty:
+				prop(t.S).Kind = DatatypeProperty
+			case rdfProperty:
+				prop(t.S)
+			case skosConceptScheme:
+				s.schemes[t.S] = true
+			}
+		case rdfsSubClassOf:
+			s.classes[t.S] = true
+			if !t.Literal {
+				s.superclass[t.S] = append(s.superclass[t.S], t.O)
+			}
+		case rdfsDomain:
+			p := prop(t.S)
+			p.Domain = append(p.Domain, t.O)
+		case rdfsRange:
+			p := prop(t.S)
+			p.Range = append(p.Range, t.O)
+		case owlOneOf:
+			s.enums[t.S] = listItems(bySubject, t.O)
+		case owlDisjointWith:
+			s.disjoint[t.S] = append(s.disjoint[t.S], t.O)
+			s.disjoint[t.O] = append(s.disjoint[t.O], t.S)
+		}
+	}
+
+	return s
+}
+
+// Reports whether name is a declared class.
+func (s *Schema) IsClass(name string) bool {
+	return s.classes[name]
+}
+
+// Reports whether name is a declared skos:ConceptScheme.
+func (s *Schema) IsConceptScheme(name string) bool {
+	return s.schemes[name]
+}
+
+// Returns the declared property with the given name.
+func (s *Schema) Property(name string) (*Property, bool) {
+	p, ok := s.properties[name]
+	return p, ok
+}
+
+// Returns the values allowed by an enumerated range of the property, or nil
+// if the range of the property is not enumerated.
+func (s *Schema) Enumeration(prop string) []string {
+	p, ok := s.properties[prop]
+	if !ok {
+		return nil
+	}
+	var vals []string
+	for _, r := range p.Range {
+		vals = append(vals, s.enums[r]...)
+	}
+	return vals
+}
+
+// Reports whether class sub is class super or one of its subclasses.
+func (s *Schema) IsSubClassOf(sub, super string) bool {
+	seen := make(map[string]bool)
+	todo := []string{sub}
+	for len(todo) > 0 {
+		c := todo[len(todo)-1]
+		todo = todo[:len(todo)-1]
+		if c == super {
+			return true
+		}
+		if seen[c] {
+			continue
+		}
+		seen[c] = true
+		todo = append(todo, s.superclass[c]...)
+	}
+	return false
+}
+
+// Returns class and all of its superclasses.
+func (s *Schema) ancestors(class string) []string {
+	seen := map[string]bool{class: true}
+	out := []string{class}
+	for i := 0; i < len(out); i++ {
+		for _, sup := range s.superclass[out[i]] {
+			if !seen[sup] {
+				seen[sup] = true
+				out = append(out, sup)
+			}
+		}
+	}
+	return out
+}
+
+// Reports whether classes a and b are declared disjoint, either directly or
+// through one of their superclasses.
+func (s *Schema) IsDisjoint(a, b string) bool {
+	bs := s.ancestors(b)
+	for _, x := range s.ancestors(a) {
+		for _, d := range s.disjoint[x] {
+			if slices.Contains(bs, d) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Reports whether any class in a is compatible with any class in b, that is
+// one is a subclass of the other. Empty sets are compatible with everything.
+func (s *Schema) compatible(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	for _, x := range a {
+		for _, y := range b {
+			if s.IsSubClassOf(x, y) || s.IsSubClassOf(y, x) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"testing"
+)
+
+func loadTestSchema(t *testing.T) *Schema {
+	t.Helper()
+	s, err := LoadSchemaFile("testdata/animals.ttl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSchemaClasses(t *testing.T) {
+	s := loadTestSchema(t)
+
+	for _, c := range []string{"ex:Gremlin", "ex:GooGrok", "ex:TastyMeal"} {
+		if !s.IsClass(c) {
+			t.Errorf("Expected %s to be a class", c)
+		}
+	}
+
+	if s.IsClass("ex:SmellOfFood") {
+		t.Errorf("Expected ex:SmellOfFood not to be a class")
+	}
+
+	if !s.IsSubClassOf("ex:Gremlin", "ex:Creature") {
+		t.Errorf("Expected ex:Gremlin to be a subclass of ex:Creature")
+	}
+
+	if s.IsSubClassOf("ex:Creature", "ex:Gremlin") {
+		t.Errorf("Expected ex:Creature not to be a subclass of ex:Gremlin")
+	}
+}
+
+func TestSchemaProperties(t *testing.T) {
+	s := loadTestSchema(t)
+
+	p, ok := s.Property("ex:SmellOfFood")
+	if !ok {
+		t.Fatalf("Expected ex:SmellOfFood to be a property")
+	}
+	if p.Kind != ObjectProperty {
+		t.Errorf("Expected ex:SmellOfFood to be an object property")
+	}
+	if len(p.Range) != 1 || p.Range[0] != "ex:Smell" {
+		t.Errorf("Expected range [ex:Smell] got %v", p.Range)
+	}
+
+	p, ok = s.Property("ex:FurColor")
+	if !ok || p.Kind != DatatypeProperty {
+		t.Errorf("Expected ex:FurColor to be a datatype property")
+	}
+}
+
+func TestSchemaEnumeration(t *testing.T) {
+	s := loadTestSchema(t)
+
+	enum := s.Enumeration("ex:FurColor")
+	expected := []string{"green", "blue", "grey"}
+	if len(enum) != len(expected) {
+		t.Fatalf("Expected %v got %v", expected, enum)
+	}
+	for i, e := range expected {
+		if enum[i] != e {
+			t.Errorf("Expected %s got %s", e, enum[i])
+		}
+	}
+
+	if s.Enumeration("ex:Name") != nil {
+		t.Errorf("Expected ex:Name not to be enumerated")
+	}
+}
+
+func TestSchemaConceptScheme(t *testing.T) {
+	s := loadTestSchema(t)
+
+	if !s.IsConceptScheme("ex:Animals") {
+		t.Errorf("Expected ex:Animals to be a concept scheme")
+	}
+	if s.IsConceptScheme("ex:Fantasy") {
+		t.Errorf("Expected ex:Fantasy not to be a concept scheme")
+	}
+}
@@ -0,0 +1,348 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SPARQLTranslator translates chains of steps to SPARQL SELECT queries.
+type SPARQLTranslator struct {
+	Prefixes   PrefixMap
+	Vocabulary Vocabulary
+}
+
+// Translates a chain of steps to a SPARQL SELECT query returning the nodes
+// the chain evaluates to, using the default vocabulary.
+func ToSPARQL(chain []Step, pm PrefixMap) (string, error) {
+	t := SPARQLTranslator{
+		Prefixes:   pm,
+		Vocabulary: DefaultVocabulary(),
+	}
+	return t.Translate(chain)
+}
+
+// Translates a chain of steps to a SPARQL SELECT query.
+func (t *SPARQLTranslator) Translate(chain []Step) (string, error) {
+	if len(chain) < 2 || chain[0].token != "Start" || chain[len(chain)-1].token != "Eval" {
+		return "", fmt.Errorf("expected chain to begin with Start and end with Eval")
+	}
+
+	w := sparqlWriter{
+		t:    t,
+		used: make(map[string]bool),
+	}
+	steps, limit, err := trailingLimit(chain[1 : len(chain)-1])
+	if err != nil {
+		return "", err
+	}
+	node := w.newNode()
+	lines, node, bound, err := w.group(steps, node, false)
+	if err != nil {
+		return "", err
+	}
+	if !bound {
+		lines = append([]string{node + " ?p ?o ."}, lines...)
+	}
+
+	var b strings.Builder
+	for _, prefix := range t.Prefixes.prefixes() {
+		if w.used[prefix] {
+			fmt.Fprintf(&b, "PREFIX %s: <%s>\n", prefix, t.Prefixes[prefix])
+		}
+	}
+	fmt.Fprintf(&b, "SELECT DISTINCT %s WHERE {\n", node)
+	for _, l := range lines {
+		b.WriteString("  " + l + "\n")
+	}
+	b.WriteString("}\n")
+	if limit > 0 {
+		fmt.Fprintf(&b, "LIMIT %d\n", limit)
+	}
+	return b.String(), nil
+}
+
+type sparqlWriter struct {
+	t     *SPARQLTranslator
+	used  map[string]bool
+	nodes int
+	vals  int
+}
+
+func (w *sparqlWriter) newNode() string {
+	v := fmt.Sprintf("?n%d", w.nodes)
+	w.nodes++
+	return v
+}
+
+func (w *sparqlWriter) newValue() string {
+	v := fmt.Sprintf("?v%d", w.vals)
+	w.vals++
+	return v
+}
+
+// Translates a chain starting at node. Returns the patterns, the node the
+// chain ends at and whether that node is bound by a pattern.
+func (w *sparqlWriter) group(chain []Step, node string, bound bool) ([]string, string, bool, error) {
+	lines := make([]string, 0)
+
+	for i := 0; i < len(chain); i++ {
+		s := chain[i]
+		switch s.token {
+		case "Follow", "FollowInverse":
+			// Consecutive traversals are combined into a single property path.
+			path := make([]string, 0)
+			for ; i < len(chain) && isFollow(chain[i]); i++ {
+				p, err := w.iri(chain[i].arg)
+				if err != nil {
+					return nil, "", false, err
+				}
+				if chain[i].token == "FollowInverse" {
+					p = "^" + p
+				}
+				path = append(path, p)
+			}
+			i--
+			next := w.newNode()
+			lines = append(lines, fmt.Sprintf("%s %s %s .", node, strings.Join(path, "/"), next))
+			node, bound = next, true
+		case "Or":
+			l, next, err := w.union(s, node, bound)
+			if err != nil {
+				return nil, "", false, err
+			}
+			lines = append(lines, l...)
+			node, bound = next, true
+		default:
+			if s.token == "IsInactive" && !bound {
+				lines = append(lines, node+" ?p ?o .")
+			}
+			l, err := w.filter(s, node)
+			if err != nil {
+				return nil, "", false, err
+			}
+			lines = append(lines, l...)
+			// VALUES and FILTER NOT EXISTS do not require the node to be in
+			// the graph.
+			bound = bound || (len(l) > 0 && s.token != "IsInactive" && s.token != "IsInstance")
+		}
+	}
+
+	return lines, node, bound, nil
+}
+
+// Translates an Or step to a UNION of its alternatives. When an alternative
+// moves to another node, every alternative binds its result to a new node.
+func (w *sparqlWriter) union(s Step, node string, bound bool) ([]string, string, error) {
+	next := node
+	if moves(s) {
+		next = w.newNode()
+	}
+
+	lines := make([]string, 0)
+	for i, sub := range s.subcmd {
+		var l []string
+		var err error
+		if next != node && isFollow(sub) {
+			// Bind the traversal directly to the result node.
+			p, perr := w.iri(sub.arg)
+			if perr != nil {
+				return nil, "", perr
+			}
+			if sub.token == "FollowInverse" {
+				p = "^" + p
+			}
+			l = []string{fmt.Sprintf("%s %s %s .", node, p, next)}
+		} else {
+			var end string
+			var b bool
+			l, end, b, err = w.group([]Step{sub}, node, bound)
+			if err != nil {
+				return nil, "", err
+			}
+			if !b {
+				l = append([]string{end + " ?p ?o ."}, l...)
+			}
+			if end != next {
+				l = append(l, fmt.Sprintf("BIND(%s AS %s)", end, next))
+			}
+		}
+
+		if i > 0 {
+			lines = append(lines, "UNION")
+		}
+		lines = append(lines, "{")
+		for _, x := range l {
+			lines = append(lines, "  "+x)
+		}
+		lines = append(lines, "}")
+	}
+	return lines, next, nil
+}
+
+// Translates a step that filters the current node.
+func (w *sparqlWriter) filter(s Step, node string) ([]string, error) {
+	v := w.t.Vocabulary
+	switch s.token {
+	case "NoOp":
+		return nil, nil
+	case "HasType":
+		return w.triples(node, [2]string{v.Type, s.arg})
+	case "HasCategory":
+		return w.triples(node, [2]string{v.Category, s.arg})
+	case "InScheme":
+		return w.triples(node, [2]string{v.InScheme, s.arg})
+	case "HasBroader":
+		if len(s.vals) != 1 {
+			return nil, fmt.Errorf("expected HasBroader[taxonomy, target] got %d values", len(s.vals))
+		}
+		return w.triples(node, [2]string{v.InScheme, s.arg}, [2]string{v.Broader, s.vals[0]})
+	case "IsInstance":
+		o, err := w.iri(s.arg)
+		if err != nil {
+			return nil, err
+		}
+		return []string{fmt.Sprintf("VALUES %s { %s }", node, o)}, nil
+	case "HasValue":
+		p, err := w.iri(s.arg)
+		if err != nil {
+			return nil, err
+		}
+		val := w.newValue()
+		lits := make([]string, len(s.vals))
+		for i, x := range s.vals {
+			lits[i] = sparqlString(x)
+		}
+		return []string{
+			fmt.Sprintf("%s %s %s .", node, p, val),
+			fmt.Sprintf("VALUES %s { %s }", val, strings.Join(lits, " ")),
+		}, nil
+	case "IsActive", "IsInactive":
+		p, err := w.predicate(v.Active)
+		if err != nil {
+			return nil, err
+		}
+		pattern := fmt.Sprintf("%s %s %s .", node, p, sparqlLiteral(v.ActiveValue))
+		if s.token == "IsInactive" {
+			return []string{fmt.Sprintf("FILTER NOT EXISTS { %s }", pattern)}, nil
+		}
+		return []string{pattern}, nil
+	case "Limit":
+		return nil, fmt.Errorf("cannot translate Limit to SPARQL unless it ends the command")
+	default:
+		return nil, fmt.Errorf("cannot translate %s to SPARQL", s.token)
+	}
+}
+
+// Returns a triple pattern for every predicate object pair.
+func (w *sparqlWriter) triples(node string, po ...[2]string) ([]string, error) {
+	lines := make([]string, 0, len(po))
+	for _, x := range po {
+		p, err := w.predicate(x[0])
+		if err != nil {
+			return nil, err
+		}
+		o, err := w.iri(x[1])
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %s .", node, p, o))
+	}
+	return lines, nil
+}
+
+func (w *sparqlWriter) predicate(name string) (string, error) {
+	if name == rdfType {
+		return "a", nil
+	}
+	return w.iri(name)
+}
+
+var sparqlLocalName = regexp.MustCompile(`^[A-Za-z0-9_]([A-Za-z0-9_.\-]*[A-Za-z0-9_\-])?$`)
+
+// Renders a name as a prefixed name when possible and as an IRI otherwise.
+func (w *sparqlWriter) iri(name string) (string, error) {
+	pm := w.t.Prefixes
+	if strings.Contains(name, "://") {
+		name = pm.Compact(name)
+		if strings.Contains(name, "://") {
+			return "<" + name + ">", nil
+		}
+	}
+
+	prefix, local, ok := splitQname(name)
+	if !ok {
+		prefix, local = "", name
+	}
+	ns, ok := pm[prefix]
+	if !ok {
+		if prefix == "" {
+			return "", fmt.Errorf("cannot translate %s without a default namespace", name)
+		}
+		return "", fmt.Errorf("unknown prefix %s in %s", prefix, name)
+	}
+	if !sparqlLocalName.MatchString(local) {
+		return "<" + ns + local + ">", nil
+	}
+	w.used[prefix] = true
+	return prefix + ":" + local, nil
+}
+
+// Reports whether the step moves from the current node to another node.
+func moves(s Step) bool {
+	if isFollow(s) {
+		return true
+	}
+	for _, sub := range s.subcmd {
+		if moves(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func isFollow(s Step) bool {
+	return s.token == "Follow" || s.token == "FollowInverse"
+}
+
+// Splits the Limit steps off the end of a chain and returns the smallest of
+// their counts, or 0 when the chain does not end with a Limit.
+func trailingLimit(chain []Step) ([]Step, int, error) {
+	limit := 0
+	for len(chain) > 0 && chain[len(chain)-1].token == "Limit" {
+		n, err := limitCount(chain[len(chain)-1].arg)
+		if err != nil {
+			return nil, 0, err
+		}
+		if limit == 0 || n < limit {
+			limit = n
+		}
+		chain = chain[:len(chain)-1]
+	}
+	return chain, limit, nil
+}
+
+// Quotes a string as a SPARQL literal.
+func sparqlString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// Renders booleans and numbers as bare literals and everything else as
+// strings.
+func sparqlLiteral(s string) string {
+	if isBareLiteral(s) {
+		return s
+	}
+	return sparqlString(s)
+}
+
+// Reports whether s is a boolean or a number.
+func isBareLiteral(s string) bool {
+	if s == "true" || s == "false" {
+		return true
+	}
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func testPrefixes() PrefixMap {
+	pm := DefaultPrefixes()
+	pm[""] = "http://example.org/"
+	pm["skos"] = skosNs
+	return pm
+}
+
+// Translates every testdata/translate/*.brm rule and compares the result to
+// the golden file with the given extension.
+func testGolden(t *testing.T, ext string, translate func([]Step) (string, error)) {
+	files, err := filepath.Glob("testdata/translate/*.brm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no golden files found")
+	}
+
+	for _, f := range files {
+		t.Run(filepath.Base(f), func(t *testing.T) {
+			src, err := os.ReadFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			chain, err := ParseCommand(string(src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, err := translate(chain)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := strings.TrimSuffix(f, ".brm") + ext
+			if *update {
+				if err := os.WriteFile(golden, []byte(out), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out != string(expected) {
+				t.Errorf("Expected\n%s\ngot\n%s", expected, out)
+			}
+		})
+	}
+}
+
+func TestSPARQLGolden(t *testing.T) {
+	testGolden(t, ".rq", func(chain []Step) (string, error) {
+		return ToSPARQL(chain, testPrefixes())
+	})
+}
+
+func TestSPARQLActivePredicate(t *testing.T) {
+	chain, err := ParseCommand(`Start[iri].IsActive[].Eval`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tr := SPARQLTranslator{
+		Prefixes:   testPrefixes(),
+		Vocabulary: DefaultVocabulary(),
+	}
+	tr.Vocabulary.Active = "ex:status"
+	tr.Vocabulary.ActiveValue = "live"
+
+	query, err := tr.Translate(chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(query, `?n0 ex:status "live" .`) {
+		t.Errorf("Expected configured active predicate in\n%s", query)
+	}
+}
+
+func TestSPARQLNoDefaultNamespace(t *testing.T) {
+	chain, err := ParseCommand(`Start[iri].HasType[Gremlin].Eval`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ToSPARQL(chain, DefaultPrefixes())
+	if err == nil {
+		t.Errorf("Expected error translating a name without a default namespace")
+	}
+}
@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"hash/maphash"
+	"sync"
+	"sync/atomic"
+)
+
+const syncShards = 64
+
+// SyncInternalizer is an Internalizer that is safe for concurrent use. The
+// dictionary is split into shards with their own locks, so goroutines
+// internalizing different strings rarely contend, and lookups of strings
+// that are already known only take a read lock.
+type SyncInternalizer struct {
+	seed    maphash.Seed
+	next    atomic.Uint64
+	strings [syncShards]stringShard
+	iids    [syncShards]iidShard
+}
+
+type stringShard struct {
+	sync.RWMutex
+	m map[string]Iid
+}
+
+type iidShard struct {
+	sync.RWMutex
+	m map[Iid]string
+}
+
+// Creates an empty SyncInternalizer seeded with the Error and NoMatch
+// strings, which get Iids 0 and 1.
+func NewSyncInternalizer() *SyncInternalizer {
+	s := &SyncInternalizer{seed: maphash.MakeSeed()}
+	for i := range s.strings {
+		s.strings[i].m = make(map[string]Iid)
+		s.iids[i].m = make(map[Iid]string)
+	}
+	s.Put("Error")
+	s.Put("NoMatch")
+	return s
+}
+
+func (s *SyncInternalizer) stringShard(str string) *stringShard {
+	return &s.strings[maphash.String(s.seed, str)%syncShards]
+}
+
+func (s *SyncInternalizer) iidShard(i Iid) *iidShard {
+	return &s.iids[i%syncShards]
+}
+
+func (s *SyncInternalizer) GetIid(str string) (Iid, bool) {
+	sh := s.stringShard(str)
+	sh.RLock()
+	i, ok := sh.m[str]
+	sh.RUnlock()
+	return i, ok
+}
+
+func (s *SyncInternalizer) GetString(i Iid) (string, bool) {
+	sh := s.iidShard(i)
+	sh.RLock()
+	str, ok := sh.m[i]
+	sh.RUnlock()
+	return str, ok
+}
+
+func (s *SyncInternalizer) Put(str string) Iid {
+	if i, ok := s.GetIid(str); ok {
+		return i
+	}
+
+	sh := s.stringShard(str)
+	sh.Lock()
+	defer sh.Unlock()
+	if i, ok := sh.m[str]; ok {
+		// Another goroutine put the string while we waited for the lock.
+		return i
+	}
+
+	i := Iid(s.next.Add(1) - 1)
+	// Publish the reverse mapping first so that GetString succeeds for any
+	// Iid that GetIid can return.
+	ish := s.iidShard(i)
+	ish.Lock()
+	ish.m[i] = str
+	ish.Unlock()
+	sh.m[str] = i
+	return i
+}
+
+// Returns the number of strings in the dictionary.
+func (s *SyncInternalizer) Len() int {
+	return int(s.next.Load())
+}
+
+func (s *SyncInternalizer) PutMany(strs []string) []Iid {
+	iids := make([]Iid, len(strs))
+	for i, str := range strs {
+		iids[i] = s.Put(str)
+	}
+	return iids
+}
+
+func (s *SyncInternalizer) GetIids(strs []string) ([]Iid, []bool) {
+	iids := make([]Iid, len(strs))
+	found := make([]bool, len(strs))
+	for i, str := range strs {
+		iids[i], found[i] = s.GetIid(str)
+	}
+	return iids, found
+}
+
+func (s *SyncInternalizer) GetStrings(iids []Iid) ([]string, []bool) {
+	strs := make([]string, len(iids))
+	found := make([]bool, len(iids))
+	for i, iid := range iids {
+		strs[i], found[i] = s.GetString(iid)
+	}
+	return strs, found
+}
@@ -0,0 +1,152 @@
+package parser
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestSyncInternalizerSeeded(t *testing.T) {
+	s := NewSyncInternalizer()
+
+	if i, ok := s.GetIid("Error"); !ok || i != 0 {
+		t.Errorf("Expected Error to be iid 0 got %d", i)
+	}
+	if i, ok := s.GetIid("NoMatch"); !ok || i != 1 {
+		t.Errorf("Expected NoMatch to be iid 1 got %d", i)
+	}
+}
+
+func TestSyncInternalizerPut(t *testing.T) {
+	s := NewSyncInternalizer()
+	red := s.Put("red")
+	blue := s.Put("blue")
+
+	if red == blue {
+		t.Errorf("Expected different iids for red and blue")
+	}
+	if s.Put("red") != red {
+		t.Errorf("Expected the same iid when putting red twice")
+	}
+	if str, ok := s.GetString(blue); !ok || str != "blue" {
+		t.Errorf("Expected blue got %s", str)
+	}
+	if _, ok := s.GetIid("green"); ok {
+		t.Errorf("Expected green to be unknown")
+	}
+}
+
+func TestSyncInternalizerConcurrentPut(t *testing.T) {
+	s := NewSyncInternalizer()
+	const workers = 16
+	const n = 1000
+
+	iids := make([][]Iid, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			iids[w] = make([]Iid, n)
+			for i := 0; i < n; i++ {
+				iids[w][i] = s.Put(fmt.Sprintf("iri-%d", i))
+				if _, ok := s.GetString(iids[w][i]); !ok {
+					t.Errorf("Expected iid %d to be visible", iids[w][i])
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for w := 1; w < workers; w++ {
+		for i := 0; i < n; i++ {
+			if iids[w][i] != iids[0][i] {
+				t.Fatalf("Expected iri-%d to have one iid got %d and %d", i, iids[0][i], iids[w][i])
+			}
+		}
+	}
+
+	if s.Len() != n+2 {
+		t.Errorf("Expected %d strings got %d", n+2, s.Len())
+	}
+	seen := make(map[Iid]bool)
+	for _, i := range iids[0] {
+		if seen[i] {
+			t.Errorf("Expected unique iids, %d is used twice", i)
+		}
+		seen[i] = true
+	}
+}
+
+func TestSyncInternalizerInternalizeSteps(t *testing.T) {
+	s := NewSyncInternalizer()
+	chain, err := ParseCommand(`Start[iri].IsInstance[red].Eval`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps, err := InternalizeSteps(chain, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	red, _ := s.GetIid("red")
+	if steps[1].Arg != red {
+		t.Errorf("Expected iid %d got %d", red, steps[1].Arg)
+	}
+}
+
+func benchmarkSyncInternalizer(b *testing.B, goroutines int) {
+	s := NewSyncInternalizer()
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("https://bsm.bloomberg.com/instance/%d", i)
+	}
+	// Half of the keys are known up front, the rest are added while the
+	// benchmark runs.
+	for _, k := range keys[:len(keys)/2] {
+		s.Put(k)
+	}
+
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	per := b.N/goroutines + 1
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < per; i++ {
+				k := keys[(g*per+i)%len(keys)]
+				s.GetString(s.Put(k))
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+func BenchmarkSyncInternalizer(b *testing.B) {
+	for _, g := range []int{1, 8, 64} {
+		b.Run(fmt.Sprintf("goroutines=%d", g), func(b *testing.B) {
+			benchmarkSyncInternalizer(b, g)
+		})
+	}
+}
+
+func TestSyncInternalizerBatch(t *testing.T) {
+	s := NewSyncInternalizer()
+	red := s.Put("red")
+
+	iids := s.PutMany([]string{"red", "blue", "blue", "green"})
+	if iids[0] != red || iids[1] != iids[2] || iids[1] == iids[3] {
+		t.Errorf("Unexpected iids %v", iids)
+	}
+
+	got, found := s.GetIids([]string{"blue", "grey"})
+	if !found[0] || found[1] || got[0] != iids[1] {
+		t.Errorf("Unexpected iids %v found %v", got, found)
+	}
+
+	strs, found := s.GetStrings([]Iid{iids[3], 99})
+	if !found[0] || found[1] || strs[0] != "green" {
+		t.Errorf("Unexpected strings %v found %v", strs, found)
+	}
+}
@@ -0,0 +1,6 @@
+MATCH (n0)
+WHERE n0.iri = 'ex:Gizmo'
+MATCH (n0)-[:`ex:Eats`]->(n1)
+MATCH (n1)<-[:`ex:ComesFrom`]-(n2)
+MATCH (n2)-[:`http://other.org/rel`]->(n3)
+RETURN DISTINCT n3
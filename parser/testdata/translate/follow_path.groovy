@@ -0,0 +1 @@
+g.V().has('iri', 'ex:Gizmo').out('ex:Eats').in('ex:ComesFrom').out('http://other.org/rel').dedup()
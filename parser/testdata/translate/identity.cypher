@@ -0,0 +1,2 @@
+MATCH (n0)
+RETURN DISTINCT n0
@@ -0,0 +1 @@
+g.V().dedup()
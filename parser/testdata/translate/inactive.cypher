@@ -0,0 +1,3 @@
+MATCH (n0)
+WHERE NOT coalesce(n0.active = true, false) AND n0.category = 'bsm:Monster'
+RETURN DISTINCT n0
@@ -0,0 +1 @@
+g.V().not(__.has('active', true)).has('category', 'bsm:Monster').dedup()
@@ -0,0 +1,3 @@
+MATCH (n0)
+WHERE n0.iri = 'ex:Gizmo'
+RETURN DISTINCT n0
@@ -0,0 +1 @@
+g.V().has('iri', 'ex:Gizmo').dedup()
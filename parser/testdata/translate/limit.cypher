@@ -0,0 +1,5 @@
+MATCH (n0)
+WHERE n0:`ex:Gremlin`
+MATCH (n0)-[:`ex:Eats`]->(n1)
+RETURN DISTINCT n1
+LIMIT 10
@@ -0,0 +1 @@
+g.V().hasLabel('ex:Gremlin').out('ex:Eats').dedup().limit(10)
@@ -0,0 +1,3 @@
+MATCH (n0)
+WHERE n0.`ex:Name` IN ['Gizmo, the mogwai', '3.14'] AND n0.`ex:Id` IN ['tax (with paren)']
+RETURN DISTINCT n0
@@ -0,0 +1 @@
+g.V().has('ex:Name', within('Gizmo, the mogwai', '3.14')).has('ex:Id', within('tax (with paren)')).dedup()
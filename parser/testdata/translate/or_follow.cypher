@@ -0,0 +1,14 @@
+MATCH (n0)
+WHERE n0:`ex:Gremlin`
+CALL {
+  WITH n0
+  MATCH (n0)-[:`ex:Eats`]->(n2)
+  RETURN n2 AS n1
+  UNION
+  WITH n0
+  WHERE n0:`ex:Food`
+  RETURN n0 AS n1
+}
+WITH n1
+WHERE n1.active = true
+RETURN DISTINCT n1
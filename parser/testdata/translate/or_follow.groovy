@@ -0,0 +1 @@
+g.V().hasLabel('ex:Gremlin').union(__.out('ex:Eats'), __.hasLabel('ex:Food')).has('active', true).dedup()
@@ -0,0 +1,5 @@
+MATCH (n0)
+WHERE (n0:Gremlin OR n0:GooGrok) AND n0.FurColor IN ['green', 'blue'] AND (n0)-[:inScheme]->({iri: 'ex:Animals'}) AND (n0)-[:inScheme]->({iri: 'ex:Fantasy'}) AND (n0)-[:broader]->({iri: 'ex:Preditor'})
+MATCH (n0)-[:SmellOfFood]->(n1)
+WHERE n1:TastyMeal
+RETURN DISTINCT n1
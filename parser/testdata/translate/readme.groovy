@@ -0,0 +1 @@
+g.V().or(__.hasLabel('Gremlin'), __.hasLabel('GooGrok')).has('FurColor', within('green', 'blue')).where(__.out('inScheme').has('iri', 'ex:Animals')).where(__.out('inScheme').has('iri', 'ex:Fantasy')).where(__.out('broader').has('iri', 'ex:Preditor')).out('SmellOfFood').hasLabel('TastyMeal').dedup()
@@ -0,0 +1,45 @@
+package parser
+
+//go:generate go run gen_tokens.go
+
+type Token int
+
+const (
+	_ Token = iota
+	NoOp
+	Start
+	Eval
+	HasType
+	HasCategory
+	HasValue
+	InScheme
+	HasBroader
+	IsInstance
+	Follow
+	FollowInverse
+	IsActive
+	IsInactive
+	Or
+	Limit
+)
+
+// ASCII string to token
+func Atot(s string) Token {
+	if t, ok := tokensByName[s]; ok {
+		return t
+	}
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return customTokens[s]
+}
+
+// Token to ASCII string
+func Ttoa(t Token) string {
+	if t > 0 && int(t) < len(tokenNames) {
+		return tokenNames[t]
+	}
+	if d, ok := LookupToken(t); ok {
+		return d.name
+	}
+	return "**error**"
+}
@@ -0,0 +1,48 @@
+// Code generated by "go run gen_tokens.go"; DO NOT EDIT.
+
+package parser
+
+import "strconv"
+
+var tokenNames = [...]string{
+	NoOp:          "NoOp",
+	Start:         "Start",
+	Eval:          "Eval",
+	HasType:       "HasType",
+	HasCategory:   "HasCategory",
+	HasValue:      "HasValue",
+	InScheme:      "InScheme",
+	HasBroader:    "HasBroader",
+	IsInstance:    "IsInstance",
+	Follow:        "Follow",
+	FollowInverse: "FollowInverse",
+	IsActive:      "IsActive",
+	IsInactive:    "IsInactive",
+	Or:            "Or",
+	Limit:         "Limit",
+}
+
+var tokensByName = map[string]Token{
+	"NoOp":          NoOp,
+	"Start":         Start,
+	"Eval":          Eval,
+	"HasType":       HasType,
+	"HasCategory":   HasCategory,
+	"HasValue":      HasValue,
+	"InScheme":      InScheme,
+	"HasBroader":    HasBroader,
+	"IsInstance":    IsInstance,
+	"Follow":        Follow,
+	"FollowInverse": FollowInverse,
+	"IsActive":      IsActive,
+	"IsInactive":    IsInactive,
+	"Or":            Or,
+	"Limit":         Limit,
+}
+
+func (t Token) String() string {
+	if t > 0 && int(t) < len(tokenNames) {
+		return tokenNames[t]
+	}
+	return "Token(" + strconv.Itoa(int(t)) + ")"
+}
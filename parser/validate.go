@@ -0,0 +1,144 @@
+package parser
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota + 1
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "unknown"
+	}
+}
+
+// Diagnostic is a problem found in a chain of steps. Path holds the index of
+// the step in the chain followed by the index inside each enclosing Or.
+type Diagnostic struct {
+	Severity Severity
+	Path     []int
+	Token    string
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	p := make([]string, len(d.Path))
+	for i, n := range d.Path {
+		p[i] = fmt.Sprint(n)
+	}
+	return fmt.Sprintf("%s: step %s (%s): %s", d.Severity, strings.Join(p, "."), d.Token, d.Message)
+}
+
+// Validator checks a chain of steps against a schema.
+type Validator struct {
+	schema *Schema
+}
+
+func NewValidator(s *Schema) *Validator {
+	return &Validator{schema: s}
+}
+
+// Validates the chain and returns the problems found, if any.
+func (v *Validator) Validate(chain []Step) []Diagnostic {
+	diags := make([]Diagnostic, 0)
+	return v.validate(chain, nil, diags)
+}
+
+func (v *Validator) validate(chain []Step, parent []int, diags []Diagnostic) []Diagnostic {
+	var prev *Property
+
+	for i, s := range chain {
+		path := append(slices.Clone(parent), i)
+		report := func(sev Severity, format string, args ...any) {
+			diags = append(diags, Diagnostic{
+				Severity: sev,
+				Path:     path,
+				Token:    s.token,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		}
+
+		if _, err := checkStep(s); err != nil {
+			report(SeverityError, "%s", err)
+			prev = nil
+			continue
+		}
+
+		var cur *Property
+		switch s.token {
+		case "HasType":
+			if !v.schema.IsClass(s.arg) {
+				report(SeverityError, "unknown class %s", s.arg)
+			}
+		case "InScheme", "HasBroader":
+			if !v.schema.IsConceptScheme(s.arg) {
+				report(SeverityError, "%s is not a skos:ConceptScheme", s.arg)
+			}
+		case "Follow", "FollowInverse":
+			p, ok := v.schema.Property(s.arg)
+			if !ok {
+				report(SeverityError, "unknown property %s", s.arg)
+				break
+			}
+			if p.Kind == DatatypeProperty {
+				report(SeverityError, "%s is a datatype property and cannot be followed", s.arg)
+				break
+			}
+			cur = p
+			if prev != nil {
+				_, out := v.edgeTypes(chain[i-1].token, prev)
+				want, _ := v.edgeTypes(s.token, p)
+				if !v.schema.compatible(out, want) {
+					report(SeverityError, "%s leads to %s but %s expects %s",
+						prev.Name, strings.Join(out, ", "), p.Name, strings.Join(want, ", "))
+				}
+			}
+		case "HasValue":
+			p, ok := v.schema.Property(s.arg)
+			if !ok {
+				report(SeverityError, "unknown property %s", s.arg)
+				break
+			}
+			if p.Kind == ObjectProperty {
+				report(SeverityError, "%s is an object property, use Follow instead of HasValue", s.arg)
+				break
+			}
+			enum := v.schema.Enumeration(s.arg)
+			if len(enum) == 0 {
+				break
+			}
+			for _, val := range s.vals {
+				if !slices.Contains(enum, val) {
+					report(SeverityError, "value %q is not in the range of %s (%s)",
+						val, s.arg, strings.Join(enum, ", "))
+				}
+			}
+		case "Or":
+			diags = v.validate(s.subcmd, path, diags)
+		}
+		prev = cur
+	}
+
+	return diags
+}
+
+// Returns the classes a node must have to traverse the property with the
+// given step and the classes of the node it leads to.
+func (v *Validator) edgeTypes(token string, p *Property) ([]string, []string) {
+	if token == "FollowInverse" {
+		return p.Range, p.Domain
+	}
+	return p.Domain, p.Range
+}
@@ -0,0 +1,117 @@
+package parser
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func validateCmd(t *testing.T, cmd string) []Diagnostic {
+	t.Helper()
+	chain, err := ParseCommand(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewValidator(loadTestSchema(t)).Validate(chain)
+}
+
+func TestValidateReadmeExample(t *testing.T) {
+	cmd := `Start[iri]
+		.Or(HasType[<http://example.org/Gremlin>].HasType[<http://example.org/GooGrok>])
+		.HasValue[<http://example.org/FurColor>, "green", "blue"]
+		.HasBroader[<http://example.org/Animals>, <http://example.org/Preditor>]
+		.Follow[<http://example.org/Eats>]
+		.HasType[<http://example.org/TastyMeal>]
+		.Eval`
+	diags := validateCmd(t, cmd)
+	if len(diags) != 0 {
+		t.Errorf("Expected no diagnostics, got %v", diags)
+	}
+}
+
+func TestValidateUnknownClass(t *testing.T) {
+	diags := validateCmd(t, `Start[iri].Or(HasType[ex:Gremlin].HasType[ex:Hobbit]).Eval`)
+	if len(diags) != 1 {
+		t.Fatalf("Expected 1 diagnostic, got %v", diags)
+	}
+
+	if !reflect.DeepEqual(diags[0].Path, []int{1, 1}) {
+		t.Errorf("Expected path [1 1] got %v", diags[0].Path)
+	}
+	if !strings.Contains(diags[0].Message, "unknown class ex:Hobbit") {
+		t.Errorf("Unexpected message %s", diags[0].Message)
+	}
+}
+
+func TestValidatePropertyInWrongStep(t *testing.T) {
+	diags := validateCmd(t, `Start[iri].Follow[ex:FurColor].HasValue[ex:Eats, "x"].Eval`)
+	if len(diags) != 2 {
+		t.Fatalf("Expected 2 diagnostics, got %v", diags)
+	}
+
+	if diags[0].Token != "Follow" || diags[1].Token != "HasValue" {
+		t.Errorf("Unexpected diagnostics %v", diags)
+	}
+}
+
+func TestValidateUnknownProperty(t *testing.T) {
+	diags := validateCmd(t, `Start[iri].Follow[ex:Smells].Eval`)
+	if len(diags) != 1 || !strings.Contains(diags[0].Message, "unknown property") {
+		t.Errorf("Unexpected diagnostics %v", diags)
+	}
+}
+
+func TestValidateDomainRangeMismatch(t *testing.T) {
+	diags := validateCmd(t, `Start[iri].Follow[ex:Eats].Follow[ex:LivesIn].Eval`)
+	if len(diags) != 1 {
+		t.Fatalf("Expected 1 diagnostic, got %v", diags)
+	}
+	if !reflect.DeepEqual(diags[0].Path, []int{2}) {
+		t.Errorf("Expected path [2] got %v", diags[0].Path)
+	}
+
+	diags = validateCmd(t, `Start[iri].Follow[ex:SmellOfFood].Follow[ex:ComesFrom].Eval`)
+	if len(diags) != 0 {
+		t.Errorf("Expected no diagnostics, got %v", diags)
+	}
+
+	diags = validateCmd(t, `Start[iri].Follow[ex:Eats].FollowInverse[ex:ComesFrom].Eval`)
+	if len(diags) != 0 {
+		t.Errorf("Expected no diagnostics, got %v", diags)
+	}
+}
+
+func TestValidateEnumeratedValues(t *testing.T) {
+	diags := validateCmd(t, `Start[iri].HasValue[ex:FurColor, "green", "pink"].Eval`)
+	if len(diags) != 1 {
+		t.Fatalf("Expected 1 diagnostic, got %v", diags)
+	}
+	if !strings.Contains(diags[0].Message, `"pink"`) {
+		t.Errorf("Unexpected message %s", diags[0].Message)
+	}
+}
+
+func TestValidateConceptScheme(t *testing.T) {
+	diags := validateCmd(t, `Start[iri].InScheme[ex:Animals].HasBroader[ex:Fantasy, ex:Preditor].Eval`)
+	if len(diags) != 1 {
+		t.Fatalf("Expected 1 diagnostic, got %v", diags)
+	}
+	if diags[0].Token != "HasBroader" {
+		t.Errorf("Unexpected diagnostic %v", diags[0])
+	}
+}
+
+func TestValidateMalformedStep(t *testing.T) {
+	chain := []Step{
+		{token: "Start", arg: "iri"},
+		{token: "Or", subcmd: []Step{{token: "HasBroader", arg: "ex:Animals"}}},
+		{token: "Eval"},
+	}
+	diags := NewValidator(loadTestSchema(t)).Validate(chain)
+	if len(diags) != 1 {
+		t.Fatalf("Expected 1 diagnostic, got %v", diags)
+	}
+	if !reflect.DeepEqual(diags[0].Path, []int{1, 0}) {
+		t.Errorf("Expected path [1 0] got %v", diags[0].Path)
+	}
+}
@@ -0,0 +1,24 @@
+package parser
+
+// Vocabulary holds the predicates that steps without an explicit property
+// argument are defined in terms of. Predicates are given in the same compact
+// form as step arguments.
+type Vocabulary struct {
+	Type        string // HasType
+	Category    string // HasCategory
+	InScheme    string // InScheme and HasBroader
+	Broader     string // HasBroader
+	Active      string // IsActive and IsInactive
+	ActiveValue string // literal value of Active for active nodes
+}
+
+func DefaultVocabulary() Vocabulary {
+	return Vocabulary{
+		Type:        rdfType,
+		Category:    "bsm:hasCategory",
+		InScheme:    compactIri(skosNs + "inScheme"),
+		Broader:     compactIri(skosNs + "broader"),
+		Active:      "bsm:isActive",
+		ActiveValue: "true",
+	}
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"bremlin/parser"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/k0kubun/pp"
+	"github.com/mattn/go-isatty"
+	"github.com/peterh/liner"
+)
+
+const replHelp = `Enter a command ending with Eval to evaluate it against the loaded data.
+A command can span several lines; an empty line submits it as is.
+
+  :load FILE             load Turtle or N-Triples data
+  :prefix [NAME IRI]     list the prefixes or add one
+  :explain [COMMAND]     show the internalized plan of a command
+  :ast [COMMAND]         show the parsed steps of a command
+  :json [COMMAND]        show a command as JSON
+  :help                  show this help
+  :quit                  leave the repl
+
+Meta-commands without a command apply to the last command entered.
+`
+
+var metaCommands = []string{":ast", ":explain", ":help", ":json", ":load", ":prefix", ":quit"}
+
+// repl holds the state of an interactive session.
+type repl struct {
+	out      io.Writer
+	is       *parser.SyncInternalizer
+	graph    *parser.MemGraph
+	prefixes parser.PrefixMap
+	terms    []string // IRIs of the loaded data, for completion
+	last     string   // last command entered
+}
+
+func newRepl(out io.Writer) *repl {
+	is := parser.NewSyncInternalizer()
+	return &repl{
+		out:      out,
+		is:       is,
+		graph:    parser.NewMemGraph(nil, is),
+		prefixes: parser.DefaultPrefixes(),
+	}
+}
+
+func runRepl(e *env, args []string) int {
+	fs := e.flags("repl", "[-history FILE] [DATA ...]")
+	history := fs.String("history", defaultHistory(), "file to keep the command history in")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	r := newRepl(e.stdout)
+	for _, f := range fs.Args() {
+		if err := r.load(f); err != nil {
+			fmt.Fprintf(e.stderr, "bremlin repl: %s\n", err)
+			return exitFail
+		}
+	}
+
+	var in prompter
+	if f, ok := e.stdin.(*os.File); ok && isatty.IsTerminal(f.Fd()) {
+		in = newTerminal(r, *history)
+	} else {
+		in = &lineReader{in: bufio.NewScanner(e.stdin), out: e.stdout}
+	}
+	defer in.Close()
+
+	fmt.Fprintln(e.stdout, "Bremlin repl, :help for help")
+	lines := make([]string, 0)
+	for {
+		prompt := "bremlin> "
+		if len(lines) > 0 {
+			prompt = "     ... "
+		}
+		l, err := in.Prompt(prompt)
+		if errors.Is(err, liner.ErrPromptAborted) {
+			lines = lines[:0]
+			continue
+		}
+		if err != nil {
+			// io.EOF when the input ends.
+			break
+		}
+
+		if len(lines) == 0 && strings.TrimSpace(l) == "" {
+			continue
+		}
+		lines = append(lines, l)
+		input := strings.Join(lines, "\n")
+		if !strings.HasPrefix(strings.TrimSpace(input), ":") && !commandComplete(input) && strings.TrimSpace(l) != "" {
+			continue
+		}
+		lines = lines[:0]
+
+		in.AppendHistory(strings.Join(strings.Fields(input), " "))
+		if !r.exec(input) {
+			break
+		}
+	}
+	fmt.Fprintln(e.stdout)
+	return exitOK
+}
+
+// prompter reads the lines of a session.
+type prompter interface {
+	Prompt(prompt string) (string, error)
+	AppendHistory(line string)
+	Close() error
+}
+
+// terminal reads lines from a terminal with line editing, completion and a
+// history kept in a file.
+type terminal struct {
+	*liner.State
+	history string
+}
+
+func newTerminal(r *repl, history string) *terminal {
+	line := liner.NewLiner()
+	line.SetCtrlCAborts(true)
+	line.SetWordCompleter(func(l string, pos int) (string, []string, string) {
+		head, completions := r.complete(l[:pos])
+		return head, completions, l[pos:]
+	})
+	if history != "" {
+		if f, err := os.Open(history); err == nil {
+			line.ReadHistory(f)
+			f.Close()
+		}
+	}
+	return &terminal{State: line, history: history}
+}
+
+// Saves the history and restores the terminal.
+func (t *terminal) Close() error {
+	if t.history != "" {
+		if f, err := os.Create(t.history); err == nil {
+			t.WriteHistory(f)
+			f.Close()
+		}
+	}
+	return t.State.Close()
+}
+
+// lineReader reads lines from input that is not a terminal, such as a pipe.
+type lineReader struct {
+	in  *bufio.Scanner
+	out io.Writer
+}
+
+func (lr *lineReader) Prompt(prompt string) (string, error) {
+	fmt.Fprint(lr.out, prompt)
+	if !lr.in.Scan() {
+		if err := lr.in.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return lr.in.Text(), nil
+}
+
+func (lr *lineReader) AppendHistory(string) {}
+func (lr *lineReader) Close() error         { return nil }
+
+func defaultHistory() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".bremlin_history")
+}
+
+// Reports whether the input is a whole command: its brackets and
+// parentheses are balanced and it ends with Eval.
+func commandComplete(input string) bool {
+	return parser.Balanced(input) && strings.HasSuffix(strings.TrimSpace(input), "Eval")
+}
+
+// Runs a command or meta-command and prints the result. Returns false when
+// the session should end.
+func (r *repl) exec(input string) bool {
+	input = strings.TrimSpace(input)
+	if !strings.HasPrefix(input, ":") {
+		r.last = input
+		if err := r.eval(input); err != nil {
+			fmt.Fprintf(r.out, "Error: %s\n", err)
+		}
+		return true
+	}
+
+	name, arg, _ := strings.Cut(input, " ")
+	arg = strings.TrimSpace(arg)
+	cmd := func() string {
+		if arg != "" {
+			r.last = arg
+		}
+		return r.last
+	}
+
+	var err error
+	switch name {
+	case ":quit", ":q", ":exit":
+		return false
+	case ":help":
+		fmt.Fprint(r.out, replHelp)
+	case ":load":
+		err = r.load(arg)
+	case ":prefix":
+		err = r.prefix(arg)
+	case ":explain":
+		err = r.explain(cmd())
+	case ":ast":
+		err = r.ast(cmd())
+	case ":json":
+		err = r.json(cmd())
+	default:
+		err = fmt.Errorf("unknown meta-command %s, :help lists them", name)
+	}
+	if err != nil {
+		fmt.Fprintf(r.out, "Error: %s\n", err)
+	}
+	return true
+}
+
+func (r *repl) parse(cmd string) ([]parser.Step, error) {
+	if cmd == "" {
+		return nil, fmt.Errorf("no command")
+	}
+	chain, err := parser.ParseCommand(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return parser.ExpandPrefixes(chain, r.prefixes), nil
+}
+
+// Evaluates a command against the loaded data and prints the nodes it leads
+// to.
+func (r *repl) eval(cmd string) error {
+	chain, err := r.parse(cmd)
+	if err != nil {
+		return err
+	}
+	steps, unknown, err := parser.InternalizeStepsReadOnly(chain, r.is)
+	if err != nil {
+		return err
+	}
+	r.warnUnknown(unknown)
+	nodes, err := parser.Evaluate(steps, r.graph, r.is)
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		s, _ := r.is.GetString(n)
+		fmt.Fprintln(r.out, r.display(s))
+	}
+	fmt.Fprintf(r.out, "(%d nodes)\n", len(nodes))
+	return nil
+}
+
+func (r *repl) warnUnknown(unknown []string) {
+	if len(unknown) > 0 {
+		fmt.Fprintf(r.out, "Warning: not in the loaded data: %s\n", strings.Join(unknown, ", "))
+	}
+}
+
+// Returns the form of an IRI shown to the user.
+func (r *repl) display(s string) string {
+	if strings.Contains(s, "://") {
+		return r.prefixes.Compact(s)
+	}
+	return s
+}
+
+func (r *repl) load(path string) error {
+	if path == "" {
+		return fmt.Errorf("usage: :load FILE")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	triples, err := parser.ReadTriples(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	terms := make(map[string]bool)
+	for _, t := range r.terms {
+		terms[t] = true
+	}
+	for _, t := range triples {
+		r.graph.Add(t)
+		terms[t.S] = true
+		terms[t.P] = true
+		if !t.Literal {
+			terms[t.O] = true
+		}
+	}
+	r.terms = r.terms[:0]
+	for t := range terms {
+		if !strings.HasPrefix(t, "_:") {
+			r.terms = append(r.terms, t)
+		}
+	}
+	sort.Strings(r.terms)
+	fmt.Fprintf(r.out, "Loaded %d triples from %s\n", len(triples), path)
+	return nil
+}
+
+func (r *repl) prefix(arg string) error {
+	if arg == "" {
+		names := make([]string, 0, len(r.prefixes))
+		for p := range r.prefixes {
+			names = append(names, p)
+		}
+		sort.Strings(names)
+		for _, p := range names {
+			fmt.Fprintf(r.out, "%s: <%s>\n", p, r.prefixes[p])
+		}
+		return nil
+	}
+
+	f := strings.Fields(arg)
+	if len(f) != 2 {
+		return fmt.Errorf("usage: :prefix NAME <IRI>")
+	}
+	name := strings.TrimSuffix(f[0], ":")
+	iri := strings.TrimSuffix(strings.TrimPrefix(f[1], "<"), ">")
+	r.prefixes[name] = iri
+	return nil
+}
+
+func (r *repl) explain(cmd string) error {
+	chain, err := r.parse(cmd)
+	if err != nil {
+		return err
+	}
+	steps, unknown, err := parser.InternalizeStepsReadOnly(chain, r.is)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(r.out, parser.Explain(steps, r.is))
+	r.warnUnknown(unknown)
+	return nil
+}
+
+func (r *repl) ast(cmd string) error {
+	chain, err := r.parse(cmd)
+	if err != nil {
+		return err
+	}
+	_, err = pp.Fprintln(r.out, chain)
+	return err
+}
+
+func (r *repl) json(cmd string) error {
+	chain, err := r.parse(cmd)
+	if err != nil {
+		return err
+	}
+	b, err := parser.MarshalChain(chain)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, b, "", "  "); err != nil {
+		return err
+	}
+	fmt.Fprintln(r.out, buf.String())
+	return nil
+}
+
+// Returns the start of the word being completed and its completions: a
+// meta-command at the start of the line, otherwise a step name, prefix or
+// IRI of the loaded data.
+func (r *repl) complete(line string) (string, []string) {
+	i := strings.LastIndexFunc(line, func(c rune) bool {
+		return !isWordChar(c)
+	})
+	head, word := line[:i+1], line[i+1:]
+
+	var candidates []string
+	if strings.TrimSpace(head) == "" && strings.HasPrefix(word, ":") {
+		candidates = metaCommands
+	} else {
+		candidates = parser.StepNames()
+		for p := range r.prefixes {
+			if p != "" {
+				candidates = append(candidates, p+":")
+			}
+		}
+		candidates = append(candidates, r.terms...)
+	}
+
+	completions := make([]string, 0)
+	for _, c := range candidates {
+		if word != "" && strings.HasPrefix(c, word) && !slices.Contains(completions, c) {
+			completions = append(completions, c)
+		}
+	}
+	sort.Strings(completions)
+	return head, completions
+}
+
+func isWordChar(c rune) bool {
+	return c == ':' || c == '_' || c == '-' || c == '/' || c == '#' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
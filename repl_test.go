@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommandComplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`Start[iri].Eval`, true},
+		{"Start[iri]\n.Or(\n", false},
+		{"Start[iri]\n.Or(\nHasType[a]\n)\n.Eval", true},
+		{`Start[iri].HasValue[f, "Eval`, false},
+		{`Start[iri].HasValue[f, "(x"].Eval`, true},
+		{`Start[iri].HasType[a]`, false},
+	}
+	for _, test := range tests {
+		if got := commandComplete(test.input); got != test.expected {
+			t.Errorf("%q: expected %v got %v", test.input, test.expected, got)
+		}
+	}
+}
+
+func testRepl(t *testing.T) (*repl, *bytes.Buffer) {
+	t.Helper()
+	var out bytes.Buffer
+	r := newRepl(&out)
+	if err := r.load("parser/testdata/zoo.ttl"); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	return r, &out
+}
+
+func TestReplEval(t *testing.T) {
+	r, out := testRepl(t)
+	r.exec("Start[iri]\n.HasType[ex:Gremlin]\n.IsActive[]\n.Eval")
+	if out.String() != "ex:gizmo\n(1 nodes)\n" {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	r.exec(`Start[iri].HasType[ex:Hobbit].Eval`)
+	if !strings.Contains(out.String(), "Warning: not in the loaded data: ex:Hobbit") {
+		t.Errorf("Expected a warning got %q", out.String())
+	}
+
+	out.Reset()
+	r.exec(`Start[iri].HasType[].Eval`)
+	if !strings.HasPrefix(out.String(), "Error: ") {
+		t.Errorf("Expected an error got %q", out.String())
+	}
+}
+
+func TestReplPrefix(t *testing.T) {
+	r, out := testRepl(t)
+	r.exec(":prefix zoo: <http://example.org/>")
+	r.exec(`Start[iri].HasType[zoo:GooGrok].Eval`)
+	if out.String() != "ex:blob\n(1 nodes)\n" {
+		t.Errorf("Unexpected output %q", out.String())
+	}
+
+	out.Reset()
+	r.exec(":prefix")
+	if !strings.Contains(out.String(), "zoo: <http://example.org/>\n") {
+		t.Errorf("Expected zoo in %q", out.String())
+	}
+
+	out.Reset()
+	r.exec(":prefix zoo")
+	if !strings.HasPrefix(out.String(), "Error: ") {
+		t.Errorf("Expected an error got %q", out.String())
+	}
+}
+
+func TestReplMetaCommands(t *testing.T) {
+	r, out := testRepl(t)
+	r.exec(`Start[iri].HasType[ex:Gremlin].Eval`)
+
+	out.Reset()
+	r.exec(":explain")
+	if !strings.HasPrefix(out.String(), "Start\nHasType ex:Gremlin (") {
+		t.Errorf("Unexpected explain output %q", out.String())
+	}
+
+	out.Reset()
+	r.exec(":json Start[iri].IsActive[].Eval")
+	if !strings.Contains(out.String(), `"token": "IsActive"`) {
+		t.Errorf("Unexpected json output %q", out.String())
+	}
+
+	// The command given to :json is now the last command.
+	out.Reset()
+	r.exec(":ast")
+	if !strings.Contains(out.String(), "IsActive") {
+		t.Errorf("Unexpected ast output %q", out.String())
+	}
+
+	out.Reset()
+	r.exec(":bogus")
+	if !strings.HasPrefix(out.String(), "Error: unknown meta-command") {
+		t.Errorf("Expected an error got %q", out.String())
+	}
+
+	if r.exec(":quit") {
+		t.Errorf("Expected :quit to end the session")
+	}
+}
+
+func TestReplComplete(t *testing.T) {
+	r, _ := testRepl(t)
+	tests := []struct {
+		line        string
+		head        string
+		completions []string
+	}{
+		{":ex", "", []string{":explain"}},
+		{"Start[iri].HasT", "Start[iri].", []string{"HasType"}},
+		{"Start[iri].Follow", "Start[iri].", []string{"Follow", "FollowInverse"}},
+		{"Start[iri].HasType[ex:G", "Start[iri].HasType[", []string{"ex:GooGrok", "ex:Gremlin"}},
+		{"Start[iri].HasType[sk", "Start[iri].HasType[", []string{}},
+		{":explain Start[iri].IsI", ":explain Start[iri].", []string{"IsInactive", "IsInstance"}},
+	}
+	for _, test := range tests {
+		head, completions := r.complete(test.line)
+		if head != test.head || !reflect.DeepEqual(completions, test.completions) {
+			t.Errorf("%q: expected %q %v got %q %v", test.line, test.head, test.completions, head, completions)
+		}
+	}
+}
+
+func TestRunRepl(t *testing.T) {
+	input := "Start[iri]\n.HasType[ex:Gremlin]\n.Eval\n:quit\n"
+	code, stdout, stderr := runCmd(t, input, "repl", "-history", "", "parser/testdata/zoo.ttl")
+	if code != exitOK || stderr != "" {
+		t.Fatalf("Expected exit %d got %d: %s", exitOK, code, stderr)
+	}
+	for _, s := range []string{"ex:gizmo", "ex:stripe", "(2 nodes)"} {
+		if !strings.Contains(stdout, s) {
+			t.Errorf("Expected %q in %q", s, stdout)
+		}
+	}
+
+	code, _, stderr = runCmd(t, "", "repl", "-history", "", "missing.ttl")
+	if code != exitFail || !strings.HasPrefix(stderr, "bremlin repl: ") {
+		t.Errorf("Expected exit %d with an error got %d %q", exitFail, code, stderr)
+	}
+}
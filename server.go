@@ -0,0 +1,395 @@
+package main
+
+import (
+	"bremlin/parser"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Largest command the server accepts.
+const maxRequestBytes = 1 << 20
+
+// server answers queries over HTTP against a graph loaded at startup. Every
+// endpoint takes a command, or a rule file with the rule chosen by the rule
+// query parameter, as the body of a POST request and answers with JSON.
+type server struct {
+	is      *parser.SyncInternalizer
+	graph   *parser.MemGraph
+	schema  *parser.Schema // nil when commands are not validated against a schema
+	timeout time.Duration  // time allowed for a request, 0 for no limit
+	limits  parser.Limits  // limits of every evaluation
+	log     *log.Logger
+}
+
+func runServe(e *env, args []string) int {
+	fs := e.flags("serve", "[-addr ADDR] [-grpc ADDR] [-data FILE ...] [-schema FILE] [-timeout DURATION] [-max-nodes N] [-max-hops N] [-max-calls N]")
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	grpcAddr := fs.String("grpc", "", "address to also answer gRPC requests on")
+	var data fileList
+	fs.Var(&data, "data", "Turtle or N-Triples file to evaluate against, can be repeated")
+	schemaPath := fs.String("schema", "", "Turtle file with the ontology to validate against")
+	timeout := fs.Duration("timeout", 10*time.Second, "time allowed for a request, 0 for no limit")
+	var limits parser.Limits
+	fs.IntVar(&limits.Nodes, "max-nodes", 0, "nodes a step may pass on, 0 for no limit")
+	fs.IntVar(&limits.Hops, "max-hops", 0, "Follow and FollowInverse steps in a command, 0 for no limit")
+	fs.IntVar(&limits.Calls, "max-calls", 0, "lookups in the graph per evaluation, 0 for no limit")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	is, g, err := loadGraph(data)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "bremlin serve: %s\n", err)
+		return exitFail
+	}
+	s := &server{
+		is:      is,
+		graph:   g,
+		timeout: *timeout,
+		limits:  limits,
+		log:     log.New(e.stderr, "", log.LstdFlags),
+	}
+	if *schemaPath != "" {
+		if s.schema, err = parser.LoadSchemaFile(*schemaPath); err != nil {
+			fmt.Fprintf(e.stderr, "bremlin serve: %s\n", err)
+			return exitFail
+		}
+	}
+
+	ln, err := net.Listen("tcp", *addr)
+	if err != nil {
+		fmt.Fprintf(e.stderr, "bremlin serve: %s\n", err)
+		return exitFail
+	}
+	s.log.Printf("listening on %s", ln.Addr())
+	var gln net.Listener
+	if *grpcAddr != "" {
+		if gln, err = net.Listen("tcp", *grpcAddr); err != nil {
+			ln.Close()
+			fmt.Fprintf(e.stderr, "bremlin serve: %s\n", err)
+			return exitFail
+		}
+		s.log.Printf("answering gRPC on %s", gln.Addr())
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errc := make(chan error, 2)
+	servers := 1
+	go func() {
+		errc <- serveUntil(ctx, ln, s.handler())
+	}()
+	if gln != nil {
+		servers++
+		go func() {
+			errc <- serveGRPCUntil(ctx, gln, s.grpcServer())
+		}()
+	}
+	// When one of the servers fails the other is stopped too.
+	code := exitOK
+	for ; servers > 0; servers-- {
+		if err := <-errc; err != nil {
+			fmt.Fprintf(e.stderr, "bremlin serve: %s\n", err)
+			code = exitFail
+			cancel()
+		}
+	}
+	return code
+}
+
+// Time allowed for the requests in flight to finish once the server is
+// asked to stop.
+const shutdownTimeout = 10 * time.Second
+
+// Serves HTTP on the listener until the context is done, then stops
+// accepting connections and waits for the requests in flight.
+func serveUntil(ctx context.Context, ln net.Listener, h http.Handler) error {
+	srv := &http.Server{
+		Handler:           h,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+	sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(sctx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/parse", s.endpoint(s.parse))
+	mux.HandleFunc("/validate", s.endpoint(s.validate))
+	mux.HandleFunc("/explain", s.endpoint(s.explain))
+	mux.HandleFunc("/eval", s.endpoint(s.eval))
+	return mux
+}
+
+// httpError is an error reading a request with the status code it is
+// answered with.
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+// badInput is an error in the command of a request, rather than in the
+// server. Each transport answers it its own way: HTTP with 400 Bad Request
+// and gRPC with InvalidArgument.
+type badInput struct {
+	err error
+}
+
+func (e *badInput) Error() string {
+	return e.err.Error()
+}
+
+// Wraps the handling of a command as an endpoint: reads and parses the
+// command, runs f within the timeout of the server and writes its result or
+// error as JSON.
+func (s *server) endpoint(f func(ctx context.Context, chain []parser.Step) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+			return
+		}
+
+		ctx, cancel := s.withTimeout(r.Context())
+		defer cancel()
+
+		var result any
+		b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBytes))
+		if err != nil {
+			status := http.StatusBadRequest
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				status = http.StatusRequestEntityTooLarge
+			}
+			err = &httpError{status, err}
+		} else {
+			var chain []parser.Step
+			if chain, err = parseRequest(string(b), r.URL.Query().Get("rule")); err == nil {
+				result, err = f(ctx, chain)
+			}
+		}
+
+		if err != nil {
+			status := http.StatusInternalServerError
+			var herr *httpError
+			var bad *badInput
+			var limit *parser.LimitExceeded
+			switch {
+			case errors.As(err, &herr):
+				status = herr.status
+			case errors.As(err, &bad):
+				status = http.StatusBadRequest
+			case errors.As(err, &limit):
+				status = http.StatusUnprocessableEntity
+			case errors.Is(err, context.DeadlineExceeded):
+				status = http.StatusGatewayTimeout
+			case errors.Is(err, context.Canceled):
+				// The client is gone, nobody reads the answer.
+				return
+			}
+			if status == http.StatusInternalServerError {
+				s.log.Printf("%s: %s", r.URL.Path, err)
+			}
+			writeJSON(w, status, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// Returns a context that is done once the timeout of the server is over.
+func (s *server) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.timeout > 0 {
+		return context.WithTimeout(ctx, s.timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// Parses the body of a request: a command, or a rule file and the name of
+// the rule to use, which may be left out when the file has a single rule.
+func parseRequest(src, rule string) ([]parser.Step, error) {
+	if !parser.IsRuleSet(src) {
+		if rule != "" {
+			return nil, &badInput{fmt.Errorf("rule %s given for a command", rule)}
+		}
+		chain, err := parser.ParseCommand(src)
+		if err != nil {
+			return nil, &badInput{err}
+		}
+		return chain, nil
+	}
+
+	rs, err := parser.ParseRuleSet(src)
+	if err != nil {
+		return nil, &badInput{err}
+	}
+	if rule == "" {
+		if len(rs.Rules) != 1 {
+			return nil, &badInput{fmt.Errorf("rule file has %d rules, choose one with the rule parameter", len(rs.Rules))}
+		}
+		return rs.Rules[0].Chain, nil
+	}
+	r, ok := rs.Rule(rule)
+	if !ok {
+		return nil, &badInput{fmt.Errorf("rule file has no rule %s", rule)}
+	}
+	return r.Chain, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (s *server) parse(ctx context.Context, chain []parser.Step) (any, error) {
+	b, err := parser.MarshalChain(chain)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"steps": json.RawMessage(b)}, nil
+}
+
+// diagnostic is the JSON form of a parser.Diagnostic.
+type diagnostic struct {
+	Severity string `json:"severity"`
+	Path     []int  `json:"path"`
+	Step     string `json:"step"`
+	Message  string `json:"message"`
+}
+
+func (s *server) validate(ctx context.Context, chain []parser.Step) (any, error) {
+	found, valid := s.check(chain)
+	diags := make([]diagnostic, len(found))
+	for i, d := range found {
+		diags[i] = diagnostic{d.Severity.String(), d.Path, d.Token, d.Message}
+	}
+	return map[string]any{"valid": valid, "diagnostics": diags}, nil
+}
+
+// Checks the command against the schema of the server and reports whether
+// it has no errors. Without a schema a command is valid when it parses.
+func (s *server) check(chain []parser.Step) ([]parser.Diagnostic, bool) {
+	if s.schema == nil {
+		return nil, true
+	}
+	found := parser.NewValidator(s.schema).Validate(chain)
+	found = append(found, parser.InferTypes(chain, s.schema).Diagnostics...)
+	for _, d := range found {
+		if d.Severity == parser.SeverityError {
+			return found, false
+		}
+	}
+	return found, true
+}
+
+func (s *server) explain(ctx context.Context, chain []parser.Step) (any, error) {
+	plan, unknown, err := s.plan(chain)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"plan": plan, "unknown": unknown}, nil
+}
+
+// Returns the plan of the command and the IRIs it names that are not in the
+// graph.
+func (s *server) plan(chain []parser.Step) (string, []string, error) {
+	steps, unknown, err := parser.InternalizeStepsReadOnly(chain, s.is)
+	if err != nil {
+		return "", nil, &badInput{err}
+	}
+	return parser.Explain(steps, s.is), unknown, nil
+}
+
+func (s *server) eval(ctx context.Context, chain []parser.Step) (any, error) {
+	iris, err := s.run(ctx, chain)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"nodes": iris, "count": len(iris)}, nil
+}
+
+// Returns an evaluator of the graph with the limits of the server.
+func (s *server) evaluator() *parser.Evaluator {
+	e := parser.NewEvaluator(s.graph, parser.DefaultVocabulary(), s.is)
+	e.SetLimits(s.limits)
+	return e
+}
+
+// Returns the error an evaluation failed with. Other than the context and
+// the limits of the server, it is the command that is at fault.
+func evalError(err error) error {
+	var limit *parser.LimitExceeded
+	if errors.As(err, &limit) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	return &badInput{err}
+}
+
+// Returns an iterator over the nodes the command selects, which stops once
+// the context is done.
+func (s *server) iterate(ctx context.Context, chain []parser.Step) (parser.NodeIterator, error) {
+	steps, _, err := parser.InternalizeStepsReadOnly(chain, s.is)
+	if err != nil {
+		return nil, &badInput{err}
+	}
+	it, err := s.evaluator().IterateContext(ctx, steps)
+	if err != nil {
+		return nil, evalError(err)
+	}
+	return it, nil
+}
+
+// Evaluates the command and returns the IRIs of the nodes it selects.
+func (s *server) run(ctx context.Context, chain []parser.Step) ([]string, error) {
+	steps, _, err := parser.InternalizeStepsReadOnly(chain, s.is)
+	if err != nil {
+		return nil, &badInput{err}
+	}
+	nodes, err := s.evaluator().EvaluateContext(ctx, steps)
+	if err != nil {
+		return nil, evalError(err)
+	}
+	iris := make([]string, len(nodes))
+	for i, n := range nodes {
+		iris[i], _ = s.is.GetString(n)
+	}
+	return iris, nil
+}
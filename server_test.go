@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"bremlin/parser"
+)
+
+func newTestServer(t *testing.T) *server {
+	t.Helper()
+	is, g, err := loadGraph([]string{"parser/testdata/zoo.ttl"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := parser.LoadSchemaFile("parser/testdata/animals.ttl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &server{is: is, graph: g, schema: schema, timeout: time.Minute, log: log.New(io.Discard, "", 0)}
+}
+
+// Posts the body to the path and decodes the JSON answer.
+func post(t *testing.T, url, body string) (int, map[string]any) {
+	t.Helper()
+	resp, err := http.Post(url, "text/plain", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected application/json got %s", ct)
+	}
+	var v map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, v
+}
+
+func TestServerEndpoints(t *testing.T) {
+	ts := httptest.NewServer(newTestServer(t).handler())
+	defer ts.Close()
+	cmd := `Start[iri].HasType[ex:Gremlin].Eval`
+
+	code, v := post(t, ts.URL+"/parse", cmd)
+	if b, _ := json.Marshal(v["steps"]); code != http.StatusOK || !strings.Contains(string(b), `"arg":"ex:Gremlin"`) {
+		t.Errorf("Unexpected parse answer %d %v", code, v)
+	}
+
+	code, v = post(t, ts.URL+"/validate", `Start[iri].HasType[ex:Hobbit].Eval`)
+	diags, _ := v["diagnostics"].([]any)
+	if code != http.StatusOK || v["valid"] != false || len(diags) == 0 || !strings.Contains(diags[0].(map[string]any)["message"].(string), "unknown class ex:Hobbit") {
+		t.Errorf("Unexpected validate answer %d %v", code, v)
+	}
+	code, v = post(t, ts.URL+"/validate", cmd)
+	if code != http.StatusOK || v["valid"] != true {
+		t.Errorf("Unexpected validate answer %d %v", code, v)
+	}
+
+	code, v = post(t, ts.URL+"/explain", `Start[iri].HasType[ex:Gremlin].HasType[ex:Hobbit].Eval`)
+	if code != http.StatusOK || !strings.Contains(v["plan"].(string), "HasType ex:Gremlin") || len(v["unknown"].([]any)) != 1 {
+		t.Errorf("Unexpected explain answer %d %v", code, v)
+	}
+
+	code, v = post(t, ts.URL+"/eval", cmd)
+	if code != http.StatusOK || v["count"] != 2.0 || v["nodes"].([]any)[0] != "ex:gizmo" {
+		t.Errorf("Unexpected eval answer %d %v", code, v)
+	}
+}
+
+func TestServerRuleFiles(t *testing.T) {
+	ts := httptest.NewServer(newTestServer(t).handler())
+	defer ts.Close()
+	rules := "rule A { Start[iri].HasType[ex:Gremlin].Eval }\nrule B { Start[iri].HasType[ex:GooGrok].Eval }"
+
+	code, v := post(t, ts.URL+"/eval?rule=B", rules)
+	if code != http.StatusOK || v["count"] != 1.0 {
+		t.Errorf("Unexpected eval answer %d %v", code, v)
+	}
+	code, v = post(t, ts.URL+"/eval", rules)
+	if code != http.StatusBadRequest || !strings.Contains(v["error"].(string), "choose one with the rule parameter") {
+		t.Errorf("Unexpected eval answer %d %v", code, v)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	ts := httptest.NewServer(newTestServer(t).handler())
+	defer ts.Close()
+
+	code, v := post(t, ts.URL+"/parse", `Start[iri].Bogus[].Eval`)
+	if code != http.StatusBadRequest || !strings.Contains(v["error"].(string), "unknown step Bogus") {
+		t.Errorf("Unexpected answer %d %v", code, v)
+	}
+
+	code, _ = post(t, ts.URL+"/parse", "Start[iri]."+strings.Repeat(" ", maxRequestBytes)+"Eval")
+	if code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected %d got %d", http.StatusRequestEntityTooLarge, code)
+	}
+
+	resp, err := http.Get(ts.URL + "/eval")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != http.MethodPost {
+		t.Errorf("Expected %d got %d", http.StatusMethodNotAllowed, resp.StatusCode)
+	}
+}
+
+func TestServerTimeout(t *testing.T) {
+	s := newTestServer(t)
+	s.timeout = time.Nanosecond
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	code, v := post(t, ts.URL+"/eval", `Start[iri].Eval`)
+	if code != http.StatusGatewayTimeout || !strings.Contains(v["error"].(string), "deadline exceeded") {
+		t.Errorf("Unexpected answer %d %v", code, v)
+	}
+}
+
+func TestServerRunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	chain, err := parser.ParseCommand(`Start[iri].HasType[ex:Gremlin].Eval`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newTestServer(t).run(ctx, chain); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v got %v", context.Canceled, err)
+	}
+}
+
+func TestServerLimits(t *testing.T) {
+	s := newTestServer(t)
+	s.limits = parser.Limits{Nodes: 1, Hops: 1}
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	code, v := post(t, ts.URL+"/eval", `Start[iri].HasType[ex:Gremlin].Eval`)
+	if code != http.StatusUnprocessableEntity || !strings.Contains(v["error"].(string), "step 1 HasType[ex:Gremlin] exceeded the limit of 1 nodes") {
+		t.Errorf("Unexpected answer %d %v", code, v)
+	}
+	code, v = post(t, ts.URL+"/eval", `Start[iri].IsInstance[ex:gizmo].Follow[ex:Eats].Follow[ex:LivesIn].Eval`)
+	if code != http.StatusUnprocessableEntity || !strings.Contains(v["error"].(string), "1 hops") {
+		t.Errorf("Unexpected answer %d %v", code, v)
+	}
+	code, _ = post(t, ts.URL+"/eval", `Start[iri].IsInstance[ex:gizmo].Eval`)
+	if code != http.StatusOK {
+		t.Errorf("Expected %d got %d", http.StatusOK, code)
+	}
+}
+
+func TestServeUntil(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newTestServer(t).handler()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serveUntil(ctx, ln, h)
+	}()
+
+	code, _ := post(t, "http://"+ln.Addr().String()+"/eval", `Start[iri].Eval`)
+	if code != http.StatusOK {
+		t.Errorf("Expected %d got %d", http.StatusOK, code)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected a clean shutdown got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the server to shut down")
+	}
+	if _, err := http.Post("http://"+ln.Addr().String()+"/eval", "text/plain", strings.NewReader("")); err == nil {
+		t.Error("Expected the server to stop accepting connections")
+	}
+}